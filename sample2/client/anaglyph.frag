@@ -0,0 +1,15 @@
+#version 120
+
+varying vec2 tc0;
+
+uniform sampler2D Texture0;
+uniform sampler2D Texture1;
+
+void main()
+{
+	// The left eye is seen through the red filter, the right eye through
+	// the cyan one.
+	vec4 left = texture2D(Texture0, tc0);
+	vec4 right = texture2D(Texture1, tc0);
+	gl_FragColor = vec4(left.r, right.g, right.b, 1.0);
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// Updater is the effect of an animation state on an object. Start is called
+// when the state is entered, Update every frame while it is current, and Stop
+// when it is left so the effect can be undone.
+type Updater interface {
+	Start(o *gfx.Object)
+	Update(o *gfx.Object, dt float64)
+	Stop(o *gfx.Object)
+}
+
+// UpdaterFunc is an Updater with nothing to start or stop.
+type UpdaterFunc func(o *gfx.Object, dt float64)
+
+func (f UpdaterFunc) Start(o *gfx.Object)              {}
+func (f UpdaterFunc) Update(o *gfx.Object, dt float64) { f(o, dt) }
+func (f UpdaterFunc) Stop(o *gfx.Object)               {}
+
+// AnimStateMachine switches an object between named animation states.
+type AnimStateMachine struct {
+	o       *gfx.Object
+	states  map[string]Updater
+	current string
+}
+
+func NewAnimStateMachine(o *gfx.Object) *AnimStateMachine {
+	return &AnimStateMachine{
+		o:      o,
+		states: make(map[string]Updater),
+	}
+}
+
+// AddState adds a state, the first one added becomes the current state.
+func (a *AnimStateMachine) AddState(name string, u Updater) {
+	a.states[name] = u
+	if a.current == "" {
+		a.current = name
+		u.Start(a.o)
+	}
+}
+
+// State returns the name of the current state.
+func (a *AnimStateMachine) State() string {
+	return a.current
+}
+
+// Transition stops the current state and starts the named one. Transitioning
+// to the current state does nothing.
+func (a *AnimStateMachine) Transition(name string) error {
+	next, ok := a.states[name]
+	if !ok {
+		return fmt.Errorf("anim: unknown state %q", name)
+	}
+	if name == a.current {
+		return nil
+	}
+	if cur, ok := a.states[a.current]; ok {
+		cur.Stop(a.o)
+	}
+	a.current = name
+	next.Start(a.o)
+	return nil
+}
+
+// Update advances the current state by dt seconds.
+func (a *AnimStateMachine) Update(dt float64) {
+	if u, ok := a.states[a.current]; ok {
+		u.Update(a.o, dt)
+	}
+}
+
+// Spin turns an object around its Z axis, returning it to its starting
+// rotation when stopped.
+type Spin struct {
+	Speed float64 // Degrees per second.
+
+	start lmath.Vec3
+}
+
+func (s *Spin) Start(o *gfx.Object) {
+	s.start = o.Rot()
+}
+
+func (s *Spin) Update(o *gfx.Object, dt float64) {
+	rot := o.Rot()
+	rot.Z = math.Mod(rot.Z+s.Speed*dt, 360)
+	o.SetRot(rot)
+}
+
+func (s *Spin) Stop(o *gfx.Object) {
+	o.SetRot(s.start)
+}
+
+// Bounce moves an object up and down, returning it to its starting position
+// when stopped.
+type Bounce struct {
+	Height    float64
+	Frequency float64 // Bounces per second.
+
+	t      float64
+	offset float64
+}
+
+func (b *Bounce) Start(o *gfx.Object) {
+	b.t, b.offset = 0, 0
+}
+
+func (b *Bounce) Update(o *gfx.Object, dt float64) {
+	b.t += dt
+	offset := b.Height * math.Abs(math.Sin(math.Pi*b.Frequency*b.t))
+	pos := o.Pos()
+	pos.Z += offset - b.offset
+	o.SetPos(pos)
+	b.offset = offset
+}
+
+func (b *Bounce) Stop(o *gfx.Object) {
+	pos := o.Pos()
+	pos.Z -= b.offset
+	o.SetPos(pos)
+	b.offset = 0
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"image"
+	"math"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/lmath"
+	"azul3d.org/engine/mouse"
+)
+
+// Arcball rotates a target object as if it were a trackball grabbed with the
+// left mouse button. The mouse sensitivity scales how far the cursor seems to
+// have moved on the trackball since the drag started.
+type Arcball struct {
+	// OnDragStart, if not nil, is called when a drag starts, before the
+	// target is rotated.
+	OnDragStart func(target *gfx.Object)
+
+	mouseLook
+	target *gfx.Object
+	cam    *camera.Camera
+
+	dragging   bool
+	startPixel image.Point
+	start      lmath.Vec3
+	startRot   lmath.Quat
+}
+
+func NewArcball(cam *camera.Camera, target *gfx.Object) *Arcball {
+	return &Arcball{
+		mouseLook: newMouseLook(),
+		target:    target,
+		cam:       cam,
+	}
+}
+
+// Update rotates the target for the mouse input of this frame, and reports
+// whether it is being dragged.
+func (a *Arcball) Update(bounds image.Rectangle, in *InputState) bool {
+	if p, ok := in.Clicked(); ok {
+		origin, dir, ok := screenRay(a.cam, bounds, p)
+		if _, hit := rayBox(origin, dir, worldBounds(a.target)); ok && hit {
+			if a.OnDragStart != nil {
+				a.OnDragStart(a.target)
+			}
+			a.dragging = true
+			a.startPixel = p
+			a.start = a.spherePoint(bounds, float64(p.X), float64(p.Y))
+			a.startRot = a.target.Quat()
+		}
+	}
+	if !a.dragging {
+		return false
+	}
+	if !in.ButtonDown(mouse.Left) {
+		a.dragging = false
+		return false
+	}
+
+	// Always rotate from where the drag started rather than by each frame's
+	// movement, so that returning to the start restores the exact rotation.
+	d := in.Cursor().Sub(a.startPixel)
+	dx, dy := a.turn(float64(d.X), float64(d.Y))
+	cur := a.spherePoint(bounds, float64(a.startPixel.X)+dx, float64(a.startPixel.Y)+dy)
+	drag := rotationBetween(a.start, cur)
+	a.target.SetQuat(drag.Mul(a.startRot).Normalized())
+	return true
+}
+
+// spherePoint projects the pixel at px, py onto the virtual trackball, centered
+// on the target, and returns the point in world space.
+func (a *Arcball) spherePoint(bounds image.Rectangle, px, py float64) lmath.Vec3 {
+	center, _ := boundingSphere(worldBounds(a.target))
+	c := transformPoint(viewProj(a.cam), center)
+	cx := float64(bounds.Min.X) + (c.X+1)/2*float64(bounds.Dx())
+	cy := float64(bounds.Min.Y) + (1-c.Y)/2*float64(bounds.Dy())
+	r := math.Min(float64(bounds.Dx()), float64(bounds.Dy())) / 3
+
+	x := (px - cx) / r
+	y := (cy - py) / r
+	z := 0.0
+	if d := x*x + y*y; d <= 1 {
+		z = math.Sqrt(1 - d)
+	} else {
+		l := math.Sqrt(d)
+		x, y = x/l, y/l
+	}
+
+	// Convert from view space, where Z points at the viewer, to world space.
+	m := a.cam.Object.Convert(gfx.LocalToWorld)
+	o := transformPoint(m, lmath.Vec3{})
+	right := transformPoint(m, lmath.Vec3{1, 0, 0}).Sub(o)
+	up := transformPoint(m, lmath.Vec3{0, 0, 1}).Sub(o)
+	forward := cameraForward(a.cam)
+	return right.MulScalar(x).Add(up.MulScalar(y)).Sub(forward.MulScalar(z)).Normalized()
+}
+
+// rotationBetween returns the shortest rotation taking the unit vector from to
+// the unit vector to.
+func rotationBetween(from, to lmath.Vec3) lmath.Quat {
+	c := from.Cross(to)
+	q := lmath.Quat{W: 1 + from.Dot(to), X: c.X, Y: c.Y, Z: c.Z}
+	return q.Normalized()
+}
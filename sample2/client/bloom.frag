@@ -0,0 +1,42 @@
+#version 120
+
+varying vec2 tc0;
+
+uniform sampler2D Texture0;
+uniform sampler2D Texture1;
+
+// Stage is 0 to keep the bright parts of Texture0, 1 to blur Texture0 along
+// (StepU, StepV), in texture coordinates, and 2 to add Texture1 scaled by
+// Strength to Texture0.
+uniform float Stage;
+uniform float Threshold;
+uniform float StepU;
+uniform float StepV;
+uniform float Strength;
+
+void main()
+{
+	if(Stage < 0.5) {
+		vec3 c = texture2D(Texture0, tc0).rgb;
+		float l = max(c.r, max(c.g, c.b));
+		gl_FragColor = vec4(c * smoothstep(Threshold, 1.0, l), 1.0);
+	} else if(Stage < 1.5) {
+		// A nine tap gaussian.
+		float w[5];
+		w[0] = 0.227027;
+		w[1] = 0.1945946;
+		w[2] = 0.1216216;
+		w[3] = 0.054054;
+		w[4] = 0.016216;
+		vec2 dir = vec2(StepU, StepV);
+		vec3 sum = texture2D(Texture0, tc0).rgb * w[0];
+		for(int i = 1; i < 5; i++) {
+			sum += texture2D(Texture0, tc0 + dir * float(i)).rgb * w[i];
+			sum += texture2D(Texture0, tc0 - dir * float(i)).rgb * w[i];
+		}
+		gl_FragColor = vec4(sum, 1.0);
+	} else {
+		vec3 c = texture2D(Texture0, tc0).rgb + texture2D(Texture1, tc0).rgb * Strength;
+		gl_FragColor = vec4(c, 1.0);
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"image"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/lmath"
+)
+
+// Bloom makes the bright parts of the scene glow onto their surroundings. It
+// is made of render graph passes: the bright parts are kept, blurred across
+// then up, and added back to the scene.
+type Bloom struct {
+	// Threshold is the brightness, from zero to one, above which colors
+	// glow, and Strength how much of the glow is added. Zero turns it off.
+	Threshold float64
+	Strength  float64
+
+	sources *gfx.GLSLSources
+	cam     *camera.Camera
+}
+
+// NewBloom returns a bloom, initially off, drawn with the given shader.
+func NewBloom(shader *gfx.Shader) *Bloom {
+	return &Bloom{
+		Threshold: 0.8,
+		sources:   shader.GLSL,
+	}
+}
+
+// SetBloom makes the colors of the scene brighter than threshold glow by
+// strength, zero turns it off.
+func (g *Game) SetBloom(strength, threshold float64) {
+	if strength < 0 {
+		strength = 0
+	}
+	g.bloom.Strength = strength
+	g.bloom.Threshold = threshold
+}
+
+// AddPasses adds the passes of the bloom to the graph, reading the scene from
+// the texture named scene and drawing the result to the canvas.
+func (b *Bloom) AddPasses(g *RenderGraph, scene string) {
+	// The graph orders them, they are added last first to show it.
+	g.AddPass(b.pass("bloom composite", 2, false, "", scene, "bloom"))
+	g.AddPass(b.pass("bloom blur y", 1, false, "bloom", "bloom blur x"))
+	g.AddPass(b.pass("bloom blur x", 1, true, "bloom blur x", "bloom bright"))
+	g.AddPass(b.pass("bloom bright", 0, false, "bloom bright", scene))
+}
+
+// pass returns the pass drawing a quad with the bloom shader at the given
+// stage. Blurs are along x if across, and along y otherwise.
+func (b *Bloom) pass(name string, stage float32, across bool, output string, inputs ...string) *RenderPass {
+	quad := newQuad(gfx.NewShader(name))
+	quad.Shader.GLSL = b.sources
+	quad.AlphaMode = gfx.NoAlpha
+	quad.DepthTest = false
+	return &RenderPass{
+		Name:   name,
+		Inputs: inputs,
+		Output: output,
+		Run: func(c gfx.Canvas, textures []*gfx.Texture) {
+			r := c.Bounds()
+			var stepU, stepV float32
+			if across {
+				stepU = 1 / float32(r.Dx())
+			} else {
+				stepV = 1 / float32(r.Dy())
+			}
+			quad.Shader.Lock()
+			quad.Shader.Inputs["Stage"] = stage
+			quad.Shader.Inputs["Threshold"] = float32(b.Threshold)
+			quad.Shader.Inputs["StepU"] = stepU
+			quad.Shader.Inputs["StepV"] = stepV
+			quad.Shader.Inputs["Strength"] = float32(b.Strength)
+			quad.Shader.Unlock()
+			quad.Textures = textures
+			b.draw(c, r, quad)
+		},
+	}
+}
+
+// draw draws the quad over the rectangle r of the canvas.
+func (b *Bloom) draw(c gfx.Canvas, r image.Rectangle, quad *gfx.Object) {
+	if b.cam == nil {
+		b.cam = newOrthoCamera(r)
+	} else {
+		b.cam.Update(r)
+	}
+	quad.SetScale(lmath.Vec3{float64(r.Dx()), 1, float64(r.Dy())})
+	c.Draw(r, quad, b.cam)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"math"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// worldBounds returns the world space axis aligned bounding box of o. It is
+// cached for objects frozen with SetStatic.
+func worldBounds(o *gfx.Object) lmath.Rect3 {
+	if p, ok := props[o]; ok && p.static != nil {
+		return staticBounds(o, p.static)
+	}
+	return transformBox(o.Convert(gfx.LocalToWorld), o.Bounds())
+}
+
+// transformBox returns the axis aligned bounding box of the box b transformed
+// by m.
+func transformBox(m lmath.Mat4, b lmath.Rect3) lmath.Rect3 {
+	var r lmath.Rect3
+	for i := 0; i < 8; i++ {
+		c := b.Min
+		if i&1 != 0 {
+			c.X = b.Max.X
+		}
+		if i&2 != 0 {
+			c.Y = b.Max.Y
+		}
+		if i&4 != 0 {
+			c.Z = b.Max.Z
+		}
+		c = transformPoint(m, c)
+		if i == 0 {
+			r.Min, r.Max = c, c
+			continue
+		}
+		r.Min = lmath.Vec3{math.Min(r.Min.X, c.X), math.Min(r.Min.Y, c.Y), math.Min(r.Min.Z, c.Z)}
+		r.Max = lmath.Vec3{math.Max(r.Max.X, c.X), math.Max(r.Max.Y, c.Y), math.Max(r.Max.Z, c.Z)}
+	}
+	return r
+}
+
+// boundingSphere returns the sphere enclosing the box b.
+func boundingSphere(b lmath.Rect3) (center lmath.Vec3, radius float64) {
+	center = b.Min.Add(b.Max).MulScalar(0.5)
+	radius = b.Max.Sub(center).Length()
+	return
+}
+
+// rayBox returns the distance along the ray at which it enters the box b.
+func rayBox(origin, dir lmath.Vec3, b lmath.Rect3) (t float64, ok bool) {
+	t, _, ok = rayBoxNormal(origin, dir, b)
+	if !ok && contains(b, origin) {
+		return 0, true
+	}
+	return
+}
+
+// rayBoxNormal returns the distance along the ray at which it enters the box b
+// from the outside, and the normal of the face it enters through.
+func rayBoxNormal(origin, dir lmath.Vec3, b lmath.Rect3) (t float64, n lmath.Vec3, ok bool) {
+	tMin, tMax := math.Inf(-1), math.Inf(1)
+	axis, sign := -1, 0.0
+	o := [3]float64{origin.X, origin.Y, origin.Z}
+	d := [3]float64{dir.X, dir.Y, dir.Z}
+	min := [3]float64{b.Min.X, b.Min.Y, b.Min.Z}
+	max := [3]float64{b.Max.X, b.Max.Y, b.Max.Z}
+	for i := 0; i < 3; i++ {
+		if d[i] == 0 {
+			if o[i] < min[i] || o[i] > max[i] {
+				return 0, n, false
+			}
+			continue
+		}
+		t0 := (min[i] - o[i]) / d[i]
+		t1 := (max[i] - o[i]) / d[i]
+		s := -1.0
+		if t0 > t1 {
+			t0, t1 = t1, t0
+			s = 1
+		}
+		if t0 > tMin {
+			tMin, axis, sign = t0, i, s
+		}
+		tMax = math.Min(tMax, t1)
+	}
+	if axis < 0 || tMin < 0 || tMax < tMin {
+		return 0, n, false
+	}
+	nv := [3]float64{}
+	nv[axis] = sign
+	return tMin, lmath.Vec3{nv[0], nv[1], nv[2]}, true
+}
+
+// contains reports whether p is inside the box b.
+func contains(b lmath.Rect3, p lmath.Vec3) bool {
+	return p.X >= b.Min.X && p.X <= b.Max.X &&
+		p.Y >= b.Min.Y && p.Y <= b.Max.Y &&
+		p.Z >= b.Min.Z && p.Z <= b.Max.Z
+}
+
+// expand returns the box b grown by r on every side.
+func expand(b lmath.Rect3, r float64) lmath.Rect3 {
+	e := lmath.Vec3{r, r, r}
+	return lmath.Rect3{Min: b.Min.Sub(e), Max: b.Max.Add(e)}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/lmath"
+)
+
+// selectTint is the color mixed into the selected objects, by its alpha.
+var selectTint = gfx.Color{1, 0.8, 0.3, 0.4}
+
+// boxSelect is the state of a drag-select box, in framebuffer pixels.
+type boxSelect struct {
+	active     bool
+	start, cur image.Point
+}
+
+// rect returns the dragged rectangle, whichever way it was dragged.
+func (b boxSelect) rect() image.Rectangle {
+	return image.Rectangle{b.start, b.cur}.Canon()
+}
+
+// BeginBoxSelect starts dragging a selection box from start, in window pixels.
+func (g *Game) BeginBoxSelect(start image.Point) {
+	start = g.framebufferPoint(start)
+	g.box = boxSelect{active: true, start: start, cur: start}
+	g.hud.ShowBox(g.box.rect())
+}
+
+// UpdateBoxSelect moves the dragged corner of the selection box to cur, in
+// window pixels.
+func (g *Game) UpdateBoxSelect(cur image.Point) {
+	if !g.box.active {
+		return
+	}
+	g.box.cur = g.framebufferPoint(cur)
+	g.hud.ShowBox(g.box.rect())
+}
+
+// EndBoxSelect hides the selection box and selects, and returns, every object
+// whose bounds on screen intersect it, even partially.
+func (g *Game) EndBoxSelect() []*gfx.Object {
+	if !g.box.active {
+		return nil
+	}
+	g.box.active = false
+	g.hud.ShowBox(image.Rectangle{})
+
+	// A box without area still selects what is under it.
+	r := g.box.rect()
+	r.Max = r.Max.Add(image.Pt(1, 1))
+
+	for _, o := range g.boxSelected {
+		setSelectTint(o, gfx.Color{})
+	}
+	g.Select(nil)
+	g.boxSelected = g.boxSelected[:0]
+	for _, so := range g.scene.objects {
+		if b, ok := screenBounds(g.cam, g.d.Bounds(), worldBounds(so.Object)); ok && b.Overlaps(r) {
+			setSelectTint(so.Object, selectTint)
+			g.boxSelected = append(g.boxSelected, so.Object)
+		}
+	}
+	return g.boxSelected
+}
+
+// setSelectTint mixes the color c into o by its alpha, to show it is
+// selected. Unlike emissive light it leaves the glow of objects such as the
+// light gizmo alone.
+func setSelectTint(o *gfx.Object, c gfx.Color) {
+	sh := ownShader(o)
+	sh.Lock()
+	sh.Inputs["SelectTint"] = c
+	sh.Unlock()
+	inputsChanged(o)
+}
+
+// screenBounds returns the rectangle, in pixels of the canvas bounds c, that
+// the world space box b covers as seen by cam. Corners behind the camera are
+// ignored, and a box entirely behind it is not on screen at all.
+func screenBounds(cam *camera.Camera, c image.Rectangle, b lmath.Rect3) (image.Rectangle, bool) {
+	vp := viewProj(cam)
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	onScreen := false
+	for i := 0; i < 8; i++ {
+		p := b.Min
+		if i&1 != 0 {
+			p.X = b.Max.X
+		}
+		if i&2 != 0 {
+			p.Y = b.Max.Y
+		}
+		if i&4 != 0 {
+			p.Z = b.Max.Z
+		}
+		clip := transformClip(vp, p)
+		if clip.W <= 0 {
+			continue
+		}
+		x := float64(c.Min.X) + (clip.X/clip.W+1)/2*float64(c.Dx())
+		y := float64(c.Min.Y) + (1-clip.Y/clip.W)/2*float64(c.Dy())
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		onScreen = true
+	}
+	if !onScreen {
+		return image.Rectangle{}, false
+	}
+	return image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY))), true
+}
+
+// ShowBox draws a translucent rectangle over r, in pixels from the top-left of
+// the screen. An empty rectangle hides it.
+func (h *HUD) ShowBox(r image.Rectangle) {
+	if r.Empty() {
+		h.box = nil
+		return
+	}
+	if h.boxQuad == nil {
+		img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+		img.SetRGBA(0, 0, color.RGBA{40, 100, 220, 80})
+		tex := gfx.NewTexture()
+		tex.Source = img
+		tex.Bounds = img.Bounds()
+		tex.MinFilter = gfx.Nearest
+		tex.MagFilter = gfx.Nearest
+		h.boxQuad = newQuad(h.shader)
+		h.boxQuad.Textures = []*gfx.Texture{tex}
+	}
+	h.box = h.boxQuad
+	h.box.SetPos(lmath.Vec3{float64(r.Min.X), 0, float64(h.bounds.Dy() - r.Max.Y)})
+	h.box.SetScale(lmath.Vec3{float64(r.Dx()), 1, float64(r.Dy())})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"image"
+	"math"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/lmath"
+)
+
+// newOrthoCamera returns an orthographic camera for the given screen bounds,
+// where one unit is one pixel and the origin is the bottom-left corner.
+func newOrthoCamera(bounds image.Rectangle) *camera.Camera {
+	cam := camera.New(bounds)
+	cam.Ortho = true
+	cam.Update(bounds)
+	cam.SetPos(lmath.Vec3{0, -1, 0})
+	return cam
+}
+
+// viewProj returns the matrix that transforms world space into the clip space
+// of the given camera.
+func viewProj(cam *camera.Camera) lmath.Mat4 {
+	view := cam.Object.Convert(gfx.WorldToLocal)
+	return view.Mul(cam.Projection().Mat4())
+}
+
+// transformPoint transforms p by m, including the perspective divide.
+func transformPoint(m lmath.Mat4, p lmath.Vec3) lmath.Vec3 {
+	x := p.X*m[0][0] + p.Y*m[1][0] + p.Z*m[2][0] + m[3][0]
+	y := p.X*m[0][1] + p.Y*m[1][1] + p.Z*m[2][1] + m[3][1]
+	z := p.X*m[0][2] + p.Y*m[1][2] + p.Z*m[2][2] + m[3][2]
+	w := p.X*m[0][3] + p.Y*m[1][3] + p.Z*m[2][3] + m[3][3]
+	if w == 0 {
+		return lmath.Vec3{x, y, z}
+	}
+	return lmath.Vec3{x / w, y / w, z / w}
+}
+
+// transformClip transforms p by m into homogeneous clip coordinates.
+func transformClip(m lmath.Mat4, p lmath.Vec3) lmath.Vec4 {
+	return lmath.Vec4{
+		p.X*m[0][0] + p.Y*m[1][0] + p.Z*m[2][0] + m[3][0],
+		p.X*m[0][1] + p.Y*m[1][1] + p.Z*m[2][1] + m[3][1],
+		p.X*m[0][2] + p.Y*m[1][2] + p.Z*m[2][2] + m[3][2],
+		p.X*m[0][3] + p.Y*m[1][3] + p.Z*m[2][3] + m[3][3],
+	}
+}
+
+// inFrustum reports whether any part of the world space box b may be inside
+// the clip volume of the view projection matrix vp. It is conservative: boxes
+// that are not entirely outside of a single clip plane are counted as inside.
+func inFrustum(vp lmath.Mat4, b lmath.Rect3) bool {
+	var outside [6]int
+	for i := 0; i < 8; i++ {
+		c := b.Min
+		if i&1 != 0 {
+			c.X = b.Max.X
+		}
+		if i&2 != 0 {
+			c.Y = b.Max.Y
+		}
+		if i&4 != 0 {
+			c.Z = b.Max.Z
+		}
+		p := transformClip(vp, c)
+		if p.X < -p.W {
+			outside[0]++
+		}
+		if p.X > p.W {
+			outside[1]++
+		}
+		if p.Y < -p.W {
+			outside[2]++
+		}
+		if p.Y > p.W {
+			outside[3]++
+		}
+		if p.Z < -p.W {
+			outside[4]++
+		}
+		if p.Z > p.W {
+			outside[5]++
+		}
+	}
+	for _, n := range outside {
+		if n == 8 {
+			return false
+		}
+	}
+	return true
+}
+
+// screenRay returns the world space ray that passes through the pixel p of the
+// canvas bounds b as seen by the given camera.
+func screenRay(cam *camera.Camera, b image.Rectangle, p image.Point) (origin, dir lmath.Vec3, ok bool) {
+	inv, ok := viewProj(cam).Inverse()
+	if !ok {
+		return
+	}
+	x := 2*float64(p.X-b.Min.X)/float64(b.Dx()) - 1
+	y := 1 - 2*float64(p.Y-b.Min.Y)/float64(b.Dy())
+	near := transformPoint(inv, lmath.Vec3{x, y, -1})
+	far := transformPoint(inv, lmath.Vec3{x, y, 1})
+	return near, far.Sub(near).Normalized(), true
+}
+
+// cameraForward returns the world space direction the camera looks in.
+func cameraForward(cam *camera.Camera) lmath.Vec3 {
+	m := cam.Object.Convert(gfx.LocalToWorld)
+	o := transformPoint(m, lmath.Vec3{})
+	return transformPoint(m, lmath.Vec3{0, 1, 0}).Sub(o).Normalized()
+}
+
+// focusMargin is how much larger than the bounding sphere of an object the
+// view is made when focusing on it.
+const focusMargin = 1.2
+
+// focusPos returns where the camera has to be, keeping its rotation, for the
+// bounding sphere of o to fill the view of the given aspect ratio.
+func focusPos(cam *camera.Camera, o *gfx.Object, aspect float64) lmath.Vec3 {
+	center, radius := boundingSphere(worldBounds(o))
+	return fitSphere(cam, center, radius, aspect)
+}
+
+// fitSphere returns where the camera has to be, keeping its rotation, for the
+// sphere to fill the view of the given aspect ratio.
+func fitSphere(cam *camera.Camera, center lmath.Vec3, radius, aspect float64) lmath.Vec3 {
+	// Use the narrower of the vertical and horizontal field of view, so that
+	// the object fits both ways.
+	fov := lmath.Radians(cam.FOV)
+	if aspect < 1 {
+		fov = 2 * math.Atan(math.Tan(fov/2)*aspect)
+	}
+	dist := radius * focusMargin / math.Sin(fov/2)
+
+	// Very small objects would otherwise end up closer than the near plane.
+	if min := cam.Near + radius; dist < min {
+		dist = min
+	}
+	return center.Sub(cameraForward(cam).MulScalar(dist))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"azul3d.org/engine/lmath"
+)
+
+// CameraState is the camera placement and controller settings that persist
+// between runs. The settings of the fly camera are the ones at the top level,
+// as they were saved before the other controllers had any.
+type CameraState struct {
+	Pos, Rot lmath.Vec3
+	LookSettings
+	Arcball LookSettings
+	Touch   LookSettings
+}
+
+// LookSettings are the mouse sensitivity and vertical inversion of a camera
+// controller.
+type LookSettings struct {
+	SensitivityX float64
+	SensitivityY float64
+	InvertY      bool
+}
+
+// cameraState returns the current camera state.
+func (g *Game) cameraState() CameraState {
+	arcball := g.arcballLook
+	if g.arcball != nil {
+		arcball = g.arcball.settings()
+	}
+	return CameraState{
+		Pos:          g.cam.Pos(),
+		Rot:          g.cam.Rot(),
+		LookSettings: g.fly.settings(),
+		Arcball:      arcball,
+		Touch:        g.touch.settings(),
+	}
+}
+
+// SaveCameraState writes the camera state to a JSON file at path.
+func (g *Game) SaveCameraState(path string) error {
+	st := g.cameraState()
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(st); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadCameraState restores the camera state from the JSON file at path.
+func (g *Game) LoadCameraState(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Start from the current settings, so that files lacking some of the
+	// fields keep the defaults for them.
+	st := g.cameraState()
+	if err := json.NewDecoder(f).Decode(&st); err != nil {
+		return err
+	}
+	g.cam.SetPos(st.Pos)
+	g.cam.SetRot(st.Rot)
+	g.fly.setSettings(st.LookSettings)
+	g.arcballLook = st.Arcball
+	if g.arcball != nil {
+		g.arcball.setSettings(st.Arcball)
+	}
+	g.touch.setSettings(st.Touch)
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// MaxClipPlanes is the number of clip planes the scene shader supports. They
+// are applied by discarding fragments rather than with hardware clip
+// distances, so the limit does not depend on the device.
+const MaxClipPlanes = 4
+
+// SetClipPlane sets the world space clip plane i, given as (a, b, c, d) where
+// points with a*x + b*y + c*z + d < 0 are hidden. The zero plane disables it,
+// and the visible part with several planes is the intersection of their
+// positive sides.
+func (s *Scene) SetClipPlane(i int, plane lmath.Vec4) error {
+	if i < 0 || i >= MaxClipPlanes {
+		return fmt.Errorf("clip plane %d out of range [0, %d)", i, MaxClipPlanes)
+	}
+	s.clipPlanes[i] = plane
+	return nil
+}
+
+// ClearClipPlanes disables every clip plane.
+func (s *Scene) ClearClipPlanes() {
+	s.clipPlanes = [MaxClipPlanes]lmath.Vec4{}
+}
+
+// setClipInputs passes the clip planes to the shader.
+func (s *Scene) setClipInputs(sh *gfx.Shader) {
+	sh.Lock()
+	for i, p := range s.clipPlanes {
+		sh.Inputs[fmt.Sprintf("ClipPlane%d", i)] = gfx.ConvertVec4(p)
+	}
+	sh.Unlock()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"azul3d.org/engine/lmath"
+)
+
+// collisionSkin is the distance kept between a moving sphere and the surfaces
+// it collides with, so that it never starts a move touching one.
+const collisionSkin = 1e-3
+
+// Slide moves a sphere of the given radius at pos by move and returns its new
+// position. When the movement hits the bounds of a scene object the sphere
+// stops at the contact and slides along the surface with what is left of it.
+func (s *Scene) Slide(pos, move lmath.Vec3, radius float64) lmath.Vec3 {
+	// Each iteration removes the movement into one surface, three are enough
+	// to come to a halt in a corner.
+	for i := 0; i < 3; i++ {
+		length := move.Length()
+		if length < collisionSkin {
+			break
+		}
+		dir := move.DivScalar(length)
+
+		hit, hitNormal := length, lmath.Vec3{}
+		for _, o := range s.objects {
+			b := expand(worldBounds(o.Object), radius)
+			t, n, ok := rayBoxNormal(pos, dir, b)
+			if ok && t < hit {
+				hit, hitNormal = t, n
+			}
+		}
+		if hit >= length {
+			return pos.Add(move)
+		}
+
+		// Move up to the contact, then remove the part of the remaining
+		// movement that goes into the surface.
+		travel := hit - collisionSkin
+		if travel < 0 {
+			travel = 0
+		}
+		pos = pos.Add(dir.MulScalar(travel))
+		rest := move.Sub(dir.MulScalar(travel))
+		move = rest.Sub(hitNormal.MulScalar(rest.Dot(hitNormal)))
+	}
+	return pos
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"image"
+	"log"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"azul3d.org/engine/gfx/window"
+	"azul3d.org/engine/keyboard"
+
+	"github.com/mypianoplayer/ragtime_sample/sample2/client/ease"
+)
+
+// Console is a one line command prompt drawn in the HUD. While open it is an
+// input context capturing every key.
+type Console struct {
+	g     *Game
+	label *Label
+	line  string
+	open  bool
+}
+
+func NewConsole(g *Game, label *Label) *Console {
+	return &Console{
+		g:     g,
+		label: label,
+	}
+}
+
+// Open shows the console and pushes it onto the input context stack.
+func (c *Console) Open() {
+	if c.open {
+		return
+	}
+	c.open = true
+	c.line = ""
+	c.g.PushInputContext(c)
+	c.redraw()
+}
+
+// Close hides the console and removes it from the input context stack, even
+// if contexts were pushed above it since it was opened.
+func (c *Console) Close() {
+	if !c.open {
+		return
+	}
+	c.open = false
+	c.g.RemoveInputContext(c)
+	c.label.SetText("")
+}
+
+func (c *Console) redraw() {
+	c.label.SetText("> " + c.line + "_")
+}
+
+// HandleEvent implements the InputContext interface.
+func (c *Console) HandleEvent(e window.Event) bool {
+	switch ev := e.(type) {
+	case keyboard.Typed:
+		if ev.S == "`" {
+			c.Close()
+			return true
+		}
+		for _, r := range ev.S {
+			if unicode.IsPrint(r) {
+				c.line += string(r)
+			}
+		}
+		c.redraw()
+		return true
+
+	case keyboard.ButtonEvent:
+		if ev.State != keyboard.Down {
+			return true
+		}
+		switch ev.Key {
+		case keyboard.Enter:
+			c.run(c.line)
+			c.line = ""
+			c.redraw()
+		case keyboard.Backspace:
+			if r := []rune(c.line); len(r) > 0 {
+				c.line = string(r[:len(r)-1])
+				c.redraw()
+			}
+		case keyboard.Escape:
+			c.Close()
+		}
+		return true
+	}
+	return false
+}
+
+// CapturesKeyboard implements the InputContext interface.
+func (c *Console) CapturesKeyboard() bool {
+	return true
+}
+
+// run executes a console command.
+func (c *Console) run(line string) {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return
+	}
+	floats := func() ([]float64, bool) {
+		var v []float64
+		for _, a := range args[1:] {
+			f, err := strconv.ParseFloat(a, 64)
+			if err != nil {
+				log.Printf("console: %q is not a number\n", a)
+				return nil, false
+			}
+			v = append(v, f)
+		}
+		return v, true
+	}
+
+	switch args[0] {
+	case "gamma":
+		if v, ok := floats(); ok && len(v) == 1 {
+			c.g.SetGamma(v[0])
+			return
+		}
+	case "sens":
+		if v, ok := floats(); ok && len(v) == 2 {
+			c.g.fly.SetMouseSensitivity(v[0], v[1])
+			return
+		}
+	case "invert":
+		c.g.fly.SetInvertY(!c.g.fly.InvertY())
+		return
+	case "stereo":
+		modes := map[string]StereoMode{"off": StereoOff, "sbs": StereoSideBySide, "anaglyph": StereoAnaglyph}
+		if len(args) >= 2 && len(args) <= 3 {
+			mode, ok := modes[args[1]]
+			sep := c.g.stereo.EyeSeparation
+			if len(args) == 3 {
+				f, err := strconv.ParseFloat(args[2], 64)
+				ok = ok && err == nil
+				sep = f
+			}
+			if ok {
+				c.g.SetStereo(mode, sep)
+				return
+			}
+		}
+	case "pixelate":
+		// pixelate <block size> [hud]
+		if len(args) == 2 || len(args) == 3 && args[2] == "hud" {
+			if n, err := strconv.Atoi(args[1]); err == nil {
+				c.g.SetPixelation(n)
+				c.g.SetPixelateHUD(len(args) == 3)
+				return
+			}
+		}
+	case "video":
+		// video start [W H] | stop | drop on|off
+		switch {
+		case len(args) == 2 && args[1] == "stop":
+			if err := c.g.StopVideoCapture(); err != nil {
+				log.Println("video capture:", err)
+			}
+			return
+		case len(args) == 3 && args[1] == "drop":
+			c.g.SetVideoDropFrames(args[2] == "on")
+			return
+		case len(args) >= 2 && args[1] == "start" && (len(args) == 2 || len(args) == 4):
+			var size image.Point
+			if len(args) == 4 {
+				w, errW := strconv.Atoi(args[2])
+				h, errH := strconv.Atoi(args[3])
+				if errW != nil || errH != nil {
+					break
+				}
+				size = image.Pt(w, h)
+			}
+			if err := c.g.StartVideoCapture(nil, size); err != nil {
+				log.Println("video capture:", err)
+			}
+			return
+		}
+	case "lut":
+		// lut <file.cube> | off
+		if len(args) == 2 {
+			if args[1] == "off" {
+				c.g.SetColorLUT(nil)
+				return
+			}
+			lut, err := LoadCubeLUT(args[1])
+			if err != nil {
+				log.Println("console:", err)
+				return
+			}
+			c.g.SetColorLUT(lut)
+			return
+		}
+	case "reflect":
+		// reflect <reflectivity> [distortion]
+		if v, ok := floats(); ok && len(v) >= 1 && len(v) <= 2 {
+			c.g.reflection.Reflectivity = v[0]
+			if len(v) == 2 {
+				c.g.reflection.Distortion = v[1]
+			}
+			return
+		}
+	case "ease":
+		if len(args) == 2 {
+			if f, ok := ease.Named[strings.ToLower(args[1])]; ok {
+				c.g.CameraEasing = f
+				return
+			}
+		}
+	case "gputime":
+		if len(args) == 2 && (args[1] == "on" || args[1] == "off") {
+			c.g.SetGPUTimers(args[1] == "on")
+			return
+		}
+	case "blur":
+		if v, ok := floats(); ok && len(v) == 1 {
+			c.g.SetMotionBlur(v[0])
+			return
+		}
+	case "hudscale":
+		// hudscale pixel | height | width
+		modes := map[string]ScaleMode{"pixel": ScalePixel, "height": ScaleWithHeight, "width": ScaleWithWidth}
+		if len(args) == 2 {
+			if mode, ok := modes[args[1]]; ok {
+				c.g.hud.SetScaleMode(mode, c.g.d.Bounds().Size())
+				return
+			}
+		}
+	case "touch":
+		if len(args) == 2 && (args[1] == "on" || args[1] == "off") {
+			c.g.SetTouchEnabled(args[1] == "on")
+			return
+		}
+	case "occlusion":
+		if len(args) == 2 && (args[1] == "on" || args[1] == "off") {
+			c.g.scene.SetOcclusionCulling(args[1] == "on")
+			return
+		}
+	case "region":
+		// region <virtual width> <height> <x0> <y0> <x1> <y1>, or region off
+		if len(args) == 2 && args[1] == "off" {
+			c.g.SetViewportRegion(image.Point{}, image.Rectangle{})
+			return
+		}
+		if v, ok := floats(); ok && len(v) == 6 {
+			c.g.SetViewportRegion(
+				image.Pt(int(v[0]), int(v[1])),
+				image.Rect(int(v[2]), int(v[3]), int(v[4]), int(v[5])),
+			)
+			return
+		}
+	case "overscan":
+		if len(args) == 2 {
+			if n, err := strconv.Atoi(args[1]); err == nil {
+				c.g.SetViewportOverscan(n)
+				return
+			}
+		}
+	case "frame":
+		if len(args) == 1 {
+			c.g.FrameScene()
+			return
+		}
+	case "ortho":
+		if len(args) == 2 && (args[1] == "on" || args[1] == "off") {
+			c.g.SetOrthographic(args[1] == "on")
+			return
+		}
+	case "seed":
+		if len(args) == 2 {
+			if n, err := strconv.ParseInt(args[1], 10, 64); err == nil {
+				c.g.SetRandomSeed(n)
+				return
+			}
+		}
+	case "hover":
+		if len(args) == 2 && (args[1] == "on" || args[1] == "off") {
+			c.g.scene.SetHoverHighlight(args[1] == "on")
+			return
+		}
+	case "parallax":
+		// parallax on|off
+		if len(args) == 2 {
+			c.g.parallaxOn = args[1] == "on"
+			return
+		}
+	case "zbias":
+		// zbias <bias>
+		if v, ok := floats(); ok && len(v) == 1 {
+			SetClipZBias(c.g.label, v[0])
+			return
+		}
+	case "statswindow":
+		// statswindow <seconds>
+		if v, ok := floats(); ok && len(v) == 1 {
+			c.g.SetStatsWindow(v[0])
+			return
+		}
+	case "bench":
+		// bench <seconds>
+		if v, ok := floats(); ok && len(v) == 1 && v[0] > 0 {
+			c.g.StartBenchmark(v[0])
+			return
+		}
+	case "snap":
+		// snap <grid spacing>, zero turns snapping off
+		if v, ok := floats(); ok && len(v) == 1 {
+			c.g.drag.Snap = v[0]
+			return
+		}
+	case "soft":
+		// soft <depth range>
+		if v, ok := floats(); ok && len(v) == 1 {
+			c.g.smoke.SetSoftness(v[0])
+			return
+		}
+	case "bloom":
+		// bloom <strength> [threshold]
+		if v, ok := floats(); ok && len(v) >= 1 && len(v) <= 2 {
+			threshold := c.g.bloom.Threshold
+			if len(v) == 2 {
+				threshold = v[1]
+			}
+			c.g.SetBloom(v[0], threshold)
+			return
+		}
+	case "texbudget":
+		// texbudget <MB>
+		if len(args) == 2 {
+			if n, err := strconv.Atoi(args[1]); err == nil {
+				c.g.SetTextureMemoryBudget(n)
+				return
+			}
+		}
+	case "wind":
+		// wind <strength> <frequency>
+		if v, ok := floats(); ok && len(v) == 2 {
+			c.g.SetWind(c.g.scene.Wind.Dir, v[0], v[1])
+			return
+		}
+	case "ruler":
+		c.g.ShowRuler(len(args) < 2 || args[1] != "off")
+		return
+	default:
+		log.Printf("console: unknown command %q\n", args[0])
+		return
+	}
+	log.Printf("console: bad arguments for %q\n", args[0])
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"log"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/gfxutil"
+	"azul3d.org/engine/lmath"
+)
+
+// DebugDraw collects lines and flat quads to draw over the scene until its
+// next Update, such as editing aids. Everything is drawn in flat colors with
+// the trail shader, after the transparent objects, tested against depth but
+// not writing it.
+type DebugDraw struct {
+	// LineWidth is how wide lines are, as a fraction of their distance to
+	// the camera, which keeps it about the same on screen.
+	LineWidth float64
+
+	lines []debugLine
+	quads []debugQuad
+
+	obj  *gfx.Object
+	mesh *gfx.Mesh
+}
+
+type debugLine struct {
+	a, b  lmath.Vec3
+	color gfx.Color
+}
+
+type debugQuad struct {
+	corners [4]lmath.Vec3
+	color   gfx.Color
+}
+
+func newDebugDraw() *DebugDraw {
+	dd := &DebugDraw{LineWidth: 0.004}
+	dd.mesh = gfx.NewMesh()
+	dd.obj = gfx.NewObject()
+	dd.obj.State = gfx.NewState()
+	dd.obj.AlphaMode = gfx.AlphaBlend
+	dd.obj.DepthWrite = false
+	dd.obj.FaceCulling = gfx.NoFaceCulling
+	dd.obj.Meshes = []*gfx.Mesh{dd.mesh}
+	return dd
+}
+
+// Debug returns the debug drawing of the scene.
+func (s *Scene) Debug() *DebugDraw {
+	if s.debug == nil {
+		s.debug = newDebugDraw()
+	}
+	return s.debug
+}
+
+// Line draws a line from a to b, in world space.
+func (dd *DebugDraw) Line(a, b lmath.Vec3, c gfx.Color) {
+	dd.lines = append(dd.lines, debugLine{a, b, c})
+}
+
+// Quad fills the quad with the corners a, b, c and d, in order around it.
+func (dd *DebugDraw) Quad(a, b, c, d lmath.Vec3, col gfx.Color) {
+	dd.quads = append(dd.quads, debugQuad{[4]lmath.Vec3{a, b, c, d}, col})
+}
+
+// Box draws the edges of the box b transformed by m.
+func (dd *DebugDraw) Box(m lmath.Mat4, b lmath.Rect3, c gfx.Color) {
+	var corners [8]lmath.Vec3
+	for i := range corners {
+		p := b.Min
+		if i&1 != 0 {
+			p.X = b.Max.X
+		}
+		if i&2 != 0 {
+			p.Y = b.Max.Y
+		}
+		if i&4 != 0 {
+			p.Z = b.Max.Z
+		}
+		corners[i] = transformPoint(m, p)
+	}
+	// Corners one bit apart share an edge.
+	for i := range corners {
+		for _, bit := range []int{1, 2, 4} {
+			if i&bit == 0 {
+				dd.Line(corners[i], corners[i|bit], c)
+			}
+		}
+	}
+}
+
+// clear forgets everything drawn so far.
+func (dd *DebugDraw) clear() {
+	dd.lines = dd.lines[:0]
+	dd.quads = dd.quads[:0]
+}
+
+// build rewrites the mesh with the lines facing the camera at eye.
+func (dd *DebugDraw) build(eye lmath.Vec3) {
+	dd.mesh.Lock()
+	verts := dd.mesh.Vertices[:0]
+	colors := dd.mesh.Colors[:0]
+	quad := func(a, b, c, d lmath.Vec3, col gfx.Color) {
+		verts = append(verts,
+			gfx.ConvertVec3(a), gfx.ConvertVec3(b), gfx.ConvertVec3(c),
+			gfx.ConvertVec3(a), gfx.ConvertVec3(c), gfx.ConvertVec3(d),
+		)
+		for i := 0; i < 6; i++ {
+			colors = append(colors, col)
+		}
+	}
+	for _, q := range dd.quads {
+		quad(q.corners[0], q.corners[1], q.corners[2], q.corners[3], q.color)
+	}
+	for _, l := range dd.lines {
+		// The ribbon spans sideways to both the line and the view direction.
+		side := l.b.Sub(l.a).Cross(eye.Sub(l.a))
+		if n := side.Length(); n > 0 {
+			side = side.DivScalar(n)
+		}
+		sa := side.MulScalar(dd.LineWidth / 2 * eye.Sub(l.a).Length())
+		sb := side.MulScalar(dd.LineWidth / 2 * eye.Sub(l.b).Length())
+		quad(l.a.Add(sa), l.b.Add(sb), l.b.Sub(sb), l.a.Sub(sa), l.color)
+	}
+	dd.mesh.Vertices = verts
+	dd.mesh.Colors = colors
+	dd.mesh.Changed = true
+	dd.mesh.Unlock()
+}
+
+// debugObjects builds the debug drawing for the camera at eye and returns its
+// object, or nil if there is nothing to draw or no shader to draw it with.
+func (s *Scene) debugObjects(eye lmath.Vec3) []*gfx.Object {
+	dd := s.debug
+	if dd == nil || s.debugOff || len(dd.lines)+len(dd.quads) == 0 {
+		return nil
+	}
+	if s.debugShader == nil {
+		sh, err := gfxutil.OpenShader("trail")
+		if err != nil {
+			log.Println("Debug drawing disabled:", err)
+			s.debugOff = true
+			return nil
+		}
+		s.debugShader = sh
+	}
+	dd.build(eye)
+	dd.obj.Shader = s.debugShader
+	return []*gfx.Object{dd.obj}
+}
@@ -0,0 +1,43 @@
+#version 120
+
+varying vec4 worldPos;
+varying vec3 worldNormal;
+
+uniform sampler2D Texture0;
+
+// DecalMatrix transforms world space into the projector box, which is the
+// unit cube centered on the origin. DecalDir is the world space direction the
+// decal is projected in.
+uniform mat4 DecalMatrix;
+uniform vec3 DecalDir;
+
+uniform vec4 ClipPlane0;
+uniform vec4 ClipPlane1;
+uniform vec4 ClipPlane2;
+uniform vec4 ClipPlane3;
+
+void main()
+{
+	// Clip the decal like the surface it is projected onto.
+	if(dot(worldPos, ClipPlane0) < 0.0 || dot(worldPos, ClipPlane1) < 0.0 ||
+	   dot(worldPos, ClipPlane2) < 0.0 || dot(worldPos, ClipPlane3) < 0.0) {
+		discard;
+	}
+
+	vec3 p = (DecalMatrix * worldPos).xyz;
+	if(any(greaterThan(abs(p), vec3(0.5)))) {
+		discard;
+	}
+
+	// Skip surfaces facing away from the projector, so that the decal does
+	// not show through on the back of thin objects.
+	vec3 n = normalize(worldNormal);
+	if(!gl_FrontFacing) {
+		n = -n;
+	}
+	if(dot(n, DecalDir) >= 0.0) {
+		discard;
+	}
+
+	gl_FragColor = texture2D(Texture0, vec2(p.x + 0.5, 0.5 - p.z));
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"image"
+	"log"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/gfx/gfxutil"
+	"azul3d.org/engine/lmath"
+)
+
+// Decal projects a texture onto the opaque surfaces inside its projector box,
+// like a sticker. The box is the unit cube centered on the origin of the
+// transform, so its scale is the size of the box, and the texture is
+// projected along the local +Y axis. Parent the transform to the one of an
+// object to keep the decal stuck to it as it moves.
+type Decal struct {
+	*gfx.Transform
+	Texture *gfx.Texture
+
+	// DepthBias is how far, in clip space depth, the decal is moved towards
+	// the camera to keep it from fighting with the surface under it.
+	DepthBias float64
+
+	proxies map[*gfx.Object]*gfx.Object
+}
+
+func NewDecal(t *gfx.Texture) *Decal {
+	return &Decal{
+		Transform: gfx.NewTransform(),
+		Texture:   t,
+		DepthBias: 0.0005,
+		proxies:   make(map[*gfx.Object]*gfx.Object),
+	}
+}
+
+// AddDecal adds the decal d to the scene.
+func (s *Scene) AddDecal(d *Decal) {
+	s.decals = append(s.decals, d)
+}
+
+// drawDecals redraws the opaque objects touching the box of each decal with
+// the decal texture projected onto them.
+func (s *Scene) drawDecals(c gfx.Canvas, r image.Rectangle, cam *camera.Camera, stats *RenderStats) {
+	if len(s.decals) == 0 {
+		return
+	}
+	if s.decalSources == nil {
+		sh, err := gfxutil.OpenShader("decal")
+		if err != nil {
+			log.Println("Decals disabled:", err)
+			s.decals = nil
+			return
+		}
+		s.decalSources = sh.GLSL
+	}
+	for _, d := range s.decals {
+		box := d.bounds()
+		for _, o := range s.opaque {
+			if !overlaps(box, worldBounds(o)) {
+				continue
+			}
+			px := d.proxy(o, s.decalSources)
+			s.setClipInputs(px.Shader)
+			c.Draw(r, px, cam)
+			stats.countDraw(px)
+		}
+	}
+}
+
+// bounds returns the world space bounding box of the projector box.
+func (d *Decal) bounds() lmath.Rect3 {
+	unit := lmath.Rect3{
+		Min: lmath.Vec3{-0.5, -0.5, -0.5},
+		Max: lmath.Vec3{0.5, 0.5, 0.5},
+	}
+	return transformBox(d.Convert(gfx.LocalToWorld), unit)
+}
+
+// proxy returns the object drawing o with the decal shader. Like the proxies
+// of PickByID it shares the transform and meshes of o.
+func (d *Decal) proxy(o *gfx.Object, sources *gfx.GLSLSources) *gfx.Object {
+	px, ok := d.proxies[o]
+	if !ok {
+		px = gfx.NewObject()
+		px.State = gfx.NewState()
+		px.AlphaMode = gfx.AlphaBlend
+		px.DepthWrite = false
+		px.Shader = gfx.NewShader("decal")
+		px.Shader.GLSL = sources
+		d.proxies[o] = px
+	}
+	px.Transform = o.Transform
+	px.Meshes = o.Meshes
+	px.FaceCulling = o.FaceCulling
+	px.Textures = []*gfx.Texture{d.Texture}
+
+	m := d.Convert(gfx.LocalToWorld)
+	origin := transformPoint(m, lmath.Vec3{})
+	dir := transformPoint(m, lmath.Vec3{0, 1, 0}).Sub(origin).Normalized()
+	px.Shader.Lock()
+	px.Shader.Inputs["DecalMatrix"] = gfx.ConvertMat4(d.Convert(gfx.WorldToLocal))
+	px.Shader.Inputs["DecalDir"] = gfx.ConvertVec3(dir)
+	px.Shader.Inputs["DepthBias"] = float32(d.DepthBias)
+	px.Shader.Unlock()
+	return px
+}
+
+// overlaps reports whether the boxes a and b intersect.
+func overlaps(a, b lmath.Rect3) bool {
+	return a.Min.X <= b.Max.X && b.Min.X <= a.Max.X &&
+		a.Min.Y <= b.Max.Y && b.Min.Y <= a.Max.Y &&
+		a.Min.Z <= b.Max.Z && b.Min.Z <= a.Max.Z
+}
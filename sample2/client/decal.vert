@@ -0,0 +1,22 @@
+#version 120
+
+attribute vec3 Vertex;
+attribute vec3 Normal;
+
+uniform mat4 MVP;
+uniform mat4 Model;
+uniform float DepthBias;
+
+varying vec4 worldPos;
+varying vec3 worldNormal;
+
+void main()
+{
+	worldPos = Model * vec4(Vertex, 1.0);
+	worldNormal = (Model * vec4(Normal, 0.0)).xyz;
+	gl_Position = MVP * vec4(Vertex, 1.0);
+
+	// Pull the decal towards the camera, so that it wins the depth test
+	// against the surface it is projected onto.
+	gl_Position.z -= DepthBias * gl_Position.w;
+}
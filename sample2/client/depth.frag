@@ -0,0 +1,9 @@
+#version 120
+
+void main()
+{
+	// Pack the window depth into 24 bits of color, 8 per channel.
+	vec3 enc = fract(gl_FragCoord.z * vec3(1.0, 255.0, 65025.0));
+	enc -= enc.yzz * vec3(1.0 / 255.0, 1.0 / 255.0, 0.0);
+	gl_FragColor = vec4(enc, 1.0);
+}
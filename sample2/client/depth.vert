@@ -0,0 +1,10 @@
+#version 120
+
+attribute vec3 Vertex;
+
+uniform mat4 MVP;
+
+void main()
+{
+	gl_Position = MVP * vec4(Vertex, 1.0);
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"azul3d.org/engine/gfx"
+)
+
+// hasExtension reports whether the OpenGL implementation behind the device
+// advertises the named extension.
+func hasExtension(info gfx.DeviceInfo, name string) bool {
+	if info.GL == nil {
+		return false
+	}
+	for _, e := range info.GL.Extensions {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+// Package ease provides easing functions, which map the linear progress t of
+// an animation from 0 to 1 onto a curve that also starts at 0 and ends at 1.
+//
+// The In variants start slowly, the Out variants end slowly, and the InOut
+// variants do both.
+package ease
+
+import "math"
+
+// Func is an easing function.
+type Func func(t float64) float64
+
+// Linear progresses at a constant rate.
+func Linear(t float64) float64 {
+	return t
+}
+
+// SmoothStep is the cubic Hermite curve 3t^2 - 2t^3.
+func SmoothStep(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
+
+func InQuad(t float64) float64 {
+	return t * t
+}
+
+func OutQuad(t float64) float64 {
+	return 1 - (1-t)*(1-t)
+}
+
+func InOutQuad(t float64) float64 {
+	if t < 0.5 {
+		return 2 * t * t
+	}
+	return 1 - 2*(1-t)*(1-t)
+}
+
+func InCubic(t float64) float64 {
+	return t * t * t
+}
+
+func OutCubic(t float64) float64 {
+	u := 1 - t
+	return 1 - u*u*u
+}
+
+func InOutCubic(t float64) float64 {
+	if t < 0.5 {
+		return 4 * t * t * t
+	}
+	u := 1 - t
+	return 1 - 4*u*u*u
+}
+
+func InSine(t float64) float64 {
+	return 1 - math.Cos(t*math.Pi/2)
+}
+
+func OutSine(t float64) float64 {
+	return math.Sin(t * math.Pi / 2)
+}
+
+func InOutSine(t float64) float64 {
+	return 0.5 - 0.5*math.Cos(t*math.Pi)
+}
+
+func InExpo(t float64) float64 {
+	if t <= 0 {
+		return 0
+	}
+	return math.Pow(2, 10*(t-1))
+}
+
+func OutExpo(t float64) float64 {
+	if t >= 1 {
+		return 1
+	}
+	return 1 - math.Pow(2, -10*t)
+}
+
+// OutBounce falls to 1 and bounces off it three times, each bounce smaller
+// than the last.
+func OutBounce(t float64) float64 {
+	const n, d = 7.5625, 2.75
+	switch {
+	case t < 1/d:
+		return n * t * t
+	case t < 2/d:
+		t -= 1.5 / d
+		return n*t*t + 0.75
+	case t < 2.5/d:
+		t -= 2.25 / d
+		return n*t*t + 0.9375
+	default:
+		t -= 2.625 / d
+		return n*t*t + 0.984375
+	}
+}
+
+func InBounce(t float64) float64 {
+	return 1 - OutBounce(1-t)
+}
+
+func InOutBounce(t float64) float64 {
+	if t < 0.5 {
+		return 0.5 * InBounce(2*t)
+	}
+	return 0.5 + 0.5*OutBounce(2*t-1)
+}
+
+// Named maps the lower case names of the easing functions to them.
+var Named = map[string]Func{
+	"linear":      Linear,
+	"smoothstep":  SmoothStep,
+	"inquad":      InQuad,
+	"outquad":     OutQuad,
+	"inoutquad":   InOutQuad,
+	"incubic":     InCubic,
+	"outcubic":    OutCubic,
+	"inoutcubic":  InOutCubic,
+	"insine":      InSine,
+	"outsine":     OutSine,
+	"inoutsine":   InOutSine,
+	"inexpo":      InExpo,
+	"outexpo":     OutExpo,
+	"inbounce":    InBounce,
+	"outbounce":   OutBounce,
+	"inoutbounce": InOutBounce,
+}
@@ -0,0 +1,78 @@
+package ease
+
+import (
+	"math"
+	"testing"
+)
+
+const epsilon = 1e-9
+
+func TestEndpoints(t *testing.T) {
+	for name, f := range Named {
+		if v := f(0); math.Abs(v) > epsilon {
+			t.Errorf("%s(0) = %v, want 0", name, v)
+		}
+		if v := f(1); math.Abs(v-1) > epsilon {
+			t.Errorf("%s(1) = %v, want 1", name, v)
+		}
+	}
+}
+
+// TestOutMirrorsIn checks that every Out variant is its In variant played
+// backwards, and every InOut variant is symmetric about its midpoint.
+func TestOutMirrorsIn(t *testing.T) {
+	pairs := []struct {
+		name    string
+		in, out Func
+	}{
+		{"quad", InQuad, OutQuad},
+		{"cubic", InCubic, OutCubic},
+		{"sine", InSine, OutSine},
+		{"expo", InExpo, OutExpo},
+		{"bounce", InBounce, OutBounce},
+	}
+	for _, p := range pairs {
+		for i := 0; i <= 100; i++ {
+			x := float64(i) / 100
+			if a, b := p.out(x), 1-p.in(1-x); math.Abs(a-b) > epsilon {
+				t.Errorf("out%s(%v) = %v, want %v", p.name, x, a, b)
+			}
+		}
+	}
+	for _, name := range []string{"smoothstep", "inoutquad", "inoutcubic", "inoutsine", "inoutbounce"} {
+		f := Named[name]
+		for i := 0; i <= 100; i++ {
+			x := float64(i) / 100
+			if a, b := f(x), 1-f(1-x); math.Abs(a-b) > epsilon {
+				t.Errorf("%s(%v) = %v, want %v", name, x, a, b)
+			}
+		}
+	}
+}
+
+// TestMonotonic checks that the curves other than the bounces never go back.
+func TestMonotonic(t *testing.T) {
+	for name, f := range Named {
+		if name == "inbounce" || name == "outbounce" || name == "inoutbounce" {
+			continue
+		}
+		prev := f(0)
+		for i := 1; i <= 1000; i++ {
+			v := f(float64(i) / 1000)
+			if v < prev-epsilon {
+				t.Errorf("%s goes back at %v", name, float64(i)/1000)
+				break
+			}
+			prev = v
+		}
+	}
+}
+
+func TestOutBounceStaysBelowOne(t *testing.T) {
+	for i := 0; i <= 1000; i++ {
+		x := float64(i) / 1000
+		if v := OutBounce(x); v < 0 || v > 1+epsilon {
+			t.Errorf("OutBounce(%v) = %v, want within [0, 1]", x, v)
+		}
+	}
+}
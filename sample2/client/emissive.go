@@ -0,0 +1,16 @@
+package main
+
+import (
+	"azul3d.org/engine/gfx"
+)
+
+// SetEmissive makes o glow with the given color, added to its shading after
+// everything else. A strength of zero turns the glow off.
+func SetEmissive(o *gfx.Object, c gfx.Color, strength float64) {
+	sh := ownShader(o)
+	sh.Lock()
+	sh.Inputs["Emissive"] = c
+	sh.Inputs["EmissiveStrength"] = float32(strength)
+	sh.Unlock()
+	inputsChanged(o)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/keyboard"
+	"azul3d.org/engine/lmath"
+	"azul3d.org/engine/mouse"
+)
+
+// FlyCamera moves a camera freely with the W, A, S, D, Q and E keys, and turns
+// it while the right mouse button is dragged.
+type FlyCamera struct {
+	// Speed is how fast the camera moves, in units per second.
+	Speed float64
+
+	// LookSpeed is how fast the camera turns, in degrees per pixel dragged.
+	LookSpeed float64
+
+	// Collide, if not nil, is called to resolve each movement of the camera
+	// from pos by move, returning the position the camera ends up at.
+	Collide func(pos, move lmath.Vec3) lmath.Vec3
+
+	mouseLook
+	cam *camera.Camera
+}
+
+func NewFlyCamera(cam *camera.Camera) *FlyCamera {
+	return &FlyCamera{
+		Speed:     2,
+		LookSpeed: 0.25,
+		mouseLook: newMouseLook(),
+		cam:       cam,
+	}
+}
+
+// mouseLook is the sensitivity and vertical inversion of a camera controller
+// turning by drag movement, shared by the fly camera, the arcball and touch
+// orbiting.
+type mouseLook struct {
+	sensX, sensY float64
+	invertY      bool
+}
+
+func newMouseLook() mouseLook {
+	return mouseLook{sensX: 1, sensY: 1}
+}
+
+// SetMouseSensitivity sets how much faster than by default the controller
+// turns horizontally and vertically, the default is 1 for both.
+func (l *mouseLook) SetMouseSensitivity(x, y float64) {
+	l.sensX, l.sensY = x, y
+}
+
+// MouseSensitivity returns the sensitivity set by SetMouseSensitivity.
+func (l *mouseLook) MouseSensitivity() (x, y float64) {
+	return l.sensX, l.sensY
+}
+
+// SetInvertY sets whether dragging up pitches down instead of up.
+func (l *mouseLook) SetInvertY(invert bool) {
+	l.invertY = invert
+}
+
+// InvertY reports whether vertical drag movement is inverted.
+func (l *mouseLook) InvertY() bool {
+	return l.invertY
+}
+
+// turn returns the drag movement dx, dy scaled by the sensitivity, and flipped
+// vertically if inverted.
+func (l *mouseLook) turn(dx, dy float64) (x, y float64) {
+	x, y = dx*l.sensX, dy*l.sensY
+	if l.invertY {
+		y = -y
+	}
+	return x, y
+}
+
+// settings returns the sensitivity and inversion, as they are saved.
+func (l *mouseLook) settings() LookSettings {
+	return LookSettings{SensitivityX: l.sensX, SensitivityY: l.sensY, InvertY: l.invertY}
+}
+
+// setSettings restores the sensitivity and inversion of st.
+func (l *mouseLook) setSettings(st LookSettings) {
+	l.sensX, l.sensY, l.invertY = st.SensitivityX, st.SensitivityY, st.InvertY
+}
+
+// Update turns and moves the camera for a frame lasting dt seconds.
+func (f *FlyCamera) Update(dt float64, kb *keyboard.Watcher, in *InputState) {
+	if in.ButtonDown(mouse.Right) {
+		d := in.CursorDelta()
+		yaw, pitch := f.turn(float64(d.X), float64(d.Y))
+		rot := f.cam.Rot()
+		rot.Z -= yaw * f.LookSpeed
+		rot.X -= pitch * f.LookSpeed
+		if rot.X > 89 {
+			rot.X = 89
+		} else if rot.X < -89 {
+			rot.X = -89
+		}
+		f.cam.SetRot(rot)
+	}
+
+	forward := cameraForward(f.cam)
+	up := lmath.Vec3{0, 0, 1}
+	right := forward.Cross(up).Normalized()
+
+	var move lmath.Vec3
+	if kb.Down(keyboard.W) {
+		move = move.Add(forward)
+	}
+	if kb.Down(keyboard.S) {
+		move = move.Sub(forward)
+	}
+	if kb.Down(keyboard.D) {
+		move = move.Add(right)
+	}
+	if kb.Down(keyboard.A) {
+		move = move.Sub(right)
+	}
+	if kb.Down(keyboard.E) {
+		move = move.Add(up)
+	}
+	if kb.Down(keyboard.Q) {
+		move = move.Sub(up)
+	}
+	if move.LengthSq() == 0 {
+		return
+	}
+	move = move.Normalized().MulScalar(f.Speed * dt)
+
+	pos := f.cam.Pos()
+	if f.Collide != nil {
+		f.cam.SetPos(f.Collide(pos, move))
+	} else {
+		f.cam.SetPos(pos.Add(move))
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/gfx/window"
+	"azul3d.org/engine/keyboard"
+	"azul3d.org/engine/lmath"
+	"azul3d.org/engine/mouse"
+)
+
+// lookCases are mouse settings, and how far a drag of 4 pixels right and 2
+// down should turn a controller with them, in yaw and pitch, as multiples of
+// the turn of the controller per pixel.
+var lookCases = []struct {
+	name       string
+	sensX      float64
+	sensY      float64
+	invert     bool
+	yaw, pitch float64
+}{
+	{"default", 1, 1, false, -4, -2},
+	{"inverted", 1, 1, true, -4, 2},
+	{"twice as sensitive", 2, 2, false, -8, -4},
+	{"sensitive along Y only", 1, 3, false, -4, -6},
+	{"sensitive and inverted", 0.5, 0.5, true, -2, 1},
+}
+
+func TestFlyCameraLook(t *testing.T) {
+	for _, c := range lookCases {
+		cam := camera.New(image.Rect(0, 0, 640, 480))
+		f := NewFlyCamera(cam)
+		f.SetMouseSensitivity(c.sensX, c.sensY)
+		f.SetInvertY(c.invert)
+
+		in := NewInputState()
+		in.Handle(mouse.Event{Button: mouse.Right, State: mouse.Down})
+		in.Handle(window.CursorMoved{X: 4, Y: 2, Delta: true})
+		f.Update(0, keyboard.NewWatcher(), in)
+
+		want := lmath.Vec3{X: c.pitch * f.LookSpeed, Z: c.yaw * f.LookSpeed}
+		if rot := cam.Rot(); math.Abs(rot.X-want.X) > 1e-9 || math.Abs(rot.Z-want.Z) > 1e-9 {
+			t.Errorf("%s: turned to %v, want %v", c.name, rot, want)
+		}
+	}
+}
+
+func TestTouchOrbitLook(t *testing.T) {
+	for _, c := range lookCases {
+		g := &Game{
+			cam:   camera.New(image.Rect(0, 0, 640, 480)),
+			touch: touchGestures{mouseLook: newMouseLook()},
+		}
+		g.cam.SetPos(lmath.Vec3{0, -2, 0})
+		g.touch.SetMouseSensitivity(c.sensX, c.sensY)
+		g.touch.SetInvertY(c.invert)
+		g.touchOrbit(lmath.Vec2{4, 2})
+
+		want := lmath.Vec3{X: c.pitch * 0.3, Z: c.yaw * 0.3}
+		if rot := g.cam.Rot(); math.Abs(rot.X-want.X) > 1e-9 || math.Abs(rot.Z-want.Z) > 1e-9 {
+			t.Errorf("%s: orbited to %v, want %v", c.name, rot, want)
+		}
+	}
+}
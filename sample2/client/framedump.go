@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+
+	"azul3d.org/engine/gfx"
+)
+
+// frameDump writes downloaded frames as numbered PNG files from a background
+// goroutine, so the render loop never waits on the disk.
+type frameDump struct {
+	dir    string
+	max    int
+	queued int
+	frames chan pendingFrame
+	done   chan struct{}
+}
+
+// pendingFrame is a frame whose pixels are still being downloaded.
+type pendingFrame struct {
+	number int
+	img    chan image.Image
+}
+
+func newFrameDump(dir string, maxFrames int) (*frameDump, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f := &frameDump{
+		dir:    dir,
+		max:    maxFrames,
+		frames: make(chan pendingFrame, 8),
+		done:   make(chan struct{}),
+	}
+	go f.write()
+	return f, nil
+}
+
+func (f *frameDump) write() {
+	defer close(f.done)
+	for p := range f.frames {
+		img := <-p.img
+		if img == nil {
+			log.Printf("frame dump: frame %d could not be downloaded\n", p.number)
+			continue
+		}
+		path := filepath.Join(f.dir, fmt.Sprintf("frame_%05d.png", p.number))
+		if err := writePNG(path, img); err != nil {
+			log.Println("frame dump:", err)
+		}
+	}
+}
+
+// capture queues the download of the canvas after what was drawn to it, to be
+// made when it is rendered, and reports whether the frame limit was reached. When the writer falls behind it blocks rather than
+// dropping frames.
+func (f *frameDump) capture(c gfx.Canvas) bool {
+	f.queued++
+	p := pendingFrame{number: f.queued, img: make(chan image.Image, 1)}
+	c.Download(c.Bounds(), p.img)
+	f.frames <- p
+	return f.queued >= f.max
+}
+
+// stop waits for every queued frame to be written.
+func (f *frameDump) stop() {
+	close(f.frames)
+	<-f.done
+}
+
+func writePNG(path string, img image.Image) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(out, img); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// StartFrameDump starts writing every frame to dir as frame_00001.png and so
+// on, until maxFrames were written or StopFrameDump is called.
+func (g *Game) StartFrameDump(dir string, maxFrames int) error {
+	g.StopFrameDump()
+	f, err := newFrameDump(dir, maxFrames)
+	if err != nil {
+		return err
+	}
+	g.frameDump = f
+	return nil
+}
+
+// StopFrameDump stops the running frame dump, once all of its frames are on
+// disk.
+func (g *Game) StopFrameDump() {
+	if g.frameDump == nil {
+		return
+	}
+	g.frameDump.stop()
+	g.frameDump = nil
+}
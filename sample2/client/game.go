@@ -1,187 +1,1689 @@
-package main
-
-import (
-	"image"
-	"log"
-
-	"azul3d.org/engine/gfx"
-	"azul3d.org/engine/gfx/camera"
-	"azul3d.org/engine/gfx/gfxutil"
-	"azul3d.org/engine/gfx/window"
-	"azul3d.org/engine/keyboard"
-	"azul3d.org/engine/lmath"
-
-	"azul3d.org/examples/abs"
-)
-
-type Game struct {
-	cam     *camera.Camera
-	event   chan window.Event
-	rtColor *gfx.Texture
-	card    *gfx.Object
-	scene   *Scene
-}
-
-func NewGame() *Game {
-	return &Game{
-		scene: NewScene(),
-	}
-}
-
-func (g *Game) Init(w window.Window, d gfx.Device) {
-
-	// Create a new perspective (3D) camera.
-	g.cam = camera.New(d.Bounds())
-
-	// Move the camera back two units away from the card.
-	g.cam.SetPos(lmath.Vec3{0, -2, 0})
-
-	// Create a texture to hold the color data of our render-to-texture.
-	g.rtColor = gfx.NewTexture()
-	g.rtColor.MinFilter = gfx.LinearMipmapLinear
-	g.rtColor.MagFilter = gfx.Linear
-
-	// Choose a render to texture format.
-	cfg := d.Info().RTTFormats.ChooseConfig(gfx.Precision{
-		// We want 24/bpp RGB color buffer.
-		RedBits: 8, GreenBits: 8, BlueBits: 8,
-
-		// We could also request a depth or stencil buffer here, by simply
-		// using the lines:
-		// DepthBits: 24,
-		// StencilBits: 24,
-	}, true)
-
-	// Print the configuration we chose.
-	log.Printf("RTT ColorFormat=%v, DepthFormat=%v, StencilFormat=%v\n", cfg.ColorFormat, cfg.DepthFormat, cfg.StencilFormat)
-
-	// Color buffer will go into our rtColor texture.
-	cfg.Color = g.rtColor
-
-	// We will render to a 512x512 area.
-	cfg.Bounds = image.Rect(0, 0, 512, 512)
-
-	// Create our render-to-texture canvas.
-	rtCanvas := d.RenderToTexture(cfg)
-	if rtCanvas == nil {
-		// Important! Check if the canvas is nil. If it is their graphics
-		// hardware doesn't support render to texture. Sorry!
-		log.Fatal("Graphics hardware does not support render to texture.")
-	}
-
-	// Read the GLSL shaders from disk.
-	shader, err := gfxutil.OpenShader(abs.Path("azul3d_rtt/rtt"))
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// Create a card mesh.
-	cardMesh := gfx.NewMesh()
-	cardMesh.Vertices = []gfx.Vec3{
-		// Bottom-left triangle.
-		{-1, 0, -1},
-		{1, 0, -1},
-		{-1, 0, 1},
-
-		// Top-right triangle.
-		{-1, 0, 1},
-		{1, 0, -1},
-		{1, 0, 1},
-	}
-	cardMesh.TexCoords = []gfx.TexCoordSet{
-		{
-			Slice: []gfx.TexCoord{
-				{0, 1},
-				{1, 1},
-				{0, 0},
-
-				{0, 0},
-				{1, 1},
-				{1, 0},
-			},
-		},
-	}
-
-	// Create a card object.
-	g.card = gfx.NewObject()
-	g.card.State = gfx.NewState()
-	g.card.FaceCulling = gfx.NoFaceCulling
-	g.card.AlphaMode = gfx.AlphaToCoverage
-	g.card.Shader = shader
-	g.card.Textures = []*gfx.Texture{g.rtColor}
-	g.card.Meshes = []*gfx.Mesh{cardMesh}
-
-	// Create an event mask for the events we are interested in.
-	evMask := window.FramebufferResizedEvents
-	evMask |= window.KeyboardTypedEvents
-
-	// Create a channel of events.
-	g.event = make(chan window.Event, 256)
-
-	// Have the window notify our channel whenever events occur.
-	w.Notify(g.event, evMask)
-
-	// Draw some colored stripes onto the render to texture canvas. The result
-	// is stored in the rtColor texture, and we can then display it on a card
-	// below without even rendering the stripes every frame.
-	stripeColor1 := gfx.Color{1, 0, 0, 1}   // red
-	stripeColor2 := gfx.Color{1, 0.5, 1, 1} // green
-	stripeWidth := 12                       // pixels
-	flipColor := false
-	b := rtCanvas.Bounds()
-	for i := 0; (i * stripeWidth) < b.Dx(); i++ {
-		flipColor = !flipColor
-		x := i * stripeWidth
-		dst := image.Rect(x, b.Min.Y, x+stripeWidth, b.Max.Y)
-		if flipColor {
-			rtCanvas.Clear(dst, stripeColor1)
-		} else {
-			rtCanvas.Clear(dst, stripeColor2)
-		}
-	}
-
-	// Render the rtCanvas to the rtColor texture.
-	rtCanvas.Render()
-}
-
-func (g *Game) Update(w window.Window, d gfx.Device) {
-
-	// Handle each pending event.
-	window.Poll(g.event, func(e window.Event) {
-		switch ev := e.(type) {
-		case window.FramebufferResized:
-			// Update the camera's projection matrix for the new width and
-			// height.
-			g.cam.Update(d.Bounds())
-
-		case keyboard.Typed:
-			if ev.S == "m" || ev.S == "M" {
-				// Toggle mipmapping.
-				if g.rtColor.MinFilter == gfx.LinearMipmapLinear {
-					g.rtColor.MinFilter = gfx.Linear
-				} else {
-					g.rtColor.MinFilter = gfx.LinearMipmapLinear
-				}
-			}
-		}
-	})
-
-	// Rotate the card on the Z axis 15 degrees/sec.
-	//		rot := card.Rot()
-	//		card.SetRot(lmath.Vec3{
-	//			X: rot.X,
-	//			Y: rot.Y,
-	//			Z: rot.Z + (15 * d.Clock().Dt()),
-	//		})
-
-	// Clear color and depth buffers.
-	d.Clear(d.Bounds(), gfx.Color{1, 1, 1, 1})
-	d.ClearDepth(d.Bounds(), 1.0)
-
-	// Draw the card.
-	d.Draw(d.Bounds(), g.card, g.cam)
-
-	// Render the frame.
-	d.Render()
-
-}
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"math"
+	"os"
+	"strings"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/gfx/gfxutil"
+	"azul3d.org/engine/gfx/window"
+	"azul3d.org/engine/keyboard"
+	"azul3d.org/engine/lmath"
+	"azul3d.org/engine/mouse"
+
+	"azul3d.org/examples/abs"
+
+	"github.com/mypianoplayer/ragtime_sample/sample2/client/ease"
+)
+
+type Game struct {
+	// CameraEasing shapes the camera moves of FocusOn and ViewFrom.
+	CameraEasing ease.Func
+
+	w       window.Window
+	d       gfx.Device
+	cam     *camera.Camera
+	event   chan window.Event
+	rtColor *gfx.Texture
+	card    *gfx.Object
+	scene   *Scene
+	input   *InputState
+	hud     *HUD
+	fps     *Label
+	fpsTime float64
+
+	bounds   image.Rectangle
+	tween    CameraTween
+	selected *gfx.Object
+
+	morph *MorphMesh
+	time  float64
+
+	stats     RenderStats
+	statsLog  *statsLog
+	statsAgg  *StatsAggregator
+	timers    *GPUTimers
+	frameDump *frameDump
+
+	// reflectStats are the statistics of the reflection pass of the frame,
+	// kept apart as its mirrored views cull on their own.
+	reflectStats RenderStats
+
+	// benchLeft is how many seconds of the running benchmark are left.
+	benchLeft float64
+
+	// sceneWatch applies the changes of the scene file, while set.
+	sceneWatch *sceneWatcher
+
+	// video pipes the frames to an encoder while set, dropping them when it
+	// falls behind if videoDrop is set.
+	video     *videoCapture
+	videoDrop bool
+
+	fly     *FlyCamera
+	post    *PostProcess
+	arcball *Arcball
+	drag    *TranslateDrag
+	navCube *NavCube
+	ruler   *Ruler
+	stereo  *Stereo
+	sky     *Sky
+	skyOn   bool
+
+	// arcballLook is the mouse settings of the arcball, kept while there is
+	// none.
+	arcballLook LookSettings
+
+	// parallax are the background layers drawn over the sky while
+	// parallaxOn is set, from the furthest.
+	parallax        []*ParallaxLayer
+	parallaxSources *gfx.GLSLSources
+	parallaxCam     *camera.Camera
+	parallaxOn      bool
+
+	shaders *ShaderLibrary
+	light   *PointLight
+	gizmo   *gfx.Object
+	moon    *gfx.Object
+
+	// label lies flat on the card, kept in front of it by a clip space Z
+	// bias.
+	label *gfx.Object
+
+	// occluder is the wall the hidden cube is skipped behind.
+	occluder *gfx.Object
+
+	floorTex   [2]*gfx.Texture
+	reflection *PlanarReflection
+
+	threaded *ThreadedLoop
+	random   *FrameRandom
+	viewport *viewportRegion
+	streamer *TextureStreamer
+
+	// smoke is the particle system drifting over the floor, and meadow the
+	// grass scattered from the random seed.
+	smoke  *ParticleSystem
+	meadow *InstancedObject
+
+	// bloom is drawn by the passes of graph, after the scene.
+	bloom *Bloom
+	graph *RenderGraph
+
+	// overdraw replaces the scene by its overdraw heatmap, while set.
+	overdraw *overdrawView
+
+	// orthoHeight is the height of the view in world units, while the
+	// camera is orthographic.
+	orthoHeight float64
+
+	vrsSupported bool
+	shadingRate  ShadingRate
+	clipMode     int
+	pixelateHUD  bool
+	grading      int
+	mipForced    bool
+	wireframe    bool
+	additive     bool
+
+	swing bool
+	orbit bool
+
+	jumpZ, jumpVel float64
+
+	// sim is the 30Hz fixed step moving the card while simMode is not
+	// simOff.
+	sim     *FixedStep
+	simMode int
+	simTime float64
+
+	history *History
+
+	touch     touchGestures
+	touchSeen bool
+
+	box         boxSelect
+	boxSelected []*gfx.Object
+
+	contexts []InputContext
+	console  *Console
+
+	// nameField renames the selected object, focusedField is the text field
+	// that has the keyboard, if any.
+	nameField    *TextField
+	focusedField *TextField
+
+	icon        image.Image
+	badgeNotice bool
+}
+
+func NewGame() *Game {
+	return &Game{
+		CameraEasing: ease.SmoothStep,
+		scene:        NewScene(),
+		history:      NewHistory(),
+		sim:          NewFixedStep(30),
+		streamer:     NewTextureStreamer(64),
+		input:        NewInputState(),
+		random:       NewFrameRandom(1),
+		arcballLook:  newMouseLook().settings(),
+		touch:        touchGestures{mouseLook: newMouseLook()},
+	}
+}
+
+func (g *Game) Init(w window.Window, d gfx.Device) {
+	g.w, g.d = w, d
+	g.contexts = []InputContext{g}
+	g.scene.OnRemove = g.forget
+	if g.icon != nil {
+		g.SetWindowIcon(g.icon)
+	}
+
+	// Create a new perspective (3D) camera.
+	g.cam = camera.New(d.Bounds())
+	g.bounds = d.Bounds()
+
+	// Move the camera back two units away from the card.
+	g.cam.SetPos(lmath.Vec3{0, -2, 0})
+
+	// Let the camera fly around, stopping in front of the objects it runs
+	// into.
+	g.fly = NewFlyCamera(g.cam)
+	g.SetCameraCollision(true, 0.2)
+
+	// Let the selected object be moved by dragging it with control held,
+	// landing on a grid a quarter of a unit wide.
+	g.drag = NewTranslateDrag(g.cam)
+	g.drag.Snap = 0.25
+	g.drag.OnDragStart = func(target *gfx.Object) {
+		g.history.Record(g.selected, target)
+	}
+
+	// Restore the camera from the last run, if it was saved.
+	if err := g.LoadCameraState("camera.json"); err != nil && !os.IsNotExist(err) {
+		log.Println(err)
+	}
+
+	// Ask for the background to be shaded at a coarser rate than the card.
+	g.vrsSupported = hasExtension(d.Info(), shadingRateExtension)
+	g.SetShadingRate(ShadingRate2x2)
+
+	// Time the render passes on the GPU, where the device allows it.
+	g.timers = NewGPUTimers(d)
+	g.statsAgg = NewStatsAggregator(defaultStatsWindow)
+
+	// Create a texture to hold the color data of our render-to-texture.
+	g.rtColor = gfx.NewTexture()
+	g.rtColor.MinFilter = gfx.LinearMipmapLinear
+	g.rtColor.MagFilter = gfx.Linear
+
+	// Choose a render to texture format.
+	cfg := d.Info().RTTFormats.ChooseConfig(gfx.Precision{
+		// We want 24/bpp RGB color buffer.
+		RedBits: 8, GreenBits: 8, BlueBits: 8,
+
+		// We could also request a depth or stencil buffer here, by simply
+		// using the lines:
+		// DepthBits: 24,
+		// StencilBits: 24,
+	}, true)
+
+	// Print the configuration we chose.
+	log.Printf("RTT ColorFormat=%v, DepthFormat=%v, StencilFormat=%v\n", cfg.ColorFormat, cfg.DepthFormat, cfg.StencilFormat)
+
+	// Color buffer will go into our rtColor texture.
+	cfg.Color = g.rtColor
+
+	// We will render to a 512x512 area.
+	cfg.Bounds = image.Rect(0, 0, 512, 512)
+
+	// Create our render-to-texture canvas.
+	rtCanvas := d.RenderToTexture(cfg)
+	if rtCanvas == nil {
+		// Important! Check if the canvas is nil. If it is their graphics
+		// hardware doesn't support render to texture. Sorry!
+		log.Fatal("Graphics hardware does not support render to texture.")
+	}
+
+	// Read the GLSL shaders from disk.
+	shader, err := gfxutil.OpenShader(abs.Path("azul3d_rtt/rtt"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Load the shaders scene objects are drawn with in the background, so
+	// that the first frame is not held up by them.
+	g.shaders = NewShaderLibrary(d)
+	g.shaders.OnLoad = func(name string, sh *gfx.Shader) {
+		g.scene.NameShader(name, sh)
+
+		// The objects of the scene file are drawn with it.
+		if name == "scene" {
+			g.WatchSceneFile("scene.json")
+		}
+	}
+	g.shaders.LoadAsync("scene", "scene")
+
+	// Read the post processing shaders from disk.
+	postShader, err := gfxutil.OpenShader("post")
+	if err != nil {
+		log.Fatal(err)
+	}
+	g.post = NewPostProcess(postShader)
+
+	// Read the shaders combining the eyes of anaglyph stereo from disk.
+	anaglyphShader, err := gfxutil.OpenShader("anaglyph")
+	if err != nil {
+		log.Fatal(err)
+	}
+	g.stereo = NewStereo(anaglyphShader)
+
+	// Read the shaders of the sky gradient from disk.
+	skyShader, err := gfxutil.OpenShader("sky")
+	if err != nil {
+		log.Fatal(err)
+	}
+	g.sky = NewSky(skyShader)
+
+	// Read the shaders of the parallax background layers from disk, and
+	// layer far mountains, hills and near bushes over the sky.
+	parallaxShader, err := gfxutil.OpenShader("parallax")
+	if err != nil {
+		log.Fatal(err)
+	}
+	g.parallaxSources = parallaxShader.GLSL
+	g.parallaxOn = true
+	g.AddParallaxLayer(silhouetteTexture(image.Pt(512, 128), color.RGBA{150, 165, 190, 255}, 120, 2), 0.05)
+	hills := g.AddParallaxLayer(silhouetteTexture(image.Pt(512, 128), color.RGBA{90, 120, 100, 230}, 90, 3), 0.3)
+	hills.Repeat = 1.5
+	bushes := g.AddParallaxLayer(silhouetteTexture(image.Pt(512, 64), color.RGBA{40, 70, 40, 220}, 50, 6), 0.7)
+	bushes.Repeat = 2
+
+	// Read the bloom shaders from disk, and wire the bloom after the scene.
+	bloomShader, err := gfxutil.OpenShader("bloom")
+	if err != nil {
+		log.Fatal(err)
+	}
+	g.bloom = NewBloom(bloomShader)
+	g.graph = NewRenderGraph()
+	g.bloom.AddPasses(g.graph, "scene")
+	g.graph.AddPass(&RenderPass{
+		Name:   "scene",
+		Output: "scene",
+		Run: func(c gfx.Canvas, _ []*gfx.Texture) {
+			g.drawScene(d, c)
+		},
+	})
+	if err := g.graph.Compile(); err != nil {
+		log.Fatal(err)
+	}
+	var order []string
+	for _, p := range g.graph.Order() {
+		order = append(order, p.Name)
+	}
+	log.Printf("Render graph: %s, in %d textures\n", strings.Join(order, ", "), g.graph.Textures())
+
+	// Create a card mesh.
+	cardMesh := gfx.NewMesh()
+	cardMesh.Vertices = []gfx.Vec3{
+		// Bottom-left triangle.
+		{-1, 0, -1},
+		{1, 0, -1},
+		{-1, 0, 1},
+
+		// Top-right triangle.
+		{-1, 0, 1},
+		{1, 0, -1},
+		{1, 0, 1},
+	}
+	cardMesh.TexCoords = []gfx.TexCoordSet{
+		{
+			Slice: []gfx.TexCoord{
+				{0, 1},
+				{1, 1},
+				{0, 0},
+
+				{0, 0},
+				{1, 1},
+				{1, 0},
+			},
+		},
+	}
+
+	// Split the card into many small triangles, so that it bends smoothly.
+	cardMesh = TessellateMesh(cardMesh, 3)
+
+	cardMesh.Normals = computeNormals(cardMesh.Vertices, nil, nil)
+
+	// Let the card bend towards the camera along its left and right edges.
+	g.morph = NewMorphMesh(cardMesh)
+	bent := append([]gfx.Vec3(nil), cardMesh.Vertices...)
+	for i := range bent {
+		bent[i].Y = -0.5 * bent[i].X * bent[i].X
+	}
+	if err := g.morph.AddTarget("bent", bent); err != nil {
+		log.Fatal(err)
+	}
+
+	// Create a card object.
+	g.card = gfx.NewObject()
+	g.card.State = gfx.NewState()
+	g.card.FaceCulling = gfx.NoFaceCulling
+	g.card.AlphaMode = gfx.AlphaToCoverage
+	g.shaders.Use(g.card, "scene")
+	g.card.Textures = []*gfx.Texture{g.rtColor}
+	g.card.Meshes = []*gfx.Mesh{cardMesh}
+	// Spin the card while it is selected.
+	cardAnim := NewAnimStateMachine(g.card)
+	cardAnim.AddState("idle", UpdaterFunc(func(o *gfx.Object, dt float64) {}))
+	cardAnim.AddState("spin", &Spin{Speed: 90})
+	g.scene.Add(&Object{Object: g.card, Name: "card", Anim: cardAnim})
+	g.scene.NameTexture("stripes", g.rtColor)
+
+	// Light the scene from above the camera, under a white to blue sky with
+	// the sun disc where the light comes from, and with a point light
+	// orbiting the card.
+	g.scene.Sun = &DirectionalLight{
+		Dir:     lmath.Vec3{0, 1, -1},
+		Color:   gfx.Color{0.4, 0.4, 0.4, 1},
+		Ambient: gfx.Color{0.4, 0.4, 0.4, 1},
+	}
+	g.SetSkyGradient(gfx.Color{1, 1, 1, 1}, gfx.Color{0.25, 0.45, 0.85, 1})
+	g.SetSunDirection(lmath.Vec3{0, 1, -1})
+	g.light = NewPointLight(lmath.Vec3{}, gfx.Color{1, 0.8, 0.5, 1}, 2.5)
+	g.scene.AddLight(g.light)
+
+	// Stick a logo onto the upper right of the card, from logo.png if there
+	// is one. The projector follows the card as it moves.
+	logo, err := LoadImage("logo.png")
+	if err != nil {
+		logo = logoImage(128)
+	}
+	logoTex := gfx.NewTexture()
+	logoTex.Source = logo
+	logoTex.MinFilter = gfx.LinearMipmapLinear
+	logoTex.MagFilter = gfx.Linear
+	logoTex.WrapU = gfx.Clamp
+	logoTex.WrapV = gfx.Clamp
+	decal := NewDecal(logoTex)
+	decal.SetParent(g.card.Transform)
+	decal.SetPos(lmath.Vec3{0.4, 0, 0.4})
+	decal.SetScale(lmath.Vec3{0.8, 2, 0.8})
+	g.scene.AddDecal(decal)
+
+	// Lay a checkered floor of frozen tiles under the card, which the scene
+	// draws as one batch per color, and mirror the scene in it.
+	g.reflection = NewPlanarReflection()
+	g.reflection.SetPlane(lmath.Vec3{0, 0, 1}, 1.2)
+	g.reflection.Reflectivity = 0.35
+	g.scene.AddReflection(g.reflection)
+	g.addFloor()
+
+	// Let the sun cast shadows, and mark the point light with a glowing cube
+	// which is only a debugging aid, so neither casts nor receives them.
+	g.scene.Shadows = true
+	g.gizmo = newCube(0.1)
+	g.shaders.Use(g.gizmo, "scene")
+	g.gizmo.Textures = []*gfx.Texture{g.floorTex[0]}
+	SetEmissive(g.gizmo, g.light.Color, 1)
+	SetCastShadow(g.gizmo, false)
+	SetReceiveShadow(g.gizmo, false)
+	g.scene.Add(&Object{Object: g.gizmo, Name: "light gizmo"})
+
+	// Attach a small card to the card, orbiting it and following it as it
+	// swings or spins.
+	moonMesh := gfx.NewMesh()
+	moonMesh.Vertices = []gfx.Vec3{
+		{-0.5, 0, -0.5}, {0.5, 0, -0.5}, {-0.5, 0, 0.5},
+		{-0.5, 0, 0.5}, {0.5, 0, -0.5}, {0.5, 0, 0.5},
+	}
+	moonMesh.Normals = computeNormals(moonMesh.Vertices, nil, nil)
+	moonMesh.TexCoords = []gfx.TexCoordSet{{Slice: make([]gfx.TexCoord, 6)}}
+	g.moon = gfx.NewObject()
+	g.moon.State = gfx.NewState()
+	g.moon.FaceCulling = gfx.NoFaceCulling
+	g.shaders.Use(g.moon, "scene")
+	g.moon.Textures = []*gfx.Texture{g.floorTex[1]}
+	g.moon.Meshes = []*gfx.Mesh{moonMesh}
+	g.moon.SetScale(lmath.Vec3{0.3, 0.3, 0.3})
+	if err := SetParent(g.moon, g.card); err != nil {
+		log.Fatal(err)
+	}
+	g.scene.Add(&Object{Object: g.moon, Name: "moon"})
+
+	// Lay a label on the lower left of the card, in its plane. Drawn at the
+	// same depth they would fight for the pixels; the bias keeps the label in
+	// front, and culling its back faces hides it behind the card.
+	labelText := NewTextRenderer()
+	labelText.Color = gfx.Color{0.1, 0.1, 0.3, 1}
+	labelText.OutlineColor = gfx.Color{1, 1, 1, 1}
+	labelText.OutlineWidth = 1
+	labelText.Scale = 4
+	labelImg := labelText.Render("RAGTIME")
+	labelTex := gfx.NewTexture()
+	labelTex.Source = labelImg
+	labelTex.Bounds = labelImg.Bounds()
+	labelTex.MinFilter = gfx.LinearMipmapLinear
+	labelTex.MagFilter = gfx.Linear
+	labelTex.WrapU = gfx.Clamp
+	labelTex.WrapV = gfx.Clamp
+	labelH := 0.8 * float64(labelImg.Bounds().Dy()) / float64(labelImg.Bounds().Dx())
+	g.label = newQuad(nil)
+	g.label.FaceCulling = gfx.BackFaceCulling
+	g.shaders.Use(g.label, "scene")
+	g.label.Meshes[0].Normals = computeNormals(g.label.Meshes[0].Vertices, nil, nil)
+	g.label.Textures = []*gfx.Texture{labelTex}
+	g.label.SetPos(lmath.Vec3{-0.9, 0, -0.9})
+	g.label.SetScale(lmath.Vec3{0.8, 1, labelH})
+	if err := SetParent(g.label, g.card); err != nil {
+		log.Fatal(err)
+	}
+	SetClipZBias(g.label, 0.00005)
+	g.scene.Add(&Object{Object: g.label, Name: "label"})
+
+	// Add a panel to the side with fewer triangles further away, drawn with
+	// its wireframe to show them. The margin keeps it from flickering
+	// between levels while the camera hovers around 4 or 8 units away.
+	panelMesh := gfx.NewMesh()
+	panelMesh.Vertices = []gfx.Vec3{
+		{-0.5, 0, -0.5}, {0.5, 0, -0.5}, {-0.5, 0, 0.5},
+		{-0.5, 0, 0.5}, {0.5, 0, -0.5}, {0.5, 0, 0.5},
+	}
+	panelMesh.Normals = computeNormals(panelMesh.Vertices, nil, nil)
+	panelMesh.TexCoords = []gfx.TexCoordSet{{Slice: []gfx.TexCoord{
+		{0, 1}, {1, 1}, {0, 0},
+		{0, 0}, {1, 1}, {1, 0},
+	}}}
+	panel := gfx.NewObject()
+	panel.State = gfx.NewState()
+	panel.FaceCulling = gfx.NoFaceCulling
+	g.shaders.Use(panel, "scene")
+	// Its large texture is streamed, keeping only the mip levels needed.
+	g.streamer.Stream(panel, logoImage(1024))
+	panel.Meshes = []*gfx.Mesh{TessellateMesh(panelMesh, 3)}
+	panel.SetPos(lmath.Vec3{2.5, 1, 0})
+	SetBarycentricWireframe(panel, gfx.Color{0, 0, 0, 1}, 1)
+	lod := NewLODObject(panel)
+	for i, dist := range []float64{4, 8} {
+		m := TessellateMesh(panelMesh, 1-i)
+		addBarycentrics(m)
+		lod.AddLevel(dist, []*gfx.Mesh{m})
+	}
+	lod.SetHysteresis(0.25)
+	lod.OnChange = func(level int) {
+		log.Println("Panel level of detail", level)
+	}
+	g.scene.AddLOD(lod)
+	g.scene.Add(&Object{Object: panel, Name: "panel"})
+
+	// Stand a tall card on the floor that sways in the wind from its top,
+	// while its base stays put.
+	reedMesh := gfx.NewMesh()
+	reedMesh.Vertices = []gfx.Vec3{
+		{-0.15, 0, 0}, {0.15, 0, 0}, {-0.15, 0, 2},
+		{-0.15, 0, 2}, {0.15, 0, 0}, {0.15, 0, 2},
+	}
+	reedMesh.TexCoords = []gfx.TexCoordSet{{Slice: make([]gfx.TexCoord, 6)}}
+	reedMesh = TessellateMesh(reedMesh, 3)
+	reedMesh.Normals = computeNormals(reedMesh.Vertices, nil, nil)
+	reed := gfx.NewObject()
+	reed.State = gfx.NewState()
+	reed.FaceCulling = gfx.NoFaceCulling
+	g.shaders.Use(reed, "scene")
+	reed.Textures = []*gfx.Texture{g.floorTex[1]}
+	reed.Meshes = []*gfx.Mesh{reedMesh}
+	reed.SetPos(lmath.Vec3{-2, 1, -1.2})
+	SetWindSway(reed, 1)
+	g.scene.Add(&Object{Object: reed, Name: "reed"})
+	g.SetWind(lmath.Vec3{1, 0, 0}, 0.3, 0.5)
+	g.scene.Wind.BendNormals = true
+
+	// Hang a translucent backdrop behind the card. Its center can be nearer
+	// than parts of the scene in front of it, so it is forced to sort first
+	// rather than by distance.
+	backdrop := gfx.NewObject()
+	backdrop.State = gfx.NewState()
+	g.shaders.Use(backdrop, "scene")
+	backdrop.Textures = []*gfx.Texture{logoTex}
+	backdrop.Meshes = []*gfx.Mesh{panelMesh}
+	SetMaterial(backdrop, MaterialTransparent())
+	backdrop.SetScale(lmath.Vec3{6, 1, 3})
+	backdrop.SetPos(lmath.Vec3{0, 1.5, 0.3})
+	SetSortDistance(backdrop, math.Inf(1), true)
+	g.scene.Add(&Object{Object: backdrop, Name: "backdrop"})
+
+	// Stack three translucent cards to the left, which the overdraw view
+	// shows hotter where more of them overlap.
+	for i := 0; i < 3; i++ {
+		o := gfx.NewObject()
+		o.State = gfx.NewState()
+		g.shaders.Use(o, "scene")
+		o.Textures = []*gfx.Texture{logoTex}
+		o.Meshes = []*gfx.Mesh{panelMesh}
+		SetMaterial(o, MaterialTransparent())
+		o.SetPos(lmath.Vec3{-2.5 + 0.3*float64(i), 0.5 + 0.2*float64(i), 0.3 * float64(i)})
+		g.scene.Add(&Object{Object: o, Name: fmt.Sprintf("glass%d", i)})
+	}
+
+	// Put a small cube behind a wall sliding back and forth in the distance,
+	// which it is skipped behind while occlusion culling is on.
+	g.occluder = newCube(1)
+	g.shaders.Use(g.occluder, "scene")
+	g.occluder.Textures = []*gfx.Texture{g.floorTex[0]}
+	g.occluder.SetScale(lmath.Vec3{3, 0.2, 2})
+	g.occluder.SetPos(lmath.Vec3{0, 6, 0})
+	g.scene.Add(&Object{Object: g.occluder, Name: "wall"})
+	hidden := newCube(0.5)
+	g.shaders.Use(hidden, "scene")
+	hidden.Textures = []*gfx.Texture{g.floorTex[1]}
+	hidden.SetPos(lmath.Vec3{0, 8, 0})
+	g.scene.Add(&Object{Object: hidden, Name: "hidden cube"})
+	if d.Info().OcclusionQuery {
+		g.scene.SetOcclusionCulling(true)
+	} else {
+		log.Println("Occlusion culling disabled: occlusion queries are not supported.")
+	}
+
+	// Let the scene file place cubes on the floor, applying its changes as
+	// it is edited.
+	g.scene.NameMesh("cube", newCube(1).Meshes[0])
+
+	// Tint the object under the cursor, before it is clicked.
+	g.scene.SetHoverHighlight(true)
+
+	// Cull through a spatial index rather than testing every floor tile.
+	g.scene.BuildSpatialIndex()
+
+	// Leave a trail behind the card when it moves.
+	g.scene.AddTrail(g.card, 32, gfx.Color{1, 0.5, 0, 0.8})
+
+	// Let smoke drift low across the floor, fading where it meets it
+	// rather than being cut along it.
+	g.smoke = g.scene.AddParticles(lmath.Vec3{1.2, -1, -1.3}, 64, g.random)
+	g.smoke.Rate = 12
+	g.smoke.Life = 4
+	g.smoke.Velocity = lmath.Vec3{-0.2, 0.1, 0.15}
+	g.smoke.Spread = 0.1
+	g.smoke.Size = 0.5
+	g.smoke.Growth = 0.25
+	g.smoke.Color = gfx.Color{0.4, 0.4, 0.45, 0.6}
+	g.smoke.SetSoftness(0.3)
+
+	// Scatter grass cards around the floor from a point file.
+	g.addMeadow("meadow.xyz")
+
+	// Plant a forest of crossed cards far behind the wall, drawn as
+	// imposters from 10 units away.
+	g.addForest(logoTex)
+
+	// Create the HUD with an outlined FPS counter, so that it stays readable
+	// over both the white background and dark objects.
+	g.hud = NewHUD(d.Bounds(), shader)
+	fpsText := NewTextRenderer()
+	fpsText.Color = gfx.Color{1, 1, 1, 1}
+	fpsText.OutlineColor = gfx.Color{0, 0, 0, 1}
+	fpsText.OutlineWidth = 1
+	fpsText.ShadowOffset = image.Pt(1, 1)
+	fpsText.Scale = dpiScale(w)
+	g.fps = g.hud.AddLabel(image.Pt(8, 8), fpsText)
+
+	// Keep the HUD the same fraction of the window height as it is at the
+	// starting size, except for the console which stays readable at a fixed
+	// pixel size.
+	g.hud.SetScaleMode(ScaleWithHeight, d.Bounds().Size())
+
+	consoleText := NewTextRenderer()
+	consoleText.Color = fpsText.Color
+	consoleText.OutlineColor = fpsText.OutlineColor
+	consoleText.OutlineWidth = 1
+	consoleText.Scale = dpiScale(w)
+	consoleLabel := g.hud.AddLabel(image.Pt(8, 28), consoleText)
+	consoleLabel.ScaleMode = ScalePixel
+	g.console = NewConsole(g, consoleLabel)
+
+	// Rename the selected object from a field in the bottom left corner.
+	g.nameField = g.hud.AddTextField(AnchorBottomLeft, image.Pt(8, 8), 200)
+	g.nameField.Renderer.Scale = dpiScale(w)
+	g.nameField.OnSubmit = func(name string) {
+		if o := g.scene.object(g.selected); o != nil {
+			log.Printf("Renamed %q to %q\n", o.Name, name)
+			o.Name = name
+		}
+		g.FocusTextField(nil)
+	}
+
+	rulerText := NewTextRenderer()
+	rulerText.Scale = dpiScale(w)
+	g.ruler = NewRuler(shader, rulerText)
+
+	// Create the navigation cube, in the corner of the screen.
+	cubeText := NewTextRenderer()
+	cubeText.Scale = dpiScale(w)
+	g.navCube = NewNavCube(shader, cubeText, d.Bounds())
+
+	// Create an event mask for the events we are interested in.
+	evMask := window.FramebufferResizedEvents
+	evMask |= window.KeyboardTypedEvents
+	evMask |= window.MouseEvents
+	evMask |= window.CursorMovedEvents
+	evMask |= window.KeyboardButtonEvents
+	evMask |= window.CloseEvents
+
+	// Create a channel of events.
+	g.event = make(chan window.Event, 256)
+
+	// Have the window notify our channel whenever events occur.
+	w.Notify(g.event, evMask)
+
+	// Draw some colored stripes onto the render to texture canvas. The result
+	// is stored in the rtColor texture, and we can then display it on a card
+	// below without even rendering the stripes every frame.
+	stripeColor1 := gfx.Color{1, 0, 0, 1}   // red
+	stripeColor2 := gfx.Color{1, 0.5, 1, 1} // green
+	stripeWidth := 12                       // pixels
+	flipColor := false
+	b := rtCanvas.Bounds()
+	for i := 0; (i * stripeWidth) < b.Dx(); i++ {
+		flipColor = !flipColor
+		x := i * stripeWidth
+		dst := image.Rect(x, b.Min.Y, x+stripeWidth, b.Max.Y)
+		if flipColor {
+			rtCanvas.Clear(dst, stripeColor1)
+		} else {
+			rtCanvas.Clear(dst, stripeColor2)
+		}
+	}
+
+	// Render the rtCanvas to the rtColor texture.
+	rtCanvas.Render()
+}
+
+func (g *Game) Update(w window.Window, d gfx.Device) {
+	g.random.BeginFrame()
+	g.input.BeginFrame(d.Clock().Dt())
+
+	// Handle each pending event, topmost input context first.
+	window.Poll(g.event, func(e window.Event) {
+		for i := len(g.contexts) - 1; i >= 0; i-- {
+			if g.contexts[i].HandleEvent(e) {
+				break
+			}
+		}
+	})
+
+	// Clicking selects the object under the cursor, double clicking also
+	// frames it in the view, dragging with shift held selects everything
+	// inside a box and with control held moves the selected object.
+	shift := w.Keyboard().Down(keyboard.LeftShift) || w.Keyboard().Down(keyboard.RightShift)
+	if g.box.active {
+		g.UpdateBoxSelect(g.input.Cursor())
+		if !g.input.ButtonDown(mouse.Left) {
+			log.Printf("Box selected %d objects\n", len(g.EndBoxSelect()))
+		}
+	} else if p, ok := g.input.Clicked(); ok && shift {
+		g.BeginBoxSelect(p)
+	} else if ok {
+		// Clicking a text field focuses it, clicking anywhere else takes
+		// the focus away.
+		f := g.hud.TextFieldAt(p)
+		g.FocusTextField(f)
+		if f == nil {
+			if n, ok := g.navCube.Click(p); ok {
+				g.ViewFrom(n)
+			} else {
+				g.Select(g.scene.PickByID(d, g.framebufferPoint(p)))
+			}
+		}
+	}
+	if p, ok := g.input.DoubleClicked(); ok {
+		if o := g.scene.Pick(g.cam, d.Bounds(), g.framebufferPoint(p)); o != nil {
+			g.FocusOn(o)
+		}
+	}
+	g.tween.Update(d.Clock().Dt())
+	ctrl := w.Keyboard().Down(keyboard.LeftControl) || w.Keyboard().Down(keyboard.RightControl)
+	if !g.drag.Update(d.Bounds(), g.input, g.selected, ctrl) && g.arcball != nil {
+		g.arcball.Update(d.Bounds(), g.input)
+	}
+	if !g.tween.Active() && !g.keyboardCaptured() {
+		g.fly.Update(d.Clock().Dt(), w.Keyboard(), g.input)
+	}
+
+	// Morph the card back and forth between flat and bent.
+	g.time += d.Clock().Dt()
+	g.morph.SetWeight("bent", 0.5-0.5*math.Cos(g.time))
+	g.morph.Update()
+	if g.swing {
+		g.card.SetRot(lmath.Vec3{0, 0, 45 * math.Sin(g.time)})
+	}
+	if g.orbit {
+		g.card.SetPos(lmath.Vec3{math.Cos(2 * g.time), 0, math.Sin(2 * g.time)})
+	}
+	if g.simMode == simOff {
+		// Otherwise the card jumps in the simulation steps.
+		g.updateJump(d.Clock().Dt())
+	}
+	g.light.Pos = lmath.Vec3{1.5 * math.Cos(g.time), 1.5 * math.Sin(g.time), 0.5}
+	g.gizmo.SetPos(g.light.Pos)
+	g.occluder.SetPos(lmath.Vec3{4 * math.Sin(0.3*g.time), 6, 0})
+	if Parent(g.moon) != nil {
+		g.moon.SetPos(lmath.Vec3{1.4 * math.Cos(1.5*g.time), -0.1, 1.4 * math.Sin(1.5*g.time)})
+	}
+	if g.threaded != nil {
+		g.threaded.Apply()
+	}
+	if g.simMode != simOff {
+		g.scene.FixedUpdate(g.sim, d.Clock().Dt(), g.simCardStep)
+	}
+	g.shaders.Update()
+	g.checkSceneFile()
+	g.scene.Update(d.Clock().Dt())
+	g.drag.DrawPreview(g.scene.Debug())
+	g.streamer.Update(g.cam, g.bounds)
+
+	// Rotate the card on the Z axis 15 degrees/sec.
+	//		rot := card.Rot()
+	//		card.SetRot(lmath.Vec3{
+	//			X: rot.X,
+	//			Y: rot.Y,
+	//			Z: rot.Z + (15 * d.Clock().Dt()),
+	//		})
+
+	// Draw the scene, after the shadows falling in it, to the screen unless
+	// post processing is enabled. With bloom the render graph draws it to a
+	// texture first.
+	canvas := g.post.Begin(d)
+	t := g.timers
+	t.BeginFrame()
+	t.Begin(t.Shadow)
+	g.scene.RenderShadows(d)
+	g.scene.RenderImposters(d, g.shaders.Waiting)
+	t.End(t.Shadow)
+	g.stats = RenderStats{
+		FrameTime: d.Clock().Dt(),
+		GPUShadow: t.Shadow.GPUTime(),
+		GPUScene:  t.Scene.GPUTime(),
+		GPUPost:   t.Post.GPUTime(),
+	}
+	t.Begin(t.Scene)
+	if g.bloom.Strength > 0 {
+		if err := g.graph.Execute(d, canvas); err != nil {
+			log.Println("Bloom disabled:", err)
+			g.bloom.Strength = 0
+		}
+	} else {
+		g.drawScene(d, canvas)
+	}
+	if g.post.MotionBlur > 0 {
+		// Render the motion of what was just drawn, for the blur.
+		g.post.Velocity = g.scene.RenderVelocity(d, g.cam, canvas.Bounds())
+	}
+	g.scene.UpdateHover(d, g.framebufferPoint(g.input.Cursor()))
+	t.End(t.Scene)
+	g.statsAgg.Add(g.stats)
+	g.updateBenchmark(g.stats.FrameTime)
+	if g.statsLog != nil {
+		g.statsLog.write(g.stats, g.statsAgg.Summary())
+	}
+
+	// Update the FPS counter once a second and draw the HUD over the scene,
+	// pixelated along with it if asked to. The overdraw heatmap is drawn
+	// over the processed scene, so the HUD goes over it unpixelated.
+	g.fpsTime += d.Clock().Dt()
+	if g.fpsTime >= 1 {
+		g.fpsTime = 0
+		g.fps.SetText(fmt.Sprintf("%.0f FPS", d.Clock().FrameRate()) + g.statsSummaryText() + g.gpuTimesText() + g.touchText())
+	}
+	pixelateHUD := g.pixelateHUD && g.overdraw == nil
+	if pixelateHUD {
+		g.hud.Draw(canvas)
+	}
+	t.Begin(t.Post)
+	g.post.End(d)
+	t.End(t.Post)
+	if g.overdraw != nil && !g.overdraw.Draw(d, d, g.scene, g.cam) {
+		g.overdraw = nil
+	}
+	if !pixelateHUD {
+		g.hud.Draw(d)
+	}
+	g.navCube.Draw(d, g.cam)
+
+	// Queue the frame for dumping. The download only completes once the frame
+	// is rendered, so the dump is stopped after that.
+	dumpDone := g.frameDump != nil && g.frameDump.capture(d)
+	if g.video != nil {
+		g.video.capture(d)
+	}
+
+	// Render the frame.
+	d.Render()
+	if dumpDone {
+		g.StopFrameDump()
+	}
+
+	g.input.EndFrame()
+}
+
+// Shutdown finishes the running video capture and frame dump, for the game to
+// exit.
+func (g *Game) Shutdown() {
+	if err := g.StopVideoCapture(); err != nil {
+		log.Println("video capture:", err)
+	}
+	g.StopFrameDump()
+}
+
+// HandleEvent handles the events that reach the bottom of the input context
+// stack, which is the camera and the game shortcuts. It implements the
+// InputContext interface.
+func (g *Game) HandleEvent(e window.Event) bool {
+	g.input.Handle(e)
+
+	switch ev := e.(type) {
+	case touchEvent:
+		g.handleTouch(ev)
+
+	case window.Close:
+		// Finish the captures before the window goes away, or the video
+		// is left without its end.
+		g.Shutdown()
+		g.w.Close()
+
+	case window.FramebufferResized:
+		// Update the camera's projection matrix for the new width and
+		// height.
+		g.updateProjection()
+		g.bounds = g.d.Bounds()
+		g.hud.Resize(g.d.Bounds())
+		g.navCube.Resize(g.d.Bounds())
+
+		// The window may have moved to a display with another DPI.
+		if s := dpiScale(g.w); s != g.fps.Renderer.Scale {
+			g.fps.Renderer.Scale = s
+			g.fps.Redraw()
+			g.console.label.Renderer.Scale = s
+			g.console.label.Redraw()
+			g.ruler.Text.Scale = s
+			g.ruler.redraw()
+			g.nameField.Renderer.Scale = s
+			g.nameField.Redraw()
+		}
+
+	case keyboard.ButtonEvent:
+		if ev.State != keyboard.Down {
+			break
+		}
+		switch ev.Key {
+		case keyboard.F5:
+			g.saveScene("scene.bin")
+		case keyboard.F9:
+			g.loadScene("scene.bin")
+		case keyboard.F8:
+			// Toggle dumping the next ten seconds (at 60 FPS) of frames.
+			if g.frameDump == nil {
+				if err := g.StartFrameDump("frames", 600); err != nil {
+					log.Println(err)
+				}
+			} else {
+				g.StopFrameDump()
+			}
+		case keyboard.Home:
+			g.FrameScene()
+		case keyboard.F6:
+			if err := g.SaveCameraState("camera.json"); err != nil {
+				log.Println(err)
+			}
+		case keyboard.F7:
+			// Save a thumbnail of the card.
+			img, err := g.CaptureObjectThumbnail(g.card, image.Pt(128, 128))
+			if err == nil {
+				err = writePNG("thumbnail.png", img)
+			}
+			if err != nil {
+				log.Println(err)
+			}
+
+		// Control Z and Y undo and redo, the arrow keys move the selected
+		// object.
+		case keyboard.Z, keyboard.Y:
+			kb := g.w.Keyboard()
+			if kb.Down(keyboard.LeftControl) || kb.Down(keyboard.RightControl) {
+				if ev.Key == keyboard.Z {
+					g.Undo()
+				} else {
+					g.Redo()
+				}
+			}
+		case keyboard.ArrowLeft:
+			g.nudge(lmath.Vec3{-0.1, 0, 0})
+		case keyboard.ArrowRight:
+			g.nudge(lmath.Vec3{0.1, 0, 0})
+		case keyboard.ArrowUp:
+			g.nudge(lmath.Vec3{0, 0, 0.1})
+		case keyboard.ArrowDown:
+			g.nudge(lmath.Vec3{0, 0, -0.1})
+		}
+
+	case keyboard.Typed:
+		if ev.S == "`" {
+			// Open the console, it captures the keyboard until closed.
+			g.console.Open()
+		}
+		if ev.S == "f" || ev.S == "F" {
+			// Focus on the selected object, or the one under the cursor.
+			o := g.selected
+			if o == nil {
+				o = g.scene.Pick(g.cam, g.d.Bounds(), g.framebufferPoint(g.input.Cursor()))
+			}
+			if o != nil {
+				g.FocusOn(o)
+			}
+		}
+		if ev.S == "l" || ev.S == "L" {
+			// Toggle logging frame statistics.
+			if g.statsLog == nil {
+				if err := g.StartStatsLog("stats.csv"); err != nil {
+					log.Println(err)
+				}
+			} else if err := g.StopStatsLog(); err != nil {
+				log.Println(err)
+			}
+		}
+		if ev.S == "," || ev.S == "." {
+			// Adjust the mouse sensitivity.
+			x, y := g.fly.MouseSensitivity()
+			f := 1.25
+			if ev.S == "," {
+				f = 1 / f
+			}
+			g.fly.SetMouseSensitivity(x*f, y*f)
+			log.Printf("Mouse sensitivity %.2f, %.2f\n", x*f, y*f)
+		}
+		if ev.S == "c" || ev.S == "C" {
+			// Cycle between no clipping, cutting away the left half of
+			// the card, and also its bottom half.
+			g.clipMode = (g.clipMode + 1) % 3
+			g.scene.ClearClipPlanes()
+			if g.clipMode >= 1 {
+				g.scene.SetClipPlane(0, lmath.Vec4{1, 0, 0, 0})
+			}
+			if g.clipMode >= 2 {
+				g.scene.SetClipPlane(1, lmath.Vec4{0, 0, 1, 0})
+			}
+		}
+		if ev.S == "g" || ev.S == "G" {
+			// Toggle the pixel ruler.
+			g.ShowRuler(g.hud.ruler == nil)
+		}
+		if ev.S == "i" || ev.S == "I" {
+			// Toggle inverting vertical mouse movement.
+			g.fly.SetInvertY(!g.fly.InvertY())
+			log.Println("Invert Y", g.fly.InvertY())
+		}
+		if ev.S == "[" {
+			g.SetGamma(g.post.Gamma - 0.1)
+		}
+		if ev.S == "]" {
+			g.SetGamma(g.post.Gamma + 0.1)
+		}
+		if ev.S == "r" || ev.S == "R" {
+			// Toggle rotating the card with the mouse.
+			g.EnableArcball(g.card, g.arcball == nil)
+		}
+		if ev.S == "p" || ev.S == "P" {
+			// Toggle swinging the card like a door around its left edge.
+			g.swing = !g.swing
+			if g.swing {
+				SetPivot(g.card, lmath.Vec3{-1, 0, 0})
+			} else {
+				g.card.SetRot(lmath.Vec3{})
+				SetPivot(g.card, lmath.Vec3{})
+			}
+		}
+		if ev.S == "v" || ev.S == "V" {
+			// Cycle between mono, side by side and anaglyph stereo.
+			g.SetStereo((g.stereo.Mode+1)%3, g.stereo.EyeSeparation)
+		}
+		if ev.S == "b" || ev.S == "B" {
+			// Toggle forcing the card to sample mip level 2, which only
+			// shows while mipmapping is on.
+			g.mipForced = !g.mipForced
+			if g.mipForced {
+				SetMipRange(g.rtColor, 2, 2)
+			} else {
+				ClearMipRange(g.rtColor)
+			}
+		}
+		if ev.S == "o" || ev.S == "O" {
+			// Toggle moving the card around in a circle.
+			g.orbit = !g.orbit
+			if !g.orbit {
+				g.card.SetPos(lmath.Vec3{})
+			}
+		}
+		if ev.S == "t" || ev.S == "T" {
+			// Toggle bobbing the card up and down from a slow update
+			// goroutine, which rendering keeps going regardless of.
+			if g.threaded != nil {
+				g.threaded.Stop()
+				g.threaded = nil
+			} else {
+				g.threaded = NewThreadedLoop(g.scene, 30, bobUpdate())
+				g.threaded.Start()
+			}
+		}
+		if ev.S == "x" || ev.S == "X" {
+			// Cycle the pixelation between off, 4 and 8 pixel blocks.
+			switch g.post.Pixelation {
+			case 1:
+				g.SetPixelation(4)
+			case 4:
+				g.SetPixelation(8)
+			default:
+				g.SetPixelation(1)
+			}
+		}
+		if ev.S == "k" || ev.S == "K" {
+			// Cycle the color grading between none, warm and cool.
+			g.grading = (g.grading + 1) % 3
+			switch g.grading {
+			case 0:
+				g.SetColorLUT(nil)
+			case 1:
+				g.SetColorLUT(NewColorLUT(16, func(r, gr, b float64) (float64, float64, float64) {
+					return r*1.1 + 0.04, gr*1.02 + 0.01, b * 0.85
+				}))
+			case 2:
+				g.SetColorLUT(NewColorLUT(16, func(r, gr, b float64) (float64, float64, float64) {
+					return r * 0.85, gr*1.02 + 0.01, b*1.1 + 0.04
+				}))
+			}
+		}
+		if ev.S == "j" || ev.S == "J" {
+			// Toggle motion blur, which shows when the card spins or
+			// the camera pans quickly.
+			if g.post.MotionBlur > 0 {
+				g.SetMotionBlur(0)
+			} else {
+				g.SetMotionBlur(1)
+			}
+		}
+		if ev.S == "u" || ev.S == "U" {
+			// Detach the small card where it is, or attach it to the
+			// card again.
+			parent := g.card
+			if Parent(g.moon) != nil {
+				parent = nil
+			}
+			if err := SetParent(g.moon, parent); err != nil {
+				log.Println(err)
+			}
+		}
+		if ev.S == "n" || ev.S == "N" {
+			// Toggle drawing the triangles of the card over its fill.
+			g.wireframe = !g.wireframe
+			width := 0.0
+			if g.wireframe {
+				width = 1.5
+			}
+			SetBarycentricWireframe(g.card, gfx.Color{0, 0, 0, 1}, width)
+		}
+		if ev.S == "y" || ev.S == "Y" {
+			// Cycle between moving the card at 30Hz drawn interpolated,
+			// drawn as simulated, and not moving it.
+			g.simMode = (g.simMode + 1) % 3
+			if card := g.scene.object(g.card); card != nil {
+				card.SetInterpolated(g.simMode == simInterpolated)
+			}
+			if g.simMode == simOff {
+				g.card.SetPos(lmath.Vec3{})
+			}
+		}
+		if ev.S == "h" || ev.S == "H" {
+			// Toggle the card between opaque and glowing additively.
+			g.additive = !g.additive
+			m := MaterialAdditive()
+			if !g.additive {
+				m = MaterialOpaque()
+				m.FaceCulling = gfx.NoFaceCulling
+			}
+			SetMaterial(g.card, m)
+		}
+		if ev.S == "1" {
+			// Toggle the overdraw heatmap.
+			g.SetOverdrawView(g.overdraw == nil)
+		}
+		if ev.S == "m" || ev.S == "M" {
+			// Toggle mipmapping.
+			if g.rtColor.MinFilter == gfx.LinearMipmapLinear {
+				g.rtColor.MinFilter = gfx.Linear
+			} else {
+				g.rtColor.MinFilter = gfx.LinearMipmapLinear
+			}
+		}
+	}
+	return true
+}
+
+// CapturesKeyboard implements the InputContext interface.
+func (g *Game) CapturesKeyboard() bool {
+	return false
+}
+
+// FocusOn smoothly moves the camera so that o is centered and fills the view.
+func (g *Game) FocusOn(o *gfx.Object) {
+	aspect := float64(g.bounds.Dx()) / float64(g.bounds.Dy())
+	pos := focusPos(g.cam, o, aspect)
+	g.tween.Start(g.cam, pos, g.cam.Rot(), 0.5, g.CameraEasing)
+}
+
+// drawScene clears the canvas c and draws the sky and the scene to it, with
+// its reflection in the floor. The depth soft particles need is rendered
+// first.
+func (g *Game) drawScene(d gfx.Device, c gfx.Canvas) {
+	g.scene.RenderDepth(d, g.cam, c.Bounds())
+	c.Clear(c.Bounds(), gfx.Color{1, 1, 1, 1})
+	c.ClearDepth(c.Bounds(), 1.0)
+	if g.skyOn {
+		g.sky.Draw(c, g.cam)
+	}
+	if g.parallaxOn {
+		g.drawParallax(c, g.cam)
+	}
+	g.reflectStats = RenderStats{}
+	g.scene.RenderReflections(d, g.cam, &g.reflectStats)
+	g.stereo.Draw(d, c, g.scene, g.cam, &g.stats)
+}
+
+// FrameScene smoothly moves the camera, keeping its rotation, so that every
+// object of the scene is in view. An orthographic camera is also zoomed to fit
+// them. It does nothing for an empty scene.
+func (g *Game) FrameScene() {
+	if len(g.scene.objects) == 0 {
+		log.Println("Nothing to frame, the scene is empty.")
+		return
+	}
+	var box lmath.Rect3
+	for i, o := range g.scene.objects {
+		b := worldBounds(o.Object)
+		if i == 0 {
+			box = b
+			continue
+		}
+		box = lmath.Rect3{
+			Min: lmath.Vec3{math.Min(box.Min.X, b.Min.X), math.Min(box.Min.Y, b.Min.Y), math.Min(box.Min.Z, b.Min.Z)},
+			Max: lmath.Vec3{math.Max(box.Max.X, b.Max.X), math.Max(box.Max.Y, b.Max.Y), math.Max(box.Max.Z, b.Max.Z)},
+		}
+	}
+	center, radius := boundingSphere(box)
+	if radius == 0 {
+		// A single point, frame a unit around it.
+		radius = 1
+	}
+	aspect := float64(g.bounds.Dx()) / float64(g.bounds.Dy())
+
+	pos := fitSphere(g.cam, center, radius, aspect)
+	if g.cam.Ortho {
+		// The distance does not change the size of the view, only whether
+		// everything is in front of the near plane.
+		g.orthoHeight = 2 * radius * focusMargin / math.Min(aspect, 1)
+		g.updateProjection()
+		pos = center.Sub(cameraForward(g.cam).MulScalar(radius*focusMargin + g.cam.Near))
+	}
+	g.tween.Start(g.cam, pos, g.cam.Rot(), 0.5, g.CameraEasing)
+}
+
+// SetOrthographic switches the camera between an orthographic and a
+// perspective projection.
+func (g *Game) SetOrthographic(ortho bool) {
+	g.cam.Ortho = ortho
+	if g.orthoHeight == 0 {
+		g.orthoHeight = 4
+	}
+	g.updateProjection()
+}
+
+// Select makes o the selected object, o may be nil to select nothing.
+func (g *Game) Select(o *gfx.Object) {
+	if o == g.selected {
+		return
+	}
+	if prev := g.scene.object(g.selected); prev != nil {
+		setSelectTint(prev.Object, gfx.Color{})
+		if prev.Anim != nil {
+			prev.Anim.Transition("idle")
+		}
+	}
+	if next := g.scene.object(o); next != nil {
+		setSelectTint(next.Object, selectTint)
+		if next.Anim != nil {
+			next.Anim.Transition("spin")
+		}
+		if g.nameField != nil {
+			g.nameField.SetValue(next.Name)
+		}
+	}
+	g.selected = o
+}
+
+// ViewFrom tweens the camera to look along -normal, which must be one of the
+// world axes, at the selected object or else at the origin.
+func (g *Game) ViewFrom(normal lmath.Vec3) {
+	var pivot lmath.Vec3
+	if g.selected != nil {
+		pivot, _ = boundingSphere(worldBounds(g.selected))
+	}
+	dist := g.cam.Pos().Sub(pivot).Length()
+	pos := pivot.Add(normal.MulScalar(dist))
+	g.tween.Start(g.cam, pos, axisView(normal), 0.5, g.CameraEasing)
+}
+
+// EnableArcball turns rotating o by dragging it with the mouse on or off. Only
+// a single object can be rotated this way at a time. The mouse settings of the
+// arcball are kept from one to the next.
+func (g *Game) EnableArcball(o *gfx.Object, enabled bool) {
+	if g.arcball != nil {
+		g.arcballLook = g.arcball.settings()
+	}
+	if !enabled {
+		g.arcball = nil
+		return
+	}
+	g.arcball = NewArcball(g.cam, o)
+	g.arcball.setSettings(g.arcballLook)
+	g.arcball.OnDragStart = func(target *gfx.Object) {
+		g.history.Record(g.selected, target)
+	}
+}
+
+// Undo undoes the last edit, if any.
+func (g *Game) Undo() {
+	if sel, ok := g.history.Undo(g.selected); ok {
+		g.Select(sel)
+	}
+}
+
+// Redo redoes the last undone edit, if any.
+func (g *Game) Redo() {
+	if sel, ok := g.history.Redo(g.selected); ok {
+		g.Select(sel)
+	}
+}
+
+// nudge moves the selected object by delta, as an edit that can be undone.
+func (g *Game) nudge(delta lmath.Vec3) {
+	if g.selected == nil {
+		return
+	}
+	g.history.Record(g.selected, g.selected)
+	g.selected.SetPos(g.selected.Pos().Add(delta))
+}
+
+// saveScene saves the scene in binary form to the file at path.
+func (g *Game) saveScene(path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer f.Close()
+	waiting := 0
+	for _, o := range g.scene.objects {
+		if g.shaders.Waiting(o.Object) {
+			waiting++
+		}
+	}
+	if waiting > 0 {
+		log.Printf("Saving the scene without %d objects whose shaders are still loading.\n", waiting)
+	}
+	w := bufio.NewWriter(f)
+	if err := g.scene.SaveBinary(w); err != nil {
+		log.Println(err)
+		return
+	}
+	if err := w.Flush(); err != nil {
+		log.Println(err)
+	}
+}
+
+// loadScene replaces the scene with the binary one in the file at path.
+func (g *Game) loadScene(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer f.Close()
+	textures := make(TextureMap)
+	for t, name := range g.scene.textureNames {
+		textures[name] = t
+	}
+	if err := g.scene.LoadBinary(bufio.NewReader(f), textures); err != nil {
+		log.Println(err)
+	}
+}
+
+// forget drops what the game holds on to of o, which was removed from the
+// scene: the selection, the drag and the threaded loop let go of it.
+func (g *Game) forget(o *Object) {
+	if g.selected == o.Object {
+		g.Select(nil)
+	}
+	for i, so := range g.boxSelected {
+		if so == o.Object {
+			g.boxSelected = append(g.boxSelected[:i], g.boxSelected[i+1:]...)
+			break
+		}
+	}
+	if g.drag != nil {
+		g.drag.forget(o.Object)
+	}
+	if g.threaded != nil {
+		g.threaded.forget(o)
+	}
+	if g.overdraw != nil {
+		delete(g.overdraw.proxies, o.Object)
+	}
+}
+
+// ShowRuler shows or hides the pixel ruler overlay.
+func (g *Game) ShowRuler(show bool) {
+	if show {
+		g.hud.ShowRuler(g.ruler)
+	} else {
+		g.hud.ShowRuler(nil)
+	}
+}
+
+// SetRulerSpacing sets the distance between the labeled gridlines of the pixel
+// ruler, in framebuffer pixels.
+func (g *Game) SetRulerSpacing(px int) {
+	g.ruler.SetSpacing(px)
+}
+
+// SetGamma sets the gamma of the final output, 1 leaves it unchanged.
+func (g *Game) SetGamma(gamma float64) {
+	g.post.setGamma(gamma)
+	log.Printf("Gamma %.1f\n", g.post.Gamma)
+}
+
+// SetCameraCollision turns collision of the fly camera against the bounds of
+// scene objects on or off, treating the camera as a sphere of the given radius.
+func (g *Game) SetCameraCollision(enabled bool, radius float64) {
+	if !enabled {
+		g.fly.Collide = nil
+		return
+	}
+	g.fly.Collide = func(pos, move lmath.Vec3) lmath.Vec3 {
+		return g.scene.Slide(pos, move, radius)
+	}
+}
+
+// dpiScale returns the number of framebuffer pixels per window pixel.
+func dpiScale(w window.Window) int {
+	props := w.Props()
+	width, _ := props.Size()
+	fbWidth, _ := props.FramebufferSize()
+	if width == 0 || fbWidth < width {
+		return 1
+	}
+	return fbWidth / width
+}
+
+// framebufferPoint converts p from the window pixels the cursor is given in to
+// the framebuffer pixels the device draws in.
+func (g *Game) framebufferPoint(p image.Point) image.Point {
+	return p.Mul(dpiScale(g.w))
+}
+
+// jumpBuffer is how long, in seconds, a jump press is kept while the card is
+// in the air, to jump again as soon as it lands.
+const jumpBuffer = 0.15
+
+// updateJump makes the card jump when space is pressed while it is on the
+// ground, and moves it along its jump.
+func (g *Game) updateJump(dt float64) {
+	if g.jumpZ <= 0 && g.input.BufferedPress(keyboard.Space, jumpBuffer) {
+		g.jumpVel = 3
+	}
+	if g.jumpZ <= 0 && g.jumpVel == 0 {
+		return
+	}
+	g.jumpVel -= 9.8 * dt
+	g.jumpZ += g.jumpVel * dt
+	if g.jumpZ <= 0 {
+		g.jumpZ, g.jumpVel = 0, 0
+	}
+	pos := g.card.Pos()
+	pos.Z = g.jumpZ
+	g.card.SetPos(pos)
+}
+
+// SetStereo sets how the scene is drawn for two eyes, and how far apart in
+// world units the eyes are. StereoOff draws the scene once again.
+func (g *Game) SetStereo(mode StereoMode, eyeSeparation float64) {
+	g.stereo.Mode = mode
+	g.stereo.EyeSeparation = eyeSeparation
+	log.Printf("Stereo %v, eye separation %.3f\n", mode, eyeSeparation)
+}
+
+// SetPixelation draws the scene with blocks of blockSize by blockSize screen
+// pixels, for a retro look. A block size of 1 draws it at full resolution.
+func (g *Game) SetPixelation(blockSize int) {
+	if blockSize < 1 {
+		blockSize = 1
+	}
+	g.post.Pixelation = blockSize
+	log.Println("Pixelation", blockSize)
+}
+
+// SetColorLUT sets the color lookup table the scene is graded with, as made by
+// NewColorLUT or LoadCubeLUT. A nil table turns grading off.
+func (g *Game) SetColorLUT(lut *gfx.Texture) {
+	g.post.LUT = lut
+}
+
+// SetPixelateHUD sets whether the HUD is pixelated along with the scene,
+// instead of being drawn over it at full resolution.
+func (g *Game) SetPixelateHUD(pixelate bool) {
+	g.pixelateHUD = pixelate
+}
+
+// addFloor adds a checkered floor of static tiles below the card, showing the
+// floor reflection.
+func (g *Game) addFloor() {
+	textures := &g.floorTex
+	for i, c := range []color.RGBA{{200, 200, 200, 255}, {120, 120, 120, 255}} {
+		img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+		img.SetRGBA(0, 0, c)
+		textures[i] = gfx.NewTexture()
+		textures[i].Source = img
+		textures[i].Bounds = img.Bounds()
+		g.scene.NameTexture(fmt.Sprintf("floor%d", i), textures[i])
+	}
+
+	tile := gfx.NewMesh()
+	tile.Vertices = []gfx.Vec3{
+		{-0.5, -0.5, 0}, {0.5, -0.5, 0}, {-0.5, 0.5, 0},
+		{-0.5, 0.5, 0}, {0.5, -0.5, 0}, {0.5, 0.5, 0},
+	}
+	tile.Normals = computeNormals(tile.Vertices, nil, nil)
+	tile.TexCoords = []gfx.TexCoordSet{{Slice: make([]gfx.TexCoord, 6)}}
+
+	const n, size = 16, 0.25
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			o := gfx.NewObject()
+			o.State = gfx.NewState()
+			g.shaders.Use(o, "scene")
+			o.Textures = []*gfx.Texture{textures[(x+y)%2]}
+			o.Meshes = []*gfx.Mesh{tile}
+			o.SetScale(lmath.Vec3{size, size, size})
+			o.SetPos(lmath.Vec3{(float64(x) - n/2 + 0.5) * size, (float64(y) - n/2 + 0.5) * size, -1.2})
+			SetStatic(o, true)
+			g.reflection.AddSurface(o)
+			g.scene.Add(&Object{Object: o, Name: fmt.Sprintf("floor%d_%d", x, y)})
+		}
+	}
+}
+
+// addMeadow instances a grass card at every point of the point cloud file at
+// path, randomly turned and sized, drawn with one call per cell of the grid.
+func (g *Game) addMeadow(path string) {
+	points, err := LoadInstancePositions(path)
+	if err != nil {
+		log.Println("Meadow disabled:", err)
+		return
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.SetRGBA(0, 0, color.RGBA{90, 150, 60, 255})
+	tex := gfx.NewTexture()
+	tex.Source = img
+	tex.Bounds = img.Bounds()
+
+	// A card standing on its bottom edge.
+	card := gfx.NewMesh()
+	card.Vertices = []gfx.Vec3{
+		{-0.1, 0, 0}, {0.1, 0, 0}, {-0.1, 0, 0.3},
+		{-0.1, 0, 0.3}, {0.1, 0, 0}, {0.1, 0, 0.3},
+	}
+	card.Normals = computeNormals(card.Vertices, nil, nil)
+	card.TexCoords = []gfx.TexCoordSet{{Slice: make([]gfx.TexCoord, 6)}}
+
+	meadow := NewInstancedObjectFromPoints(card, points)
+	meadow.FaceCulling = gfx.NoFaceCulling
+	g.shaders.Use(meadow.Object, "scene")
+	meadow.Textures = []*gfx.Texture{tex}
+	meadow.SetRandomTransform(g.random.Seed(), 180, 0.6, 1.4)
+	g.scene.AddInstances(meadow)
+	g.meadow = meadow
+	log.Printf("Meadow: %d cards in %d draw calls\n", len(points), meadow.Cells())
+}
+
+// addForest adds a grid of trees made of two crossed cards showing tex, each
+// swapped for an imposter captured from eight angles when far away.
+func (g *Game) addForest(tex *gfx.Texture) {
+	tree := gfx.NewMesh()
+	tree.Vertices = []gfx.Vec3{
+		{-0.5, 0, 0}, {0.5, 0, 0}, {-0.5, 0, 1.5},
+		{-0.5, 0, 1.5}, {0.5, 0, 0}, {0.5, 0, 1.5},
+		{0, -0.5, 0}, {0, 0.5, 0}, {0, -0.5, 1.5},
+		{0, -0.5, 1.5}, {0, 0.5, 0}, {0, 0.5, 1.5},
+	}
+	tree.Normals = computeNormals(tree.Vertices, nil, nil)
+	tree.TexCoords = []gfx.TexCoordSet{{Slice: []gfx.TexCoord{
+		{0, 1}, {1, 1}, {0, 0},
+		{0, 0}, {1, 1}, {1, 0},
+		{0, 1}, {1, 1}, {0, 0},
+		{0, 0}, {1, 1}, {1, 0},
+	}}}
+
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 6; x++ {
+			o := gfx.NewObject()
+			o.State = gfx.NewState()
+			o.FaceCulling = gfx.NoFaceCulling
+			g.shaders.Use(o, "scene")
+			o.Textures = []*gfx.Texture{tex}
+			o.Meshes = []*gfx.Mesh{tree}
+			o.SetPos(lmath.Vec3{float64(x)*2 - 5, 12 + float64(y)*2, -1.2})
+			o.SetRot(lmath.Vec3{0, 0, float64(17 * (x + 6*y))})
+			lod := NewLODObject(o)
+			lod.SetImposterLevel(10, 8)
+			lod.SetHysteresis(0.5)
+			g.scene.AddLOD(lod)
+			g.scene.Add(&Object{Object: o, Name: fmt.Sprintf("tree%d_%d", x, y)})
+		}
+	}
+}
+
+// newCube returns a cube object of the given size centered on its origin.
+func newCube(size float64) *gfx.Object {
+	m := gfx.NewMesh()
+	h := float32(size / 2)
+	corner := func(i int) gfx.Vec3 {
+		v := gfx.Vec3{-h, -h, -h}
+		if i&1 != 0 {
+			v.X = h
+		}
+		if i&2 != 0 {
+			v.Y = h
+		}
+		if i&4 != 0 {
+			v.Z = h
+		}
+		return v
+	}
+	// Two counter-clockwise triangles per face, seen from the outside.
+	for _, f := range [][4]int{
+		{0, 2, 3, 1}, {4, 5, 7, 6}, // -Z, +Z
+		{0, 1, 5, 4}, {2, 6, 7, 3}, // -Y, +Y
+		{0, 4, 6, 2}, {1, 3, 7, 5}, // -X, +X
+	} {
+		for _, i := range []int{0, 1, 2, 0, 2, 3} {
+			m.Vertices = append(m.Vertices, corner(f[i]))
+		}
+	}
+	m.Normals = computeNormals(m.Vertices, nil, nil)
+	m.TexCoords = []gfx.TexCoordSet{{Slice: make([]gfx.TexCoord, len(m.Vertices))}}
+
+	o := gfx.NewObject()
+	o.State = gfx.NewState()
+	o.Meshes = []*gfx.Mesh{m}
+	return o
+}
+
+// logoImage draws a placeholder logo, a ring with a dot in its middle, on a
+// transparent square of the given size.
+func logoImage(size int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	c := color.RGBA{20, 60, 160, 255}
+	r := float64(size) / 2
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			d := math.Hypot(float64(x)+0.5-r, float64(y)+0.5-r) / r
+			if (d > 0.7 && d < 0.95) || d < 0.3 {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+	return img
+}
+
+// Modes of the fixed step card simulation.
+const (
+	simOff = iota
+	simInterpolated
+	simStepped
+)
+
+// simCardStep is a fixed simulation step sliding the card to the right, and
+// wrapping it back to the left which teleports it. The jump is stepped here
+// too, as FixedUpdate puts the card back where the last step left it.
+func (g *Game) simCardStep(dt float64) {
+	g.simTime += dt
+	x := math.Mod(g.simTime*0.8, 2) - 1
+	pos := g.card.Pos()
+	g.card.SetPos(lmath.Vec3{x, pos.Y, pos.Z})
+	g.updateJump(dt)
+}
+
+// bobUpdate returns a ThreadedLoop update moving the first object, the card,
+// up and down.
+func bobUpdate() func(state []TransformState, dt float64) {
+	var t float64
+	return func(state []TransformState, dt float64) {
+		t += dt
+		if len(state) > 0 {
+			state[0].Pos.Z = 0.25 * math.Sin(2*t)
+		}
+	}
+}
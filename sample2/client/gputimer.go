@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"azul3d.org/engine/gfx"
+)
+
+// timerQueryExtension is the extension GPU timer queries need.
+const timerQueryExtension = "GL_ARB_timer_query"
+
+// gpuTimerLatency is how many frames old the timer queries read back are.
+// Results are only available once the GPU finished the frame, so reading them
+// earlier would stall until it did.
+const gpuTimerLatency = 2
+
+// timerQuerier is implemented by devices able to time the GPU commands issued
+// between BeginTimer and EndTimer. The gfx devices do not expose timer queries
+// yet, so no device implements it: the passes are not timed, and the FPS
+// counter says so while the timers are on.
+type timerQuerier interface {
+	BeginTimer() (id int)
+	EndTimer(id int)
+
+	// TimerResult returns the GPU time of the query in seconds, or false if
+	// it is not available yet.
+	TimerResult(id int) (seconds float64, ok bool)
+}
+
+// Pass is a render pass of the frame timed on the GPU.
+type Pass struct {
+	Name string
+
+	// One query per frame in flight, used in turn.
+	ids    [gpuTimerLatency + 1]int
+	issued [gpuTimerLatency + 1]bool
+	time   float64
+}
+
+// GPUTime returns the GPU time, in seconds, the pass took gpuTimerLatency
+// frames ago. It is zero where timer queries are not supported.
+func (p *Pass) GPUTime() float64 {
+	return p.time
+}
+
+// GPUTimers times the shadow, scene and post processing passes of the frame.
+type GPUTimers struct {
+	Shadow, Scene, Post *Pass
+
+	// Enabled sets whether queries are issued.
+	Enabled bool
+
+	dev   timerQuerier
+	frame int
+}
+
+func NewGPUTimers(d gfx.Device) *GPUTimers {
+	t := &GPUTimers{
+		Shadow: &Pass{Name: "shadow"},
+		Scene:  &Pass{Name: "scene"},
+		Post:   &Pass{Name: "post"},
+	}
+	t.dev, _ = d.(timerQuerier)
+	return t
+}
+
+// Supported reports whether the device can time passes.
+func (t *GPUTimers) Supported() bool {
+	return t.dev != nil
+}
+
+// Passes returns the timed passes, in the order they are rendered.
+func (t *GPUTimers) Passes() []*Pass {
+	return []*Pass{t.Shadow, t.Scene, t.Post}
+}
+
+// Total returns the sum of the GPU times of the passes.
+func (t *GPUTimers) Total() float64 {
+	var total float64
+	for _, p := range t.Passes() {
+		total += p.time
+	}
+	return total
+}
+
+// BeginFrame starts a new frame, collecting the results of the queries issued
+// gpuTimerLatency frames ago whose slot the frame reuses.
+func (t *GPUTimers) BeginFrame() {
+	t.frame++
+	if t.dev == nil {
+		return
+	}
+	slot := t.frame % len(Pass{}.ids)
+	for _, p := range t.Passes() {
+		if !p.issued[slot] {
+			continue
+		}
+		p.issued[slot] = false
+		if s, ok := t.dev.TimerResult(p.ids[slot]); ok {
+			p.time = s
+		}
+	}
+}
+
+// Begin starts timing the pass p.
+func (t *GPUTimers) Begin(p *Pass) {
+	if t.dev == nil || !t.Enabled {
+		return
+	}
+	slot := t.frame % len(p.ids)
+	p.ids[slot] = t.dev.BeginTimer()
+	p.issued[slot] = true
+}
+
+// End stops timing the pass p.
+func (t *GPUTimers) End(p *Pass) {
+	if t.dev == nil || !t.Enabled {
+		return
+	}
+	slot := t.frame % len(p.ids)
+	if p.issued[slot] {
+		t.dev.EndTimer(p.ids[slot])
+	}
+}
+
+// SetGPUTimers turns timing the render passes on the GPU on or off. Where the
+// device cannot time them, the times stay zero and a notice says why.
+func (g *Game) SetGPUTimers(enabled bool) {
+	g.timers.Enabled = enabled
+	if !enabled {
+		for _, p := range g.timers.Passes() {
+			p.time = 0
+		}
+		return
+	}
+	if g.timers.Supported() {
+		return
+	}
+	if !hasExtension(g.d.Info(), timerQueryExtension) {
+		log.Println("GPU timers: timer queries are not supported by this device.")
+	} else {
+		log.Printf("GPU timers: the device supports %s but gfx cannot issue queries.\n", timerQueryExtension)
+	}
+}
+
+// gpuTimesText returns the GPU times of the passes for the FPS counter, or
+// nothing while the timers are off.
+func (g *Game) gpuTimesText() string {
+	t := g.timers
+	if !t.Enabled {
+		return ""
+	}
+	if !t.Supported() {
+		return " | GPU timers unsupported by gfx"
+	}
+	text := " | GPU"
+	for _, p := range t.Passes() {
+		text += fmt.Sprintf(" %s %.2f", p.Name, p.GPUTime()*1000)
+	}
+	return text + fmt.Sprintf(" = %.2f ms", t.Total()*1000)
+}
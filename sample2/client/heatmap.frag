@@ -0,0 +1,24 @@
+#version 120
+
+varying vec2 tc0;
+
+// Texture0 holds how many times each pixel was drawn, as a fraction of the
+// count shown in red.
+uniform sampler2D Texture0;
+
+// ramp maps 0 to blue, through cyan, green and yellow, to red at 1.
+vec3 ramp(float t)
+{
+	return clamp(vec3(4.0 * t - 2.0, 2.0 - abs(4.0 * t - 2.0), 2.0 - 4.0 * t), 0.0, 1.0);
+}
+
+void main()
+{
+	float t = texture2D(Texture0, tc0).r;
+	if(t == 0.0) {
+		// Nothing was drawn here at all.
+		gl_FragColor = vec4(0.0, 0.0, 0.0, 1.0);
+		return;
+	}
+	gl_FragColor = vec4(ramp(t), 1.0);
+}
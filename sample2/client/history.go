@@ -0,0 +1,96 @@
+package main
+
+import (
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// transformSnapshot is the transform of one object at some point in time. The
+// rotation is kept as a quaternion, which rotations made with the arcball are,
+// so that it comes back exactly.
+type transformSnapshot struct {
+	o          *gfx.Object
+	pos, scale lmath.Vec3
+	quat       lmath.Quat
+}
+
+func snapshotOf(o *gfx.Object) transformSnapshot {
+	return transformSnapshot{o, o.Pos(), o.Scale(), o.Quat()}
+}
+
+func (s transformSnapshot) restore() {
+	s.o.SetPos(s.pos)
+	s.o.SetQuat(s.quat)
+	s.o.SetScale(s.scale)
+}
+
+// historyEntry is the state an edit changed, as it was on one side of the
+// edit. Only the objects the edit touched are kept.
+type historyEntry struct {
+	objects  []transformSnapshot
+	selected *gfx.Object
+}
+
+// capture returns the current state of the objects of e.
+func (e historyEntry) capture(selected *gfx.Object) historyEntry {
+	now := historyEntry{selected: selected}
+	for _, s := range e.objects {
+		now.objects = append(now.objects, snapshotOf(s.o))
+	}
+	return now
+}
+
+// History records the transforms of edited objects, and the selection, so
+// that edits can be undone and redone.
+type History struct {
+	// Limit is the most edits that can be undone, older ones are forgotten.
+	Limit int
+
+	undo, redo []historyEntry
+}
+
+func NewHistory() *History {
+	return &History{Limit: 100}
+}
+
+// Record saves the transforms of the objects about to be edited, along with
+// the selection. It must be called before each edit, and makes the edits that
+// were undone impossible to redo.
+func (h *History) Record(selected *gfx.Object, objects ...*gfx.Object) {
+	e := historyEntry{selected: selected}
+	for _, o := range objects {
+		e.objects = append(e.objects, snapshotOf(o))
+	}
+	h.undo = append(h.undo, e)
+	if h.Limit > 0 && len(h.undo) > h.Limit {
+		h.undo = h.undo[len(h.undo)-h.Limit:]
+	}
+	h.redo = h.redo[:0]
+}
+
+// Undo restores the state from before the last edit, given the current
+// selection, and returns the selection to restore. It reports false if there
+// is nothing to undo.
+func (h *History) Undo(selected *gfx.Object) (*gfx.Object, bool) {
+	return h.step(&h.undo, &h.redo, selected)
+}
+
+// Redo repeats the last undone edit, like Undo.
+func (h *History) Redo(selected *gfx.Object) (*gfx.Object, bool) {
+	return h.step(&h.redo, &h.undo, selected)
+}
+
+// step restores the last entry of from, saving the current state of the same
+// objects to to.
+func (h *History) step(from, to *[]historyEntry, selected *gfx.Object) (*gfx.Object, bool) {
+	if len(*from) == 0 {
+		return selected, false
+	}
+	e := (*from)[len(*from)-1]
+	*from = (*from)[:len(*from)-1]
+	*to = append(*to, e.capture(selected))
+	for _, s := range e.objects {
+		s.restore()
+	}
+	return e.selected, true
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+func TestHistoryUndoRedo(t *testing.T) {
+	h := NewHistory()
+	a, b := gfx.NewObject(), gfx.NewObject()
+	start := lmath.Vec3{1, 2, 3}
+	a.SetPos(start)
+
+	h.Record(nil, a)
+	a.SetPos(lmath.Vec3{4, 5, 6})
+	h.Record(a, b)
+	b.SetScale(lmath.Vec3{2, 2, 2})
+
+	sel, ok := h.Undo(b)
+	if !ok || sel != a || b.Scale() != (lmath.Vec3{1, 1, 1}) {
+		t.Fatalf("first undo: selected %p, ok %v, scale %v", sel, ok, b.Scale())
+	}
+	sel, ok = h.Undo(sel)
+	if !ok || sel != nil || a.Pos() != start {
+		t.Fatalf("second undo: selected %p, ok %v, pos %v", sel, ok, a.Pos())
+	}
+	if _, ok := h.Undo(sel); ok {
+		t.Fatal("undid more edits than were recorded")
+	}
+
+	sel, ok = h.Redo(sel)
+	if !ok || sel != nil || a.Pos() != (lmath.Vec3{4, 5, 6}) {
+		t.Fatalf("redo: selected %p, ok %v, pos %v", sel, ok, a.Pos())
+	}
+
+	// A new edit makes the undone one impossible to redo.
+	h.Record(sel, a)
+	if _, ok := h.Redo(sel); ok {
+		t.Fatal("redid an edit after a new one was recorded")
+	}
+}
+
+func TestHistoryRestoresQuat(t *testing.T) {
+	h := NewHistory()
+	o := gfx.NewObject()
+	// A unit rotation with no exact Euler angles.
+	q := lmath.Quat{W: 0.8, X: 0.2, Y: 0.4, Z: 0.4}
+	o.SetQuat(q)
+
+	h.Record(nil, o)
+	o.SetQuat(lmath.Quat{W: 1})
+	h.Undo(nil)
+	if got := o.Quat(); got != q {
+		t.Errorf("rotation %v after undo, want %v", got, q)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	h := NewHistory()
+	h.Limit = 3
+	o := gfx.NewObject()
+	for i := 0; i < 5; i++ {
+		h.Record(nil, o)
+		o.SetPos(lmath.Vec3{float64(i + 1), 0, 0})
+	}
+	undone := 0
+	for {
+		if _, ok := h.Undo(nil); !ok {
+			break
+		}
+		undone++
+	}
+	if undone != 3 || o.Pos().X != 2 {
+		t.Errorf("undid %d edits back to %v, want 3 back to x = 2", undone, o.Pos())
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"image"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// hoverTint is the color mixed into the object under the cursor, by its alpha.
+// It is a cool blue, unlike the warm glow of the selection.
+var hoverTint = gfx.Color{0.3, 0.6, 1, 0.25}
+
+// hoverState finds the object under the cursor from the ID buffer. The pixel
+// is downloaded without waiting for it, and the object is found once it has
+// arrived, a frame or so later.
+type hoverState struct {
+	hovered *gfx.Object
+
+	// pending receives the pixel downloaded for objects, the scene objects
+	// rendered with their IDs.
+	pending chan image.Image
+	objects []*Object
+
+	// rendered is set once the IDs were rendered with cursor, viewProj and
+	// models, the cursor position, the camera and the model matrices of
+	// every scene object, so that they are only rendered again once one of
+	// them changed.
+	rendered bool
+	cursor   image.Point
+	viewProj lmath.Mat4
+	models   map[*gfx.Object]lmath.Mat4
+}
+
+// stale reports whether the IDs rendered last may no longer show what is under
+// the cursor of the scene s.
+func (h *hoverState) stale(s *Scene, cursor image.Point) bool {
+	if !h.rendered || cursor != h.cursor || viewProj(s.cam) != h.viewProj || len(h.models) != len(s.objects) {
+		return true
+	}
+	for _, o := range s.objects {
+		m, ok := h.models[o.Object]
+		if !ok || m != o.Convert(gfx.LocalToWorld) {
+			return true
+		}
+	}
+	return false
+}
+
+// record remembers what the IDs were rendered with.
+func (h *hoverState) record(s *Scene, cursor image.Point) {
+	h.rendered = true
+	h.cursor = cursor
+	h.viewProj = viewProj(s.cam)
+	if h.models == nil {
+		h.models = make(map[*gfx.Object]lmath.Mat4, len(s.objects))
+	}
+	for o := range h.models {
+		delete(h.models, o)
+	}
+	for _, o := range s.objects {
+		h.models[o.Object] = o.Convert(gfx.LocalToWorld)
+	}
+}
+
+// SetHoverHighlight sets whether the object under the cursor, as given to
+// UpdateHover, is tinted. Only the frontmost one at the cursor is.
+func (s *Scene) SetHoverHighlight(enabled bool) {
+	if !enabled {
+		if s.hover != nil {
+			setHighlight(s.hover.hovered, gfx.Color{})
+		}
+		s.hover = nil
+		return
+	}
+	if s.hover == nil {
+		s.hover = &hoverState{}
+	}
+}
+
+// Hovered returns the highlighted object under the cursor, or nil.
+func (s *Scene) Hovered() *gfx.Object {
+	if s.hover == nil {
+		return nil
+	}
+	return s.hover.hovered
+}
+
+// UpdateHover highlights the object at the pixel cursor, in framebuffer
+// pixels, as drawn by the last call to Draw. It is meant to be called once per
+// frame, and renders the IDs of the objects in view again only once the
+// cursor, the camera or an object moved.
+func (s *Scene) UpdateHover(d gfx.Device, cursor image.Point) {
+	h := s.hover
+	if h == nil {
+		return
+	}
+	if h.pending != nil {
+		select {
+		case img := <-h.pending:
+			h.pending = nil
+			s.setHovered(objectWithID(img, h.objects))
+		default:
+			// Keep the current highlight until the pixel arrives.
+			return
+		}
+	}
+	if !cursor.In(d.Bounds()) {
+		s.setHovered(nil)
+		h.rendered = false
+		return
+	}
+	if s.cam == nil || !h.stale(s, cursor) {
+		return
+	}
+	pending := make(chan image.Image, 1)
+	objects, ok := s.renderIDs(d, cursor, pending)
+	if !ok {
+		return
+	}
+	h.record(s, cursor)
+	h.objects = objects
+	h.pending = pending
+}
+
+// setHovered moves the highlight to o, which may be nil.
+func (s *Scene) setHovered(o *gfx.Object) {
+	h := s.hover
+	if o == h.hovered {
+		return
+	}
+	setHighlight(h.hovered, gfx.Color{})
+	setHighlight(o, hoverTint)
+	h.hovered = o
+}
+
+// setHighlight mixes the color c into o, by the alpha of c.
+func setHighlight(o *gfx.Object, c gfx.Color) {
+	if o == nil {
+		return
+	}
+	sh := ownShader(o)
+	sh.Lock()
+	sh.Inputs["Highlight"] = c
+	sh.Unlock()
+	inputsChanged(o)
+}
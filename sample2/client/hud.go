@@ -0,0 +1,247 @@
+package main
+
+import (
+	"image"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/lmath"
+)
+
+// ScaleMode is how HUD elements are sized as the screen changes size.
+type ScaleMode int
+
+const (
+	// ScaleInherit makes a label use the scale mode of its HUD.
+	ScaleInherit ScaleMode = iota
+
+	// ScalePixel keeps elements at a fixed size in pixels.
+	ScalePixel
+
+	// ScaleWithHeight and ScaleWithWidth size elements in proportion to
+	// the screen height or width, relative to the reference size.
+	ScaleWithHeight
+	ScaleWithWidth
+)
+
+func (m ScaleMode) String() string {
+	switch m {
+	case ScaleInherit:
+		return "inherit"
+	case ScaleWithHeight:
+		return "scale-with-height"
+	case ScaleWithWidth:
+		return "scale-with-width"
+	default:
+		return "pixel"
+	}
+}
+
+// Anchor is the corner of the screen a HUD element is positioned from.
+type Anchor int
+
+const (
+	AnchorTopLeft Anchor = iota
+	AnchorTopRight
+	AnchorBottomLeft
+	AnchorBottomRight
+)
+
+// HUD draws screen aligned elements over the scene using an orthographic
+// camera where one unit is one pixel.
+type HUD struct {
+	cam    *camera.Camera
+	shader *gfx.Shader
+	bounds image.Rectangle
+	labels []*Label
+	fields []*TextField
+	ruler  *Ruler
+
+	// mode and reference are set by SetScaleMode.
+	mode      ScaleMode
+	reference image.Point
+
+	// box is the drag-select rectangle while it is shown, see ShowBox.
+	box, boxQuad *gfx.Object
+}
+
+func NewHUD(bounds image.Rectangle, shader *gfx.Shader) *HUD {
+	return &HUD{
+		cam:    newOrthoCamera(bounds),
+		shader: shader,
+		bounds: bounds,
+		mode:   ScalePixel,
+	}
+}
+
+// SetScaleMode sets how the labels of the HUD are sized, unless they have a
+// mode of their own. Positions and sizes are in pixels at the reference
+// screen size, and scaled from it by the mode.
+func (h *HUD) SetScaleMode(mode ScaleMode, referenceSize image.Point) {
+	if mode == ScaleInherit {
+		mode = ScalePixel
+	}
+	h.mode = mode
+	h.reference = referenceSize
+}
+
+// scale returns the factor the elements drawn with the given mode are scaled
+// by at the current screen size.
+func (h *HUD) scale(mode ScaleMode) float64 {
+	if mode == ScaleInherit {
+		mode = h.mode
+	}
+	switch {
+	case mode == ScaleWithHeight && h.reference.Y > 0:
+		return float64(h.bounds.Dy()) / float64(h.reference.Y)
+	case mode == ScaleWithWidth && h.reference.X > 0:
+		return float64(h.bounds.Dx()) / float64(h.reference.X)
+	}
+	return 1
+}
+
+// Label is a line of text drawn by the HUD.
+type Label struct {
+	*gfx.Object
+	Renderer *TextRenderer
+
+	// ScaleMode is how the label is sized, ScaleInherit uses the mode of
+	// the HUD.
+	ScaleMode ScaleMode
+
+	pos  image.Point
+	text string
+	size image.Point
+}
+
+// AddLabel adds a label whose top-left corner is at pos, in pixels from the
+// top-left of the screen.
+func (h *HUD) AddLabel(pos image.Point, r *TextRenderer) *Label {
+	l := &Label{
+		Object:   newQuad(h.shader),
+		Renderer: r,
+		pos:      pos,
+	}
+	h.labels = append(h.labels, l)
+	return l
+}
+
+// SetText changes the text of the label, re-rendering its texture if needed.
+func (l *Label) SetText(s string) {
+	if s == l.text {
+		return
+	}
+	l.text = s
+	l.Redraw()
+}
+
+// Redraw re-renders the label texture, for instance after the renderer was
+// changed.
+func (l *Label) Redraw() {
+	img := l.Renderer.Render(l.text)
+	tex := gfx.NewTexture()
+	tex.Source = img
+	tex.MinFilter = gfx.Nearest
+	tex.MagFilter = gfx.Nearest
+	tex.WrapU = gfx.Clamp
+	tex.WrapV = gfx.Clamp
+	l.Textures = []*gfx.Texture{tex}
+	l.size = img.Bounds().Size()
+}
+
+// place positions the label quad for the given screen bounds, scaling its
+// position and size by scale.
+func (l *Label) place(bounds image.Rectangle, scale float64) {
+	// Pull the label back by the padding, so the glyphs themselves start at
+	// the requested position.
+	pad := l.Renderer.padding()
+	x := float64(l.pos.X-pad) * scale
+	z := float64(bounds.Dy()) - float64(l.pos.Y-pad+l.size.Y)*scale
+	l.SetPos(lmath.Vec3{x, 0, z})
+	l.SetScale(lmath.Vec3{float64(l.size.X) * scale, 1, float64(l.size.Y) * scale})
+
+	// Text drawn at its own size stays crisp, scaled text is smoothed.
+	filter := gfx.Nearest
+	if scale != 1 {
+		filter = gfx.Linear
+	}
+	if t := l.Textures; len(t) > 0 {
+		t[0].MinFilter = filter
+		t[0].MagFilter = filter
+	}
+}
+
+// Resize updates the HUD camera for new screen bounds.
+func (h *HUD) Resize(bounds image.Rectangle) {
+	h.bounds = bounds
+	h.cam.Update(bounds)
+	if h.ruler != nil {
+		h.ruler.Resize(bounds)
+	}
+}
+
+// ShowRuler sets the ruler drawn under the other HUD elements, or hides it if
+// r is nil.
+func (h *HUD) ShowRuler(r *Ruler) {
+	h.ruler = r
+	if r != nil {
+		r.Resize(h.bounds)
+	}
+}
+
+// Draw draws every HUD element on top of what is already on the canvas.
+func (h *HUD) Draw(c gfx.Canvas) {
+	c.ClearDepth(c.Bounds(), 1.0)
+	if h.ruler != nil {
+		c.Draw(c.Bounds(), h.ruler.quad, h.cam)
+	}
+	if h.box != nil {
+		c.Draw(c.Bounds(), h.box, h.cam)
+	}
+	for _, l := range h.labels {
+		if l.text == "" {
+			continue
+		}
+		l.place(h.bounds, h.scale(l.ScaleMode))
+		c.Draw(c.Bounds(), l.Object, h.cam)
+	}
+	for _, f := range h.fields {
+		f.draw(c, h)
+	}
+}
+
+// newQuad returns a unit quad object in the XZ plane, with its bottom-left
+// corner at the origin and alpha blending enabled.
+func newQuad(shader *gfx.Shader) *gfx.Object {
+	m := gfx.NewMesh()
+	m.Vertices = []gfx.Vec3{
+		{0, 0, 0},
+		{1, 0, 0},
+		{0, 0, 1},
+
+		{0, 0, 1},
+		{1, 0, 0},
+		{1, 0, 1},
+	}
+	m.TexCoords = []gfx.TexCoordSet{
+		{
+			Slice: []gfx.TexCoord{
+				{0, 1},
+				{1, 1},
+				{0, 0},
+
+				{0, 0},
+				{1, 1},
+				{1, 0},
+			},
+		},
+	}
+
+	o := gfx.NewObject()
+	o.State = gfx.NewState()
+	o.FaceCulling = gfx.NoFaceCulling
+	o.AlphaMode = gfx.AlphaBlend
+	o.Shader = shader
+	o.Meshes = []*gfx.Mesh{m}
+	return o
+}
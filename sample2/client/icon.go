@@ -0,0 +1,52 @@
+package main
+
+import (
+	"image"
+	_ "image/png"
+	"log"
+	"os"
+	"runtime"
+)
+
+// LoadImage decodes the image file at path.
+func LoadImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// iconSupported reports whether the window system shows per-window icons. On
+// OS X the icon comes from the application bundle instead.
+func iconSupported() bool {
+	return runtime.GOOS != "darwin"
+}
+
+// SetWindowIcon sets the icon of the window, shown in its title bar and in the
+// taskbar. It may be called before Init, the icon is then set once the window
+// exists.
+func (g *Game) SetWindowIcon(img image.Image) {
+	g.icon = img
+	if g.w == nil {
+		return
+	}
+	if !iconSupported() {
+		log.Println("Window icons are not supported on", runtime.GOOS)
+		return
+	}
+	props := g.w.Props()
+	props.SetIcon(img)
+	g.w.Request(props)
+}
+
+// SetBadge shows a number on the taskbar entry of the window, zero removes it.
+// No window system is supported yet, so it only logs a notice the first time.
+func (g *Game) SetBadge(n int) {
+	if !g.badgeNotice {
+		g.badgeNotice = true
+		log.Println("Taskbar badges are not supported on", runtime.GOOS)
+	}
+}
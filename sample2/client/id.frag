@@ -0,0 +1,32 @@
+#version 120
+
+varying vec2 tc0;
+varying vec4 worldPos;
+
+// ID is the color encoding the identifier of the object being drawn.
+uniform vec4 ID;
+
+// Cutouts discard the texels the scene shader discards, below AlphaThreshold
+// or 0.5 when it is not set, so that they cannot be picked through.
+uniform sampler2D Texture0;
+uniform bool BinaryAlpha;
+uniform float AlphaThreshold;
+
+// The clip planes of the scene, as in the scene shader.
+uniform vec4 ClipPlane0;
+uniform vec4 ClipPlane1;
+uniform vec4 ClipPlane2;
+uniform vec4 ClipPlane3;
+
+void main()
+{
+	if(dot(worldPos, ClipPlane0) < 0.0 || dot(worldPos, ClipPlane1) < 0.0 ||
+	   dot(worldPos, ClipPlane2) < 0.0 || dot(worldPos, ClipPlane3) < 0.0) {
+		discard;
+	}
+	float threshold = AlphaThreshold > 0.0 ? AlphaThreshold : 0.5;
+	if(BinaryAlpha && texture2D(Texture0, tc0).a < threshold) {
+		discard;
+	}
+	gl_FragColor = ID;
+}
@@ -0,0 +1,17 @@
+#version 120
+
+attribute vec3 Vertex;
+attribute vec2 TexCoord0;
+
+uniform mat4 MVP;
+uniform mat4 Model;
+
+varying vec2 tc0;
+varying vec4 worldPos;
+
+void main()
+{
+	tc0 = TexCoord0;
+	worldPos = Model * vec4(Vertex, 1.0);
+	gl_Position = MVP * vec4(Vertex, 1.0);
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"log"
+	"math"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/gfx/gfxutil"
+	"azul3d.org/engine/lmath"
+)
+
+// imposterSize is the width and height in texels of each captured angle.
+const imposterSize = 128
+
+// maxImposterAngles is the most angles an imposter captures, all of them
+// side by side in one texture.
+const maxImposterAngles = 16
+
+// Imposter is a billboard standing in for a distant object. The object is
+// rendered once from several angles around the vertical axis, as it stands
+// when captured, and the billboard shows the capture nearest to the direction
+// it is seen from.
+type Imposter struct {
+	dist   float64
+	angles int
+
+	// atlas holds the captures side by side, and meshes the billboard of
+	// each, in the local space of the object.
+	atlas  *gfx.Texture
+	meshes [][]*gfx.Mesh
+	ready  bool
+
+	// billboard is drawn by the scene instead of the object while shown is
+	// set, so that the object itself keeps its shader and textures. angle is
+	// the capture in use.
+	billboard *gfx.Object
+	shown     bool
+	angle     int
+}
+
+// SetImposterLevel makes l draw a billboard captured from the given number of
+// angles around it, from distance away from the camera and on. It is the last
+// level, and until the scene captured it the last mesh level is used instead.
+func (l *LODObject) SetImposterLevel(distance float64, angles int) {
+	if angles < 1 {
+		angles = 1
+	}
+	if angles > maxImposterAngles {
+		angles = maxImposterAngles
+	}
+	l.removeImposter()
+	l.imposter = &Imposter{dist: distance, angles: angles}
+}
+
+// removeImposter removes the imposter level of l, drawing it with the last
+// mesh level if it was at the imposter level.
+func (l *LODObject) removeImposter() {
+	if l.imposter == nil {
+		return
+	}
+	l.imposter.hide(l)
+	l.imposter = nil
+	if l.level >= len(l.levels) {
+		l.level = len(l.levels) - 1
+		l.Meshes = l.levels[l.level].meshes
+	}
+}
+
+// Imposter returns the imposter level of l, or nil.
+func (l *LODObject) Imposter() *Imposter {
+	return l.imposter
+}
+
+// Angle returns the capture the billboard shows.
+func (im *Imposter) Angle() int {
+	return im.angle
+}
+
+// angleFor returns the capture nearest to the direction dir the object is seen
+// from. Capture i looks Y forward turned by i steps about Z.
+func (im *Imposter) angleFor(dir lmath.Vec3) int {
+	yaw := math.Atan2(-dir.X, dir.Y)
+	step := 2 * math.Pi / float64(im.angles)
+	i := int(math.Floor(yaw/step+0.5)) % im.angles
+	if i < 0 {
+		i += im.angles
+	}
+	return i
+}
+
+// show draws the billboard seen from eye in place of l.
+func (im *Imposter) show(l *LODObject, eye lmath.Vec3) {
+	center, _ := boundingSphere(worldBounds(l.Object))
+	im.shown = true
+	im.angle = im.angleFor(center.Sub(eye))
+	im.billboard.Transform = l.Transform
+	im.billboard.Meshes = im.meshes[im.angle]
+}
+
+// hide draws l as a mesh again.
+func (im *Imposter) hide(l *LODObject) {
+	im.shown = false
+}
+
+// drawn returns the object drawn for o by the imposter of its level of detail,
+// which is o itself unless the billboard is shown.
+func (s *Scene) drawn(o *gfx.Object) *gfx.Object {
+	if b, ok := s.billboards[o]; ok {
+		return b
+	}
+	return o
+}
+
+// RenderImposters captures the imposters not captured yet of the levels of
+// detail of the scene, with the shader inputs their objects were last drawn
+// with. Those of objects not drawn yet, or for which waiting is true such as
+// while their shader loads, are left for a later call. It has to be called
+// before Draw.
+func (s *Scene) RenderImposters(d gfx.Device, waiting func(o *gfx.Object) bool) {
+	for _, l := range s.lods {
+		im := l.imposter
+		if im == nil || im.ready || l.Shader == nil || !l.Shader.Loaded || waiting(l.Object) {
+			continue
+		}
+		if s.imposterShader == nil {
+			sh, err := gfxutil.OpenShader("rtt")
+			if err != nil {
+				log.Println("Imposters disabled:", err)
+				s.disableImposters()
+				return
+			}
+			sh.Inputs["BinaryAlpha"] = true
+			s.imposterShader = sh
+		}
+		if err := im.capture(d, l, s.imposterShader); err != nil {
+			log.Println("Imposters disabled:", err)
+			s.disableImposters()
+			return
+		}
+	}
+}
+
+// disableImposters removes the imposter levels, which cannot be captured.
+func (s *Scene) disableImposters() {
+	for _, l := range s.lods {
+		l.removeImposter()
+		delete(s.billboards, l.Object)
+	}
+}
+
+// capture renders the most detailed level of l from every angle into the
+// atlas, and builds the billboard of each, drawn with shader.
+func (im *Imposter) capture(d gfx.Device, l *LODObject, shader *gfx.Shader) error {
+	cfg := d.Info().RTTFormats.ChooseConfig(gfx.Precision{
+		RedBits: 8, GreenBits: 8, BlueBits: 8, AlphaBits: 8,
+		DepthBits: 24,
+	}, true)
+	atlas := gfx.NewTexture()
+	atlas.MinFilter = gfx.Linear
+	atlas.MagFilter = gfx.Linear
+	atlas.WrapU = gfx.Clamp
+	atlas.WrapV = gfx.Clamp
+	cfg.Color = atlas
+	cfg.Bounds = image.Rect(0, 0, im.angles*imposterSize, imposterSize)
+	c := d.RenderToTexture(cfg)
+	if c == nil {
+		return errors.New("render to texture is not supported")
+	}
+
+	// Draw the object as a mesh, whatever level it is at.
+	o := gfx.NewObject()
+	*o.State = *l.State
+	o.Transform = l.Transform
+	o.Shader = l.Shader
+	o.Textures = l.Textures
+	o.Meshes = l.levels[0].meshes
+
+	center, radius := boundingSphere(worldBounds(o))
+	toLocal := l.Convert(gfx.WorldToLocal)
+	c.Clear(c.Bounds(), gfx.Color{0, 0, 0, 0})
+	c.ClearDepth(c.Bounds(), 1.0)
+	im.meshes = make([][]*gfx.Mesh, im.angles)
+	for i := 0; i < im.angles; i++ {
+		r := image.Rect(i*imposterSize, 0, (i+1)*imposterSize, imposterSize)
+		cam := camera.New(r)
+		cam.FOV = 30
+		cam.Update(r)
+		cam.SetRot(lmath.Vec3{0, 0, 360 * float64(i) / float64(im.angles)})
+		pos := fitSphere(cam, center, radius, 1)
+		cam.SetPos(pos)
+		c.Draw(r, o, cam)
+
+		// The billboard spans the view of the capture where it crosses the
+		// center of the object, facing back along it.
+		forward := cameraForward(cam)
+		right := forward.Cross(lmath.Vec3{0, 0, 1}).Normalized()
+		up := lmath.Vec3{0, 0, 1}
+		h := center.Sub(pos).Length() * math.Tan(lmath.Radians(cam.FOV)/2)
+		corner := func(x, y float64) gfx.Vec3 {
+			p := center.Add(right.MulScalar(x * h)).Add(up.MulScalar(y * h))
+			return gfx.ConvertVec3(transformPoint(toLocal, p))
+		}
+		u0, u1 := float32(i)/float32(im.angles), float32(i+1)/float32(im.angles)
+		m := gfx.NewMesh()
+		m.Vertices = []gfx.Vec3{
+			corner(-1, -1), corner(1, -1), corner(-1, 1),
+			corner(-1, 1), corner(1, -1), corner(1, 1),
+		}
+		m.TexCoords = []gfx.TexCoordSet{{Slice: []gfx.TexCoord{
+			{u0, 1}, {u1, 1}, {u0, 0},
+			{u0, 0}, {u1, 1}, {u1, 0},
+		}}}
+		im.meshes[i] = []*gfx.Mesh{m}
+	}
+	c.Render()
+	im.atlas = atlas
+	im.billboard = gfx.NewObject()
+	*im.billboard.State = *l.State
+	im.billboard.AlphaMode = gfx.BinaryAlpha
+	im.billboard.Shader = shader
+	im.billboard.Textures = []*gfx.Texture{atlas}
+	im.ready = true
+	return nil
+}
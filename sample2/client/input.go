@@ -0,0 +1,147 @@
+package main
+
+import (
+	"image"
+	"time"
+
+	"azul3d.org/engine/gfx/window"
+	"azul3d.org/engine/keyboard"
+	"azul3d.org/engine/mouse"
+)
+
+// InputState collects the window events of a frame so that gestures spanning
+// several events, like double clicks, can be queried in one place.
+type InputState struct {
+	// DoubleClickTime is the longest time allowed between two clicks for them
+	// to count as a double click.
+	DoubleClickTime time.Duration
+
+	// DoubleClickDist is the furthest, in pixels, the cursor may move between
+	// two clicks for them to count as a double click.
+	DoubleClickDist int
+
+	cursor  image.Point
+	delta   image.Point
+	buttons map[mouse.Button]bool
+
+	clicked  bool
+	clickPos image.Point
+
+	lastClick      image.Point
+	lastClickTime  time.Time
+	doubleClicked  bool
+	doubleClickPos image.Point
+
+	// now is the time, in seconds of the update clock, of the current
+	// frame, and presses holds the frame time each key was last pressed at,
+	// until the press is consumed by BufferedPress.
+	now     float64
+	presses map[keyboard.Key]float64
+}
+
+func NewInputState() *InputState {
+	return &InputState{
+		DoubleClickTime: 400 * time.Millisecond,
+		DoubleClickDist: 4,
+		buttons:         make(map[mouse.Button]bool),
+		presses:         make(map[keyboard.Key]float64),
+	}
+}
+
+// Handle updates the input state with the given window event.
+func (s *InputState) Handle(e window.Event) {
+	switch ev := e.(type) {
+	case window.CursorMoved:
+		if ev.Delta {
+			s.delta = s.delta.Add(image.Pt(int(ev.X), int(ev.Y)))
+			break
+		}
+		p := image.Pt(int(ev.X), int(ev.Y))
+		s.delta = s.delta.Add(p.Sub(s.cursor))
+		s.cursor = p
+
+	case mouse.Event:
+		s.buttons[ev.Button] = ev.State == mouse.Down
+		if ev.Button == mouse.Left && ev.State == mouse.Down {
+			s.click(ev.T)
+		}
+
+	case keyboard.ButtonEvent:
+		if ev.State == keyboard.Down {
+			s.presses[ev.Key] = s.now
+		}
+	}
+}
+
+func (s *InputState) click(t time.Time) {
+	s.clicked = true
+	s.clickPos = s.cursor
+
+	d := s.cursor.Sub(s.lastClick)
+	near := d.X*d.X+d.Y*d.Y <= s.DoubleClickDist*s.DoubleClickDist
+	if !s.lastClickTime.IsZero() && t.Sub(s.lastClickTime) <= s.DoubleClickTime && near {
+		s.doubleClicked = true
+		s.doubleClickPos = s.cursor
+
+		// Forget the first click, so that a third click starts a new pair
+		// instead of forming another double click.
+		s.lastClickTime = time.Time{}
+		return
+	}
+	s.lastClick = s.cursor
+	s.lastClickTime = t
+}
+
+// Cursor returns the last known cursor position, in window pixels.
+func (s *InputState) Cursor() image.Point {
+	return s.cursor
+}
+
+// CursorDelta returns how far the cursor moved during this frame, in pixels.
+func (s *InputState) CursorDelta() image.Point {
+	return s.delta
+}
+
+// ButtonDown reports whether the given mouse button is held down.
+func (s *InputState) ButtonDown(b mouse.Button) bool {
+	return s.buttons[b]
+}
+
+// Clicked reports whether the left mouse button was pressed during this frame,
+// and if so the cursor position at the time. The second click of a double
+// click is reported here too.
+func (s *InputState) Clicked() (image.Point, bool) {
+	return s.clickPos, s.clicked
+}
+
+// DoubleClicked reports whether a double click happened during this frame,
+// and if so the cursor position of the second click.
+func (s *InputState) DoubleClicked() (image.Point, bool) {
+	return s.doubleClickPos, s.doubleClicked
+}
+
+// BufferedPress reports whether key was pressed within the last window
+// seconds of the update clock, and consumes the press so that it is only
+// reported once. This lets a press made slightly before an action becomes
+// possible still trigger it.
+func (s *InputState) BufferedPress(key keyboard.Key, window float64) bool {
+	t, ok := s.presses[key]
+	if !ok {
+		return false
+	}
+	delete(s.presses, key)
+	return s.now-t <= window
+}
+
+// BeginFrame advances the clock presses are timed with by the frame time dt, in
+// seconds. Call it before handling the events of the frame.
+func (s *InputState) BeginFrame(dt float64) {
+	s.now += dt
+}
+
+// EndFrame clears the gestures reported for the frame that just ended.
+func (s *InputState) EndFrame() {
+	s.delta = image.Point{}
+	s.clicked = false
+	s.doubleClicked = false
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"azul3d.org/engine/gfx/window"
+)
+
+// InputContext is a layer of input handling. Contexts are stacked, and each
+// event is given to the topmost context first, reaching the ones below only
+// while it is not consumed.
+type InputContext interface {
+	// HandleEvent handles the event and reports whether it was consumed.
+	HandleEvent(e window.Event) bool
+
+	// CapturesKeyboard reports whether the context owns the keyboard for as
+	// long as it is on the stack, including keys that are polled rather than
+	// received as events.
+	CapturesKeyboard() bool
+}
+
+// PushInputContext puts c on top of the input context stack.
+func (g *Game) PushInputContext(c InputContext) {
+	g.contexts = append(g.contexts, c)
+}
+
+// PopInputContext removes the topmost input context, the game itself at the
+// bottom of the stack is never removed.
+func (g *Game) PopInputContext() {
+	if len(g.contexts) > 1 {
+		g.contexts[len(g.contexts)-1] = nil
+		g.contexts = g.contexts[:len(g.contexts)-1]
+	}
+}
+
+// RemoveInputContext removes c from the input context stack, wherever it is
+// in it.
+func (g *Game) RemoveInputContext(c InputContext) {
+	for i := len(g.contexts) - 1; i > 0; i-- {
+		if g.contexts[i] == c {
+			copy(g.contexts[i:], g.contexts[i+1:])
+			g.contexts[len(g.contexts)-1] = nil
+			g.contexts = g.contexts[:len(g.contexts)-1]
+			return
+		}
+	}
+}
+
+// keyboardCaptured reports whether a context on the stack owns the keyboard.
+func (g *Game) keyboardCaptured() bool {
+	for _, c := range g.contexts {
+		if c.CapturesKeyboard() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"azul3d.org/engine/gfx/window"
+	"azul3d.org/engine/keyboard"
+	"azul3d.org/engine/mouse"
+)
+
+// clickAt moves the cursor of s to p and clicks the left button there at t.
+func clickAt(s *InputState, p image.Point, t time.Time) {
+	s.Handle(window.CursorMoved{X: float64(p.X), Y: float64(p.Y)})
+	s.Handle(mouse.Event{Button: mouse.Left, State: mouse.Down, T: t})
+	s.Handle(mouse.Event{Button: mouse.Left, State: mouse.Up, T: t})
+}
+
+func TestDoubleClick(t *testing.T) {
+	start := time.Unix(0, 0)
+	for _, c := range []struct {
+		name  string
+		delay time.Duration
+		to    image.Point
+		want  bool
+	}{
+		{"fast", 200 * time.Millisecond, image.Pt(12, 10), true},
+		{"slow", 600 * time.Millisecond, image.Pt(10, 10), false},
+		{"fast but too far", 200 * time.Millisecond, image.Pt(15, 10), false},
+	} {
+		s := NewInputState()
+		clickAt(s, image.Pt(10, 10), start)
+		s.EndFrame()
+		clickAt(s, c.to, start.Add(c.delay))
+		p, ok := s.DoubleClicked()
+		if ok != c.want {
+			t.Errorf("%s: double clicked %v, want %v", c.name, ok, c.want)
+		}
+		if ok && p != c.to {
+			t.Errorf("%s: double clicked at %v, want %v", c.name, p, c.to)
+		}
+		if _, ok := s.Clicked(); !ok {
+			t.Errorf("%s: the second click was not reported as a click", c.name)
+		}
+	}
+
+	// The double click is reported for the frame it happened in only, and a
+	// third click starts a new pair.
+	s := NewInputState()
+	clickAt(s, image.Pt(10, 10), start)
+	clickAt(s, image.Pt(10, 10), start.Add(100*time.Millisecond))
+	if _, ok := s.DoubleClicked(); !ok {
+		t.Fatal("no double click")
+	}
+	s.EndFrame()
+	if _, ok := s.DoubleClicked(); ok {
+		t.Errorf("the double click was reported again the next frame")
+	}
+	clickAt(s, image.Pt(10, 10), start.Add(200*time.Millisecond))
+	if _, ok := s.DoubleClicked(); ok {
+		t.Errorf("a third click made another double click")
+	}
+}
+
+func TestBufferedPress(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		frames []float64 // frame times after the press
+		window float64
+		want   bool
+	}{
+		{"same frame", nil, 0.15, true},
+		{"within the window", []float64{0.0625, 0.0625}, 0.15, true},
+		{"at the end of the window", []float64{0.125}, 0.125, true},
+		{"expired", []float64{0.0625, 0.0625, 0.0625}, 0.15, false},
+	} {
+		s := NewInputState()
+		s.BeginFrame(1)
+		s.Handle(keyboard.ButtonEvent{Key: keyboard.Space, State: keyboard.Down})
+		for _, dt := range c.frames {
+			s.EndFrame()
+			s.BeginFrame(dt)
+		}
+		if got := s.BufferedPress(keyboard.Space, c.window); got != c.want {
+			t.Errorf("%s: pressed %v, want %v", c.name, got, c.want)
+		}
+
+		// Whether it was in time or not, the press is consumed.
+		if s.BufferedPress(keyboard.Space, c.window) {
+			t.Errorf("%s: the press was reported twice", c.name)
+		}
+	}
+
+	s := NewInputState()
+	s.Handle(keyboard.ButtonEvent{Key: keyboard.Space, State: keyboard.Down})
+	if s.BufferedPress(keyboard.Enter, 1) {
+		t.Errorf("a press of another key was reported")
+	}
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// maxInstancePoints is the most points a point cloud file may hold, so that a
+// corrupt count fails instead of allocating huge slices.
+const maxInstancePoints = 1 << 24
+
+// LoadInstancePositions reads the points of a point cloud file: either a PLY
+// file, ASCII or binary, whose vertex element has x, y and z properties, or a
+// list of points one per line with their coordinates separated by spaces or
+// commas. Lines starting with # are skipped, and columns after the third are
+// ignored.
+func LoadInstancePositions(path string) ([]lmath.Vec3, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReaderSize(f, 1<<16)
+	if magic, _ := r.Peek(4); len(magic) == 4 && string(magic[:3]) == "ply" && (magic[3] == '\n' || magic[3] == '\r') {
+		return readPLYPositions(path, r)
+	}
+	return readXYZPositions(path, r)
+}
+
+// readXYZPositions reads a list of points one per line.
+func readXYZPositions(path string, r io.Reader) ([]lmath.Vec3, error) {
+	var (
+		points []lmath.Vec3
+		err    error
+	)
+	separator := func(c rune) bool {
+		return c == ' ' || c == '\t' || c == ',' || c == '\r'
+	}
+	s := bufio.NewScanner(r)
+	line := 0
+	for s.Scan() {
+		line++
+		fields := strings.FieldsFunc(s.Text(), separator)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%s:%d: expected three coordinates", path, line)
+		}
+		var v [3]float64
+		for i := range v {
+			if v[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+			}
+		}
+		if len(points) == maxInstancePoints {
+			return nil, fmt.Errorf("%s: more than %d points", path, maxInstancePoints)
+		}
+		points = append(points, lmath.Vec3{v[0], v[1], v[2]})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+// plySizes is the size in bytes of the PLY property types.
+var plySizes = map[string]int{
+	"char": 1, "uchar": 1, "int8": 1, "uint8": 1,
+	"short": 2, "ushort": 2, "int16": 2, "uint16": 2,
+	"int": 4, "uint": 4, "int32": 4, "uint32": 4,
+	"float": 4, "float32": 4,
+	"double": 8, "float64": 8,
+}
+
+// plyProperty is a scalar property of the PLY vertex element.
+type plyProperty struct {
+	name, typ string
+	offset    int
+}
+
+// readPLYPositions reads the x, y and z properties of the vertex element of a
+// PLY file, which has to be its first element.
+func readPLYPositions(path string, r *bufio.Reader) ([]lmath.Vec3, error) {
+	var (
+		format   string
+		count    = -1
+		props    []plyProperty
+		rowSize  int
+		inVertex bool
+	)
+	for line := 1; ; line++ {
+		text, err := r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("%s: missing end_header", path)
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "format":
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("%s:%d: bad format", path, line)
+			}
+			format = fields[1]
+		case "element":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("%s:%d: bad element", path, line)
+			}
+			inVertex = fields[1] == "vertex"
+			if inVertex {
+				n, err := strconv.Atoi(fields[2])
+				if err != nil || n < 0 || n > maxInstancePoints {
+					return nil, fmt.Errorf("%s:%d: bad vertex count %q", path, line, fields[2])
+				}
+				count = n
+			} else if count < 0 {
+				return nil, fmt.Errorf("%s:%d: the vertex element has to come first", path, line)
+			}
+		case "property":
+			if !inVertex {
+				continue
+			}
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("%s:%d: vertex list properties are not supported", path, line)
+			}
+			size, ok := plySizes[fields[1]]
+			if !ok {
+				return nil, fmt.Errorf("%s:%d: unknown property type %q", path, line, fields[1])
+			}
+			props = append(props, plyProperty{name: fields[2], typ: fields[1], offset: rowSize})
+			rowSize += size
+		}
+		if fields[0] == "end_header" {
+			break
+		}
+	}
+	if count < 0 {
+		return nil, fmt.Errorf("%s: no vertex element", path)
+	}
+	var xyz [3]int
+	for i, name := range []string{"x", "y", "z"} {
+		xyz[i] = -1
+		for j, p := range props {
+			if p.name == name {
+				xyz[i] = j
+			}
+		}
+		if xyz[i] < 0 {
+			return nil, fmt.Errorf("%s: the vertex element has no %s property", path, name)
+		}
+	}
+
+	points := make([]lmath.Vec3, count)
+	switch format {
+	case "ascii":
+		for i := range points {
+			text, err := r.ReadString('\n')
+			if err != nil && (err != io.EOF || text == "") {
+				return nil, fmt.Errorf("%s: %d vertices, expected %d", path, i, count)
+			}
+			fields := strings.Fields(text)
+			if len(fields) != len(props) {
+				return nil, fmt.Errorf("%s: vertex %d has %d values, expected %d", path, i, len(fields), len(props))
+			}
+			var v [3]float64
+			for k, j := range xyz {
+				if v[k], err = strconv.ParseFloat(fields[j], 64); err != nil {
+					return nil, fmt.Errorf("%s: vertex %d: %v", path, i, err)
+				}
+			}
+			points[i] = lmath.Vec3{v[0], v[1], v[2]}
+		}
+	case "binary_little_endian", "binary_big_endian":
+		var order binary.ByteOrder = binary.LittleEndian
+		if format == "binary_big_endian" {
+			order = binary.BigEndian
+		}
+		row := make([]byte, rowSize)
+		for i := range points {
+			if _, err := io.ReadFull(r, row); err != nil {
+				return nil, fmt.Errorf("%s: %d vertices, expected %d", path, i, count)
+			}
+			var v [3]float64
+			for k, j := range xyz {
+				v[k] = plyValue(order, row[props[j].offset:], props[j].typ)
+			}
+			points[i] = lmath.Vec3{v[0], v[1], v[2]}
+		}
+	default:
+		return nil, fmt.Errorf("%s: unknown format %q", path, format)
+	}
+	return points, nil
+}
+
+// plyValue decodes the binary PLY value of type typ at the start of b.
+func plyValue(order binary.ByteOrder, b []byte, typ string) float64 {
+	switch typ {
+	case "char", "int8":
+		return float64(int8(b[0]))
+	case "uchar", "uint8":
+		return float64(b[0])
+	case "short", "int16":
+		return float64(int16(order.Uint16(b)))
+	case "ushort", "uint16":
+		return float64(order.Uint16(b))
+	case "int", "int32":
+		return float64(int32(order.Uint32(b)))
+	case "uint", "uint32":
+		return float64(order.Uint32(b))
+	case "float", "float32":
+		return float64(math.Float32frombits(order.Uint32(b)))
+	default:
+		return math.Float64frombits(order.Uint64(b))
+	}
+}
+
+// InstancedObject draws a mesh at many positions. The gfx devices cannot draw
+// instances natively, so the copies are combined into one mesh per cell of a
+// square grid, each drawn with a single draw call. The whole group is culled
+// at once, and the cells inside the view one by one.
+//
+// The embedded object holds the shader, textures and state of the instances,
+// and its transform places the positions in the world; its meshes are unused.
+type InstancedObject struct {
+	*gfx.Object
+
+	mesh      *gfx.Mesh
+	positions []lmath.Vec3
+
+	// Per point random rotation about Z, in degrees, and scale.
+	seed               int64
+	maxRotation        float64
+	minScale, maxScale float64
+	cellSize           float64
+
+	// cells are the combined meshes, and bounds the local bounds of all of
+	// them. cellShader is the shader of the object the cells were last
+	// given.
+	cells      []*gfx.Object
+	bounds     lmath.Rect3
+	built      bool
+	cellShader *gfx.Shader
+}
+
+// NewInstancedObjectFromPoints returns an object drawing mesh at each of the
+// positions in a single draw call, without random rotation nor scale. See
+// SetCellSize to cull them by parts instead.
+func NewInstancedObjectFromPoints(mesh *gfx.Mesh, positions []lmath.Vec3) *InstancedObject {
+	o := gfx.NewObject()
+	o.State = gfx.NewState()
+	return &InstancedObject{
+		Object:    o,
+		mesh:      mesh,
+		positions: positions,
+		minScale:  1,
+		maxScale:  1,
+	}
+}
+
+// SetRandomTransform rotates every instance by up to maxRotation degrees about
+// Z and scales it between minScale and maxScale. The values of each point only
+// depend on seed and its index, so a seed always gives the same result.
+func (inst *InstancedObject) SetRandomTransform(seed int64, maxRotation, minScale, maxScale float64) {
+	inst.seed = seed
+	inst.maxRotation = maxRotation
+	inst.minScale, inst.maxScale = minScale, maxScale
+	inst.built = false
+}
+
+// SetRandomSeed scatters the instances again from seed, keeping the ranges
+// given to SetRandomTransform.
+func (inst *InstancedObject) SetRandomSeed(seed int64) {
+	inst.seed = seed
+	inst.built = false
+}
+
+// SetCellSize sets the width of the grid cells the instances are grouped
+// into, each culled by itself and drawn with a call of its own. Zero, the
+// default, puts them all in one.
+func (inst *InstancedObject) SetCellSize(size float64) {
+	inst.cellSize = size
+	inst.built = false
+}
+
+// Cells returns how many draw calls the instances are drawn with at most.
+func (inst *InstancedObject) Cells() int {
+	inst.build()
+	return len(inst.cells)
+}
+
+// pointRandom returns two numbers in [0, 1) for the point i.
+func (inst *InstancedObject) pointRandom(i int) (a, b float64) {
+	x := mix64(uint64(inst.seed) ^ mix64(uint64(2*i)))
+	y := mix64(uint64(inst.seed) ^ mix64(uint64(2*i+1)))
+	return float64(x>>11) / (1 << 53), float64(y>>11) / (1 << 53)
+}
+
+// build groups the positions into cells and combines the transformed copies of
+// the mesh of each, if it was not done since the last change.
+func (inst *InstancedObject) build() {
+	if inst.built {
+		return
+	}
+	type cellKey struct{ x, y int }
+	var (
+		groups = make(map[cellKey][]int)
+		order  []cellKey
+	)
+	for i, p := range inst.positions {
+		var k cellKey
+		if inst.cellSize > 0 {
+			k = cellKey{int(math.Floor(p.X / inst.cellSize)), int(math.Floor(p.Y / inst.cellSize))}
+		}
+		if _, ok := groups[k]; !ok {
+			order = append(order, k)
+		}
+		groups[k] = append(groups[k], i)
+	}
+
+	inst.mesh.RLock()
+	defer inst.mesh.RUnlock()
+	inst.cells = inst.cells[:0]
+	for _, k := range order {
+		cell := gfx.NewObject()
+		cell.State = inst.State
+		cell.Shader = inst.Shader
+		cell.Transform = inst.Transform
+		cell.Meshes = []*gfx.Mesh{inst.combine(groups[k])}
+		if b := cell.Bounds(); len(inst.cells) == 0 {
+			inst.bounds = b
+		} else {
+			inst.bounds.Min = lmath.Vec3{math.Min(inst.bounds.Min.X, b.Min.X), math.Min(inst.bounds.Min.Y, b.Min.Y), math.Min(inst.bounds.Min.Z, b.Min.Z)}
+			inst.bounds.Max = lmath.Vec3{math.Max(inst.bounds.Max.X, b.Max.X), math.Max(inst.bounds.Max.Y, b.Max.Y), math.Max(inst.bounds.Max.Z, b.Max.Z)}
+		}
+		inst.cells = append(inst.cells, cell)
+	}
+	inst.built = true
+	inst.cellShader = inst.Shader
+}
+
+// combine returns one mesh with a copy of the instanced mesh at each of the
+// given points.
+func (inst *InstancedObject) combine(points []int) *gfx.Mesh {
+	src := inst.mesh
+	out := gfx.NewMesh()
+	n := len(src.Vertices) * len(points)
+	out.Vertices = make([]gfx.Vec3, 0, n)
+	hasNormals := len(src.Normals) == len(src.Vertices)
+	hasColors := len(src.Colors) == len(src.Vertices)
+	hasTexCoords := len(src.TexCoords) > 0 && len(src.TexCoords[0].Slice) == len(src.Vertices)
+	if hasNormals {
+		out.Normals = make([]gfx.Vec3, 0, n)
+	}
+	if hasColors {
+		out.Colors = make([]gfx.Color, 0, n)
+	}
+	if hasTexCoords {
+		out.TexCoords = []gfx.TexCoordSet{{Slice: make([]gfx.TexCoord, 0, n)}}
+	}
+	for _, i := range points {
+		a, b := inst.pointRandom(i)
+		angle := (a*2 - 1) * inst.maxRotation * math.Pi / 180
+		scale := inst.minScale + b*(inst.maxScale-inst.minScale)
+		sin, cos := math.Sincos(angle)
+		p := inst.positions[i]
+
+		base := uint32(len(out.Vertices))
+		for j, v := range src.Vertices {
+			x, y, z := float64(v.X)*scale, float64(v.Y)*scale, float64(v.Z)*scale
+			out.Vertices = append(out.Vertices, gfx.Vec3{
+				X: float32(p.X + x*cos - y*sin),
+				Y: float32(p.Y + x*sin + y*cos),
+				Z: float32(p.Z + z),
+			})
+			if hasNormals {
+				nm := src.Normals[j]
+				out.Normals = append(out.Normals, gfx.Vec3{
+					X: float32(float64(nm.X)*cos - float64(nm.Y)*sin),
+					Y: float32(float64(nm.X)*sin + float64(nm.Y)*cos),
+					Z: nm.Z,
+				})
+			}
+			if hasColors {
+				out.Colors = append(out.Colors, src.Colors[j])
+			}
+			if hasTexCoords {
+				out.TexCoords[0].Slice = append(out.TexCoords[0].Slice, src.TexCoords[0].Slice[j])
+			}
+		}
+		if len(src.Indices) == 0 {
+			for j := range src.Vertices {
+				out.Indices = append(out.Indices, base+uint32(j))
+			}
+		}
+		for _, j := range src.Indices {
+			out.Indices = append(out.Indices, base+j)
+		}
+	}
+	return out
+}
+
+// visibleCells returns the cells of the group if any of it is inside the
+// view of vp, adding the cells culled with the group to stats.
+func (inst *InstancedObject) visibleCells(vp lmath.Mat4, stats *RenderStats) []*gfx.Object {
+	inst.build()
+	stats.Objects += len(inst.cells)
+	if len(inst.cells) == 0 {
+		return nil
+	}
+	if !inFrustum(vp, transformBox(inst.Convert(gfx.LocalToWorld), inst.bounds)) {
+		stats.Culled += len(inst.cells)
+		return nil
+	}
+	stats.Tested += len(inst.cells)
+
+	// The cells are drawn like the embedded object, whatever it was set to
+	// since they were built. Its shader is only given to them when it is
+	// replaced, so that the copies drawing them lit are kept.
+	setShader := inst.Shader != inst.cellShader
+	inst.cellShader = inst.Shader
+	for _, c := range inst.cells {
+		c.State = inst.State
+		if setShader {
+			c.Shader = inst.Shader
+		}
+		c.Textures = inst.Textures
+		c.Transform = inst.Transform
+	}
+	return inst.cells
+}
+
+// AddInstances adds the instanced object inst to the scene.
+func (s *Scene) AddInstances(inst *InstancedObject) {
+	s.instances = append(s.instances, inst)
+}
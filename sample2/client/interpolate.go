@@ -0,0 +1,125 @@
+package main
+
+import (
+	"azul3d.org/engine/lmath"
+)
+
+// FixedStep runs a simulation in steps of a fixed length, however long the
+// frames are, carrying the time left over from one frame to the next.
+type FixedStep struct {
+	// Step is the length of one simulation step, in seconds.
+	Step float64
+
+	// MaxSteps is the most steps run in one frame, so that a long stall
+	// does not take ever longer frames to catch up with.
+	MaxSteps int
+
+	acc float64
+}
+
+// NewFixedStep returns a fixed step driver running rate steps per second.
+func NewFixedStep(rate int) *FixedStep {
+	return &FixedStep{
+		Step:     1 / float64(rate),
+		MaxSteps: 8,
+	}
+}
+
+// Advance adds dt seconds to the time to simulate and calls step once for
+// every whole step of it. It returns how far, from 0 to 1, the time left is
+// into the next step.
+func (f *FixedStep) Advance(dt float64, step func(dt float64)) float64 {
+	f.acc += dt
+	for n := 0; f.acc >= f.Step; n++ {
+		if n == f.MaxSteps {
+			f.acc = 0
+			break
+		}
+		step(f.Step)
+		f.acc -= f.Step
+	}
+	return f.acc / f.Step
+}
+
+// simTransform is the transform of an object after a simulation step.
+type simTransform struct {
+	pos, scale lmath.Vec3
+	rot        lmath.Quat
+}
+
+// SetInterpolated sets whether o is drawn between its transforms after the
+// last two steps of FixedUpdate, rather than jumping from one to the next.
+func (o *Object) SetInterpolated(interpolated bool) {
+	o.interpolated = interpolated
+	o.simValid = false
+}
+
+func (o *Object) simTransform() simTransform {
+	return simTransform{pos: o.Pos(), scale: o.Scale(), rot: o.Quat()}
+}
+
+func (o *Object) setSimTransform(t simTransform) {
+	o.SetPos(t.pos)
+	o.SetScale(t.scale)
+	o.SetQuat(t.rot)
+}
+
+// FixedUpdate advances the simulation by dt seconds, calling step for every
+// whole step of f. Interpolated objects are then placed between their last
+// two simulated transforms by the time left over, which makes them lag one
+// step behind but move smoothly at any frame rate. Steps moving an object
+// further than TeleportDistance make it jump instead of sliding across.
+func (s *Scene) FixedUpdate(f *FixedStep, dt float64, step func(dt float64)) {
+	// Give the simulation back the transforms it left, rather than the
+	// interpolated ones drawn.
+	for _, o := range s.objects {
+		if o.interpolated && o.simValid {
+			o.setSimTransform(o.cur)
+		}
+	}
+
+	alpha := f.Advance(dt, func(dt float64) {
+		for _, o := range s.objects {
+			if o.interpolated {
+				o.prev = o.simTransform()
+			}
+		}
+		step(dt)
+		for _, o := range s.objects {
+			if !o.interpolated {
+				continue
+			}
+			o.cur = o.simTransform()
+			if !o.simValid || o.cur.pos.Sub(o.prev.pos).Length() > s.TeleportDistance {
+				o.prev = o.cur
+			}
+			o.simValid = true
+		}
+	})
+
+	for _, o := range s.objects {
+		if o.interpolated && o.simValid {
+			o.setSimTransform(lerpSim(o.prev, o.cur, alpha))
+		}
+	}
+}
+
+// lerpSim returns the transform the fraction t of the way from a to b.
+func lerpSim(a, b simTransform, t float64) simTransform {
+	// Take the short way around, q and -q being the same rotation.
+	q := b.rot
+	if a.rot.W*q.W+a.rot.X*q.X+a.rot.Y*q.Y+a.rot.Z*q.Z < 0 {
+		q = lmath.Quat{W: -q.W, X: -q.X, Y: -q.Y, Z: -q.Z}
+	}
+	rot := lmath.Quat{
+		W: a.rot.W + (q.W-a.rot.W)*t,
+		X: a.rot.X + (q.X-a.rot.X)*t,
+		Y: a.rot.Y + (q.Y-a.rot.Y)*t,
+		Z: a.rot.Z + (q.Z-a.rot.Z)*t,
+	}
+	return simTransform{
+		pos:   a.pos.Lerp(b.pos, t),
+		scale: a.scale.Lerp(b.scale, t),
+		rot:   rot.Normalized(),
+	}
+}
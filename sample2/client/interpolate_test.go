@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+func TestFixedStepAdvance(t *testing.T) {
+	f := NewFixedStep(4)
+	steps := 0
+	step := func(dt float64) {
+		if dt != 0.25 {
+			t.Fatalf("stepped by %v, want 0.25", dt)
+		}
+		steps++
+	}
+
+	// Shorter frames than the step carry over until they add up to one.
+	if alpha := f.Advance(0.125, step); steps != 0 || alpha != 0.5 {
+		t.Fatalf("%d steps, alpha %v; want 0 steps, alpha 0.5", steps, alpha)
+	}
+	if alpha := f.Advance(0.5, step); steps != 2 || alpha != 0.5 {
+		t.Fatalf("%d steps, alpha %v; want 2 steps, alpha 0.5", steps, alpha)
+	}
+
+	// A stall runs no more than MaxSteps, and drops the time left.
+	steps = 0
+	if alpha := f.Advance(10, step); steps != f.MaxSteps || alpha != 0 {
+		t.Fatalf("%d steps, alpha %v; want %d steps, alpha 0", steps, alpha, f.MaxSteps)
+	}
+}
+
+// movingScene returns a scene with one object, interpolated or not, and a
+// simulation step moving it speed along X.
+func movingScene(interpolated bool, speed float64) (*Scene, *Object, func(dt float64)) {
+	s := NewScene()
+	o := &Object{Object: gfx.NewObject()}
+	o.SetInterpolated(interpolated)
+	s.Add(o)
+	step := func(dt float64) {
+		p := o.Pos()
+		p.X += speed * dt
+		o.SetPos(p)
+	}
+	return s, o, step
+}
+
+func TestFixedUpdateInterpolates(t *testing.T) {
+	// A 32Hz simulation drawn at 128Hz moves a quarter of a step every
+	// frame, rather than a whole step every fourth frame. The rates are
+	// powers of two so that the frame times add up exactly.
+	s, o, step := movingScene(true, 32)
+	f := NewFixedStep(32)
+	frame := 1.0 / 128
+	// The first step has no previous one to come from, so run two.
+	s.FixedUpdate(f, 2.0/32, step)
+	last := o.Pos().X
+	for i := 0; i < 12; i++ {
+		s.FixedUpdate(f, frame, step)
+		x := o.Pos().X
+		if d := x - last; math.Abs(d-0.25) > 1e-6 {
+			t.Fatalf("frame %d moved %v, want 0.25", i, d)
+		}
+		last = x
+	}
+
+	// The simulation sees its own transforms, not the drawn ones.
+	f = NewFixedStep(32)
+	s, o, _ = movingScene(true, 0)
+	var simX []float64
+	record := func(dt float64) {
+		simX = append(simX, o.Pos().X)
+		o.SetPos(o.Pos().Add(lmath.Vec3{1, 0, 0}))
+	}
+	for i := 0; i < 8; i++ {
+		s.FixedUpdate(f, frame*2, record)
+	}
+	for i, x := range simX {
+		if x != float64(i) {
+			t.Fatalf("step %d saw X %v, want %d", i, x, i)
+		}
+	}
+}
+
+func TestFixedUpdateNotInterpolated(t *testing.T) {
+	// Without interpolation the object only moves on the frames a step
+	// runs, a whole step at a time.
+	s, o, step := movingScene(false, 32)
+	f := NewFixedStep(32)
+	frame := 1.0 / 128
+	last := o.Pos().X
+	moves := 0
+	for i := 0; i < 12; i++ {
+		s.FixedUpdate(f, frame, step)
+		x := o.Pos().X
+		if d := x - last; d != 0 {
+			if math.Abs(d-1) > 1e-6 {
+				t.Fatalf("frame %d moved %v, want 0 or 1", i, d)
+			}
+			moves++
+		}
+		last = x
+	}
+	if moves != 3 {
+		t.Fatalf("moved on %d frames, want 3", moves)
+	}
+}
+
+func TestFixedUpdateTeleport(t *testing.T) {
+	s, o, step := movingScene(true, 32)
+	s.TeleportDistance = 5
+	f := NewFixedStep(32)
+	s.FixedUpdate(f, 1.0/32, step)
+
+	// A step jumping further than TeleportDistance is drawn there at once,
+	// not slid across over the next frames.
+	jump := func(dt float64) {
+		o.SetPos(lmath.Vec3{100, 0, 0})
+	}
+	s.FixedUpdate(f, 1.0/32, jump)
+	if x := o.Pos().X; x != 100 {
+		t.Fatalf("drawn at X %v after the teleporting step, want 100", x)
+	}
+	s.FixedUpdate(f, 1.0/64, func(dt float64) {})
+	if x := o.Pos().X; x != 100 {
+		t.Fatalf("drawn at X %v after the teleport, want 100", x)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// MaxObjectLights is the number of point lights the scene shader sums for one
+// object. Objects reached by more lights only get the nearest ones.
+const MaxObjectLights = 4
+
+// DirectionalLight lights every object of the scene from the same direction,
+// like the sun.
+type DirectionalLight struct {
+	// Dir is the world space direction the light travels in.
+	Dir lmath.Vec3
+
+	// Color is the color of the light, and Ambient the color every surface
+	// receives regardless of where it faces.
+	Color, Ambient gfx.Color
+}
+
+// PointLight lights the objects near its position, fading out to nothing at
+// the radius.
+type PointLight struct {
+	Pos    lmath.Vec3
+	Color  gfx.Color
+	Radius float64
+
+	// Falloff is the exponent of the attenuation curve (1 - d/Radius)^Falloff
+	// at distance d, with larger values fading out faster.
+	Falloff float64
+}
+
+func NewPointLight(pos lmath.Vec3, color gfx.Color, radius float64) *PointLight {
+	return &PointLight{
+		Pos:     pos,
+		Color:   color,
+		Radius:  radius,
+		Falloff: 2,
+	}
+}
+
+// AddLight adds the point light l to the scene.
+func (s *Scene) AddLight(l *PointLight) {
+	s.lights = append(s.lights, l)
+}
+
+// lit reports whether the scene has any light, and is drawn with lighting.
+func (s *Scene) lit() bool {
+	return s.Sun != nil || len(s.lights) > 0
+}
+
+// lightInputNames are the names of the inputs of each point light slot of
+// the scene shader.
+var lightInputNames = func() (names [MaxObjectLights]struct{ pos, color, radius, falloff string }) {
+	for i := range names {
+		names[i].pos = fmt.Sprintf("LightPos%d", i)
+		names[i].color = fmt.Sprintf("LightColor%d", i)
+		names[i].radius = fmt.Sprintf("LightRadius%d", i)
+		names[i].falloff = fmt.Sprintf("LightFalloff%d", i)
+	}
+	return
+}()
+
+// litKey identifies the copy of a shader drawing the objects reached by the
+// same point lights, in the order of the scene, and which receive shadows
+// alike.
+type litKey struct {
+	base    *gfx.Shader
+	lights  [MaxObjectLights]*PointLight
+	receive bool
+}
+
+// litShader is a copy of a shader with the inputs of a set of lights, and the
+// update it was last drawn in.
+type litShader struct {
+	shader *gfx.Shader
+	update int
+}
+
+// setLightInputs passes the directional light and the point lights nearest to
+// o to its shader. Lights whose radius does not reach the bounding sphere of o
+// are skipped. Objects with a shader of their own get the inputs on it, the
+// others share a copy of their shader with the objects reached by the same
+// lights, so that they still draw with few shaders.
+func (s *Scene) setLightInputs(o *gfx.Object) {
+	p, ok := props[o]
+	owns := ok && p.shader != nil && o.Shader == p.shader
+	if !s.lit() {
+		switch {
+		case owns:
+			o.Shader.Lock()
+			o.Shader.Inputs["Lighting"] = false
+			o.Shader.Unlock()
+		case ok && p.lit != nil && o.Shader == p.lit:
+			o.Shader = p.baseShader
+			p.lit = nil
+		}
+		return
+	}
+
+	center, radius := boundingSphere(worldBounds(o))
+	s.nearLights = s.nearLights[:0]
+	for _, l := range s.lights {
+		if l.Pos.Sub(center).Length() < l.Radius+radius {
+			s.nearLights = append(s.nearLights, l)
+		}
+	}
+	if len(s.nearLights) > MaxObjectLights {
+		sort.SliceStable(s.nearLights, func(i, j int) bool {
+			return s.nearLights[i].Pos.Sub(center).Length() < s.nearLights[j].Pos.Sub(center).Length()
+		})
+		s.nearLights = s.nearLights[:MaxObjectLights]
+		// Back to the order of the scene, so that the same lights always
+		// make the same key.
+		sort.SliceStable(s.nearLights, func(i, j int) bool {
+			return s.lightIndex(s.nearLights[i]) < s.lightIndex(s.nearLights[j])
+		})
+	}
+
+	if owns {
+		sh := o.Shader
+		sh.Lock()
+		s.writeLightInputs(sh)
+		s.setShadowInputs(o, sh)
+		sh.Unlock()
+		return
+	}
+
+	key := litKey{base: baseShader(o), receive: s.receivesShadowMap(o)}
+	copy(key.lights[:], s.nearLights)
+	lit, found := s.litShaders[key]
+	if !found {
+		lit = &litShader{shader: copyShader(key.base), update: -1}
+		if s.litShaders == nil {
+			s.litShaders = make(map[litKey]*litShader)
+		}
+		s.litShaders[key] = lit
+	}
+	p = propsOf(o)
+	o.Shader = lit.shader
+	p.lit, p.baseShader = lit.shader, key.base
+
+	sh := lit.shader
+	sh.Lock()
+	if lit.update != s.updates {
+		// Follow the inputs set on the shader it copies, once a frame.
+		lit.update = s.updates
+		key.base.RLock()
+		for k, v := range key.base.Inputs {
+			sh.Inputs[k] = v
+		}
+		key.base.RUnlock()
+		s.writeLightInputs(sh)
+	}
+	s.setShadowInputs(o, sh)
+	sh.Unlock()
+}
+
+// writeLightInputs sets the inputs of the directional light and of the lights
+// in nearLights on sh, which must be locked.
+func (s *Scene) writeLightInputs(sh *gfx.Shader) {
+	sh.Inputs["Lighting"] = true
+	if s.Sun != nil {
+		sh.Inputs["SunDir"] = gfx.ConvertVec3(s.Sun.Dir.Normalized())
+		sh.Inputs["SunColor"] = s.Sun.Color
+		sh.Inputs["Ambient"] = s.Sun.Ambient
+	} else {
+		sh.Inputs["SunColor"] = gfx.Color{}
+		sh.Inputs["Ambient"] = gfx.Color{}
+	}
+	for i, names := range lightInputNames {
+		l := &PointLight{}
+		if i < len(s.nearLights) {
+			l = s.nearLights[i]
+		}
+		sh.Inputs[names.pos] = gfx.ConvertVec3(l.Pos)
+		sh.Inputs[names.color] = l.Color
+		sh.Inputs[names.radius] = float32(l.Radius)
+		sh.Inputs[names.falloff] = float32(l.Falloff)
+	}
+}
+
+// lightIndex returns the index of l among the lights of the scene.
+func (s *Scene) lightIndex(l *PointLight) int {
+	for i, v := range s.lights {
+		if v == l {
+			return i
+		}
+	}
+	return -1
+}
+
+// pruneLitShaders forgets the lit copies not drawn with during the last
+// update, such as those of lights no longer reaching anything.
+func (s *Scene) pruneLitShaders() {
+	for k, lit := range s.litShaders {
+		if lit.update < s.updates-1 {
+			delete(s.litShaders, k)
+		}
+	}
+}
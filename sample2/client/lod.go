@@ -0,0 +1,131 @@
+package main
+
+import (
+	"sort"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// lodLevel is one level of detail of a LODObject.
+type lodLevel struct {
+	dist   float64
+	meshes []*gfx.Mesh
+}
+
+// LODObject swaps the meshes of an object for simpler ones as the camera gets
+// further away from it. Level 0 is the most detailed, and the imposter, if
+// any, comes after the last mesh level.
+type LODObject struct {
+	*gfx.Object
+
+	// OnChange, if set, is called with the new level whenever it changes.
+	OnChange func(level int)
+
+	levels   []lodLevel
+	imposter *Imposter
+	level    int
+	margin   float64
+}
+
+// NewLODObject returns a LODObject switching the meshes of o, which are its
+// most detailed level.
+func NewLODObject(o *gfx.Object) *LODObject {
+	return &LODObject{
+		Object: o,
+		levels: []lodLevel{{meshes: o.Meshes}},
+	}
+}
+
+// AddLevel adds a level of detail used from dist away from the camera and on,
+// until the next level starts.
+func (l *LODObject) AddLevel(dist float64, meshes []*gfx.Mesh) {
+	l.levels = append(l.levels, lodLevel{dist: dist, meshes: meshes})
+	sort.SliceStable(l.levels[1:], func(i, j int) bool {
+		return l.levels[1+i].dist < l.levels[1+j].dist
+	})
+}
+
+// SetHysteresis sets how far past the distance of a level the camera has to
+// go for the level to change, in either direction, so that hovering around
+// the distance does not flip between levels. Zero switches right at it.
+func (l *LODObject) SetHysteresis(margin float64) {
+	if margin < 0 {
+		margin = 0
+	}
+	l.margin = margin
+}
+
+// Level returns the level of detail in use, which is the number of mesh
+// levels for the imposter.
+func (l *LODObject) Level() int {
+	return l.level
+}
+
+// numLevels returns the number of levels, with the imposter if it is captured.
+func (l *LODObject) numLevels() int {
+	if l.imposter != nil && l.imposter.ready {
+		return len(l.levels) + 1
+	}
+	return len(l.levels)
+}
+
+// levelDist returns the distance the level i starts at.
+func (l *LODObject) levelDist(i int) float64 {
+	if i == len(l.levels) {
+		return l.imposter.dist
+	}
+	return l.levels[i].dist
+}
+
+// Update switches to the level for a camera at eye.
+func (l *LODObject) Update(eye lmath.Vec3) {
+	center, _ := boundingSphere(worldBounds(l.Object))
+	dist := eye.Sub(center).Length()
+
+	// Step one level at a time, each needing the margin past its distance, so
+	// a large jump still ends up at the right level.
+	level := l.level
+	for level+1 < l.numLevels() && dist >= l.levelDist(level+1)+l.margin {
+		level++
+	}
+	for level > 0 && dist < l.levelDist(level)-l.margin {
+		level--
+	}
+
+	// The billboard turns to the capture the object is seen from at every
+	// update, not only when the level changes.
+	if level == len(l.levels) {
+		l.imposter.show(l, eye)
+	}
+	if level == l.level {
+		return
+	}
+	if l.level == len(l.levels) {
+		l.imposter.hide(l)
+	}
+	l.level = level
+	if level < len(l.levels) {
+		l.Meshes = l.levels[level].meshes
+	}
+	if l.OnChange != nil {
+		l.OnChange(level)
+	}
+}
+
+// AddLOD makes the scene switch the level of detail of l as it is drawn.
+func (s *Scene) AddLOD(l *LODObject) {
+	s.lods = append(s.lods, l)
+}
+
+// updateLODs switches the levels of detail for the camera at eye.
+func (s *Scene) updateLODs(eye lmath.Vec3) {
+	for _, l := range s.lods {
+		l.Update(eye)
+		if im := l.imposter; im != nil && im.shown {
+			s.billboards[l.Object] = im.billboard
+		} else {
+			delete(s.billboards, l.Object)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"azul3d.org/engine/gfx"
+)
+
+// NewColorLUT returns a color lookup table of size^3 entries mapping each
+// color through f, with components in [0, 1]. As there are no 3D textures, the
+// table is unrolled into a size*size by size texture: the blue slices are laid
+// out side by side, with red increasing to the right and green downwards in each.
+func NewColorLUT(size int, f func(r, g, b float64) (float64, float64, float64)) *gfx.Texture {
+	img := image.NewRGBA(image.Rect(0, 0, size*size, size))
+	max := float64(size - 1)
+	for b := 0; b < size; b++ {
+		for g := 0; g < size; g++ {
+			for r := 0; r < size; r++ {
+				or, og, ob := f(float64(r)/max, float64(g)/max, float64(b)/max)
+				img.SetRGBA(b*size+r, g, color.RGBA{lutByte(or), lutByte(og), lutByte(ob), 255})
+			}
+		}
+	}
+	return lutTexture(img)
+}
+
+func lutByte(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(1, v))*255 + 0.5)
+}
+
+func lutTexture(img *image.RGBA) *gfx.Texture {
+	t := gfx.NewTexture()
+	t.Source = img
+	t.Bounds = img.Bounds()
+	t.MinFilter = gfx.Linear
+	t.MagFilter = gfx.Linear
+	t.WrapU = gfx.Clamp
+	t.WrapV = gfx.Clamp
+	return t
+}
+
+// LoadCubeLUT reads a color lookup table in the .cube format. Only 3D tables
+// with the default [0, 1] domain are supported.
+func LoadCubeLUT(path string) (*gfx.Texture, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		size   int
+		values [][3]float64
+		line   int
+	)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line++
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch fields[0] {
+		case "TITLE":
+			continue
+		case "LUT_3D_SIZE":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("%s:%d: bad LUT_3D_SIZE", path, line)
+			}
+			size, err = strconv.Atoi(fields[1])
+			if err != nil || size < 2 || size > 256 {
+				return nil, fmt.Errorf("%s:%d: bad LUT_3D_SIZE %q", path, line, fields[1])
+			}
+			continue
+		case "LUT_1D_SIZE":
+			return nil, fmt.Errorf("%s: 1D tables are not supported", path)
+		case "DOMAIN_MIN", "DOMAIN_MAX":
+			want := 0.0
+			if fields[0] == "DOMAIN_MAX" {
+				want = 1
+			}
+			for _, v := range fields[1:] {
+				if x, err := strconv.ParseFloat(v, 64); err != nil || x != want {
+					return nil, fmt.Errorf("%s:%d: only the [0, 1] domain is supported", path, line)
+				}
+			}
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: expected three values", path, line)
+		}
+		var v [3]float64
+		for i, field := range fields {
+			if v[i], err = strconv.ParseFloat(field, 64); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+			}
+		}
+		values = append(values, v)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return nil, fmt.Errorf("%s: missing LUT_3D_SIZE", path)
+	}
+	if len(values) != size*size*size {
+		return nil, fmt.Errorf("%s: %d entries, expected %d", path, len(values), size*size*size)
+	}
+
+	// Entries are listed with red changing fastest, then green, then blue.
+	img := image.NewRGBA(image.Rect(0, 0, size*size, size))
+	for i, v := range values {
+		r, g, b := i%size, i/size%size, i/(size*size)
+		img.SetRGBA(b*size+r, g, color.RGBA{lutByte(v[0]), lutByte(v[1]), lutByte(v[2]), 255})
+	}
+	return lutTexture(img), nil
+}
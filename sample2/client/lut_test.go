@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeCube writes a .cube file holding text to a temporary directory, and
+// returns its path and a function removing it.
+func writeCube(t *testing.T, text string) (string, func()) {
+	dir, err := ioutil.TempDir("", "lut")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "test.cube")
+	if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+// cubeText returns a .cube file of the given size mapping colors through f.
+func cubeText(size int, f func(r, g, b float64) (float64, float64, float64)) string {
+	var sb strings.Builder
+	sb.WriteString("# comment\nTITLE \"test\"\n")
+	fmt.Fprintf(&sb, "LUT_3D_SIZE %d\nDOMAIN_MIN 0 0 0\nDOMAIN_MAX 1 1 1\n\n", size)
+	max := float64(size - 1)
+	for b := 0; b < size; b++ {
+		for g := 0; g < size; g++ {
+			for r := 0; r < size; r++ {
+				or, og, ob := f(float64(r)/max, float64(g)/max, float64(b)/max)
+				fmt.Fprintf(&sb, "%f %f %f\n", or, og, ob)
+			}
+		}
+	}
+	return sb.String()
+}
+
+func TestLoadCubeLUT(t *testing.T) {
+	// Swap the red and blue channels, so that the layout of the entries is
+	// checked along every axis.
+	swap := func(r, g, b float64) (float64, float64, float64) {
+		return b, g * 0.5, r
+	}
+	path, remove := writeCube(t, cubeText(4, swap))
+	defer remove()
+
+	tex, err := LoadCubeLUT(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := tex.Source.(*image.RGBA)
+	want := NewColorLUT(4, swap).Source.(*image.RGBA)
+	if got.Bounds() != want.Bounds() {
+		t.Fatalf("bounds %v, want %v", got.Bounds(), want.Bounds())
+	}
+	for y := 0; y < got.Bounds().Dy(); y++ {
+		for x := 0; x < got.Bounds().Dx(); x++ {
+			if a, b := got.RGBAAt(x, y), want.RGBAAt(x, y); a != b {
+				t.Fatalf("pixel %d, %d is %v, want %v", x, y, a, b)
+			}
+		}
+	}
+}
+
+func TestLoadCubeLUTErrors(t *testing.T) {
+	identity := func(r, g, b float64) (float64, float64, float64) {
+		return r, g, b
+	}
+	tests := []struct {
+		name, text, err string
+	}{
+		{"no size", "0 0 0\n", "missing LUT_3D_SIZE"},
+		{"1D", "LUT_1D_SIZE 4\n", "1D tables"},
+		{"domain", "LUT_3D_SIZE 2\nDOMAIN_MAX 2 2 2\n", "domain"},
+		{"entries", strings.Replace(cubeText(2, identity), "1.000000 1.000000 1.000000\n", "", 1), "7 entries"},
+		{"values", "LUT_3D_SIZE 2\n0 0\n", "three values"},
+		{"size", "LUT_3D_SIZE 1\n", "bad LUT_3D_SIZE"},
+	}
+	for _, tt := range tests {
+		path, remove := writeCube(t, tt.text)
+		_, err := LoadCubeLUT(path)
+		remove()
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v, want one containing %q", tt.name, err, tt.err)
+		}
+	}
+}
@@ -6,6 +6,10 @@
 package main
 
 import (
+	"flag"
+	"log"
+	"os"
+
 	"azul3d.org/engine/gfx"
 	"azul3d.org/engine/gfx/window"
 )
@@ -23,6 +27,15 @@ func gfxLoop(w window.Window, d gfx.Device) {
 }
 
 func main() {
+	seed := flag.Int64("seed", 1, "seed of the random numbers effects use")
+	flag.Parse()
+
 	game = NewGame()
+	game.SetRandomSeed(*seed)
+	if icon, err := LoadImage("icon.png"); err == nil {
+		game.SetWindowIcon(icon)
+	} else if !os.IsNotExist(err) {
+		log.Println(err)
+	}
 	window.Run(gfxLoop, nil)
 }
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"azul3d.org/engine/gfx"
+)
+
+// Material bundles the render state of an object, so that common setups can
+// be applied in one call and shared between objects. Objects keep what they
+// were set to, changes to a material apply to them once it is set again.
+type Material struct {
+	// Shader and Textures replace those of the object, unless nil.
+	Shader   *gfx.Shader
+	Textures []*gfx.Texture
+
+	AlphaMode   gfx.AlphaMode
+	Blend       gfx.BlendState
+	DepthTest   bool
+	DepthWrite  bool
+	FaceCulling gfx.FaceCullMode
+
+	// AlphaThreshold is the alpha below which fragments are discarded, with
+	// the gfx.BinaryAlpha mode.
+	AlphaThreshold float64
+}
+
+// MaterialOpaque returns a material for solid surfaces, hiding their back
+// faces.
+func MaterialOpaque() *Material {
+	return &Material{
+		AlphaMode:   gfx.NoAlpha,
+		Blend:       gfx.DefaultBlendState,
+		DepthTest:   true,
+		DepthWrite:  true,
+		FaceCulling: gfx.BackFaceCulling,
+	}
+}
+
+// MaterialCutout returns a material for surfaces with holes where their
+// alpha is below threshold, like leaves or fences. They are seen from both
+// sides.
+func MaterialCutout(threshold float64) *Material {
+	return &Material{
+		AlphaMode:      gfx.BinaryAlpha,
+		Blend:          gfx.DefaultBlendState,
+		DepthTest:      true,
+		DepthWrite:     true,
+		FaceCulling:    gfx.NoFaceCulling,
+		AlphaThreshold: threshold,
+	}
+}
+
+// MaterialTransparent returns a material for see-through surfaces, blended
+// over what is behind them and drawn back to front.
+func MaterialTransparent() *Material {
+	return &Material{
+		AlphaMode:   gfx.AlphaBlend,
+		Blend:       gfx.DefaultBlendState,
+		DepthTest:   true,
+		DepthWrite:  false,
+		FaceCulling: gfx.NoFaceCulling,
+	}
+}
+
+// MaterialAdditive returns a material for glowing surfaces, whose color is
+// added to what is behind them.
+func MaterialAdditive() *Material {
+	blend := gfx.DefaultBlendState
+	blend.SrcRGB = gfx.BSrcAlpha
+	blend.DstRGB = gfx.BOne
+	blend.SrcAlpha = gfx.BZero
+	blend.DstAlpha = gfx.BOne
+	return &Material{
+		AlphaMode:   gfx.AlphaBlend,
+		Blend:       blend,
+		DepthTest:   true,
+		DepthWrite:  false,
+		FaceCulling: gfx.NoFaceCulling,
+	}
+}
+
+// SetMaterial sets the render state of o from m.
+func SetMaterial(o *gfx.Object, m *Material) {
+	if m.Shader != nil {
+		replaceBaseShader(o, m.Shader)
+	}
+	if m.Textures != nil {
+		o.Textures = m.Textures
+	}
+	o.AlphaMode = m.AlphaMode
+	o.Blend = m.Blend
+	o.DepthTest = m.DepthTest
+	o.DepthWrite = m.DepthWrite
+	o.FaceCulling = m.FaceCulling
+
+	// Only cutouts need an input of their own, which keeps other frozen
+	// objects batchable.
+	if m.AlphaMode == gfx.BinaryAlpha {
+		sh := ownShader(o)
+		sh.Lock()
+		sh.Inputs["AlphaThreshold"] = float32(m.AlphaThreshold)
+		sh.Unlock()
+	}
+
+	p := propsOf(o)
+	p.material = m
+
+	// Static batches are grouped by state.
+	if p.static != nil {
+		staticGen++
+	}
+}
+
+// MaterialOf returns the material last set on o, or nil.
+func MaterialOf(o *gfx.Object) *Material {
+	if p, ok := props[o]; ok {
+		return p.material
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"azul3d.org/engine/gfx"
+)
+
+// mipRange is the range of mip levels sampled from a texture.
+type mipRange struct {
+	base, max int
+}
+
+var mipRanges = make(map[*gfx.Texture]mipRange)
+
+// SetMipRange clamps the mip levels the scene shader samples from t to
+// [baseLevel, maxLevel], so that setting both to the same level forces it. The
+// clamp is applied through the level of detail bias in the shader, and only
+// has an effect while t uses a mipmapped minification filter.
+func SetMipRange(t *gfx.Texture, baseLevel, maxLevel int) error {
+	if baseLevel < 0 || baseLevel > maxLevel {
+		return fmt.Errorf("invalid mip range [%d, %d]", baseLevel, maxLevel)
+	}
+	mipRanges[t] = mipRange{baseLevel, maxLevel}
+	return nil
+}
+
+// ClearMipRange lets the scene shader sample every mip level of t again.
+func ClearMipRange(t *gfx.Texture) {
+	delete(mipRanges, t)
+}
+
+// setMipInputs passes the mip range of the first texture of o to its shader.
+// Objects with a range get their own shader, as the range belongs to their
+// texture and not to the shader they may share with others.
+func setMipInputs(o *gfx.Object) {
+	t := firstTexture(o)
+	r, ok := mipRanges[t]
+	if !ok {
+		if p, owns := props[o]; owns && p.shader != nil && o.Shader == p.shader {
+			o.Shader.Lock()
+			o.Shader.Inputs["MipClamp"] = false
+			o.Shader.Unlock()
+		}
+		return
+	}
+	t.RLock()
+	size := t.Bounds.Size()
+	t.RUnlock()
+
+	sh := ownShader(o)
+	sh.Lock()
+	sh.Inputs["MipClamp"] = true
+	sh.Inputs["MipBaseLevel"] = float32(r.base)
+	sh.Inputs["MipMaxLevel"] = float32(r.max)
+	sh.Inputs["TextureWidth"] = float32(size.X)
+	sh.Inputs["TextureHeight"] = float32(size.Y)
+	sh.Unlock()
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// MorphMesh blends the vertex positions of a mesh between named morph targets
+// on the CPU, each target contributing by its weight.
+type MorphMesh struct {
+	// Mesh is the blended mesh, it is the base mesh given to NewMorphMesh and
+	// is updated in place.
+	*gfx.Mesh
+
+	base        []gfx.Vec3
+	baseNormals []gfx.Vec3
+	targets     []*morphTarget
+	dirty       bool
+}
+
+type morphTarget struct {
+	name     string
+	vertices []gfx.Vec3
+	weight   float64
+}
+
+// NewMorphMesh returns a morph mesh blending from the current vertices of m.
+func NewMorphMesh(m *gfx.Mesh) *MorphMesh {
+	m.RLock()
+	defer m.RUnlock()
+	return &MorphMesh{
+		Mesh:        m,
+		base:        append([]gfx.Vec3(nil), m.Vertices...),
+		baseNormals: append([]gfx.Vec3(nil), m.Normals...),
+	}
+}
+
+// AddTarget adds a morph target with the given vertex positions, which must
+// match the base mesh one to one. Its weight starts at zero.
+func (m *MorphMesh) AddTarget(name string, vertices []gfx.Vec3) error {
+	if len(vertices) != len(m.base) {
+		return fmt.Errorf("morph target %q has %d vertices, base mesh has %d", name, len(vertices), len(m.base))
+	}
+	if m.target(name) != nil {
+		return fmt.Errorf("morph target %q already exists", name)
+	}
+	m.targets = append(m.targets, &morphTarget{name: name, vertices: vertices})
+	return nil
+}
+
+func (m *MorphMesh) target(name string) *morphTarget {
+	for _, t := range m.targets {
+		if t.name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// SetWeight sets the weight of the named morph target.
+func (m *MorphMesh) SetWeight(name string, w float64) {
+	t := m.target(name)
+	if t == nil {
+		log.Printf("SetWeight: no morph target %q\n", name)
+		return
+	}
+	if t.weight != w {
+		t.weight = w
+		m.dirty = true
+	}
+}
+
+// Update blends the mesh if any weight changed since the last update.
+func (m *MorphMesh) Update() {
+	if !m.dirty {
+		return
+	}
+	m.dirty = false
+
+	m.Lock()
+	defer m.Unlock()
+
+	blended := false
+	for i, b := range m.base {
+		v := b
+		for _, t := range m.targets {
+			if t.weight == 0 {
+				continue
+			}
+			w := float32(t.weight)
+			v.X += (t.vertices[i].X - b.X) * w
+			v.Y += (t.vertices[i].Y - b.Y) * w
+			v.Z += (t.vertices[i].Z - b.Z) * w
+			blended = true
+		}
+		m.Vertices[i] = v
+	}
+
+	// Keep the original normals when no target contributes, so that zero
+	// weights reproduce the base mesh exactly.
+	if len(m.baseNormals) > 0 {
+		if blended {
+			m.Normals = computeNormals(m.Vertices, m.Indices, m.Normals)
+		} else {
+			copy(m.Normals, m.baseNormals)
+		}
+	}
+	m.Changed = true
+}
+
+// computeNormals returns smooth vertex normals for the triangles described by
+// vertices and indices (or by vertices alone, when indices is empty), reusing
+// the dst slice if it is large enough.
+func computeNormals(vertices []gfx.Vec3, indices []uint32, dst []gfx.Vec3) []gfx.Vec3 {
+	if cap(dst) < len(vertices) {
+		dst = make([]gfx.Vec3, len(vertices))
+	}
+	dst = dst[:len(vertices)]
+	sums := make([]lmath.Vec3, len(vertices))
+
+	n := len(indices)
+	if n == 0 {
+		n = len(vertices)
+	}
+	index := func(i int) int {
+		if len(indices) == 0 {
+			return i
+		}
+		return int(indices[i])
+	}
+	for i := 0; i+2 < n; i += 3 {
+		a, b, c := index(i), index(i+1), index(i+2)
+		va, vb, vc := vertices[a].Vec3(), vertices[b].Vec3(), vertices[c].Vec3()
+		face := vb.Sub(va).Cross(vc.Sub(va))
+		sums[a] = sums[a].Add(face)
+		sums[b] = sums[b].Add(face)
+		sums[c] = sums[c].Add(face)
+	}
+	for i, s := range sums {
+		dst[i] = gfx.ConvertVec3(s.Normalized())
+	}
+	return dst
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"image"
+	"log"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/gfx/gfxutil"
+	"azul3d.org/engine/lmath"
+)
+
+// maxVelocity is the longest screen space motion per frame, in texture
+// coordinates, the velocity target holds. Faster motion is clamped to it.
+const maxVelocity = 0.1
+
+// velocityBuffer renders the screen space motion of every object since the
+// previous frame, encoded into the red and green of a texture.
+type velocityBuffer struct {
+	canvas gfx.Canvas
+	color  *gfx.Texture
+
+	// shader is shared by the proxies of the objects which did not move
+	// since the previous frame, which only need the view projection of the
+	// camera then. The ones which moved also need the model matrix they had,
+	// and use a copy of it of their own.
+	shader  *gfx.Shader
+	proxies map[*gfx.Object]*velocityProxy
+
+	// prevViewProj is the view projection matrix of the previous frame.
+	prevViewProj lmath.Mat4
+	frame        int
+}
+
+// velocityProxy draws the motion of an object. model is the model matrix of the
+// object in the frame it was last drawn, and moved is its own copy of the
+// shader, made the first time it moved.
+type velocityProxy struct {
+	*gfx.Object
+	model lmath.Mat4
+	frame int
+	moved *gfx.Shader
+}
+
+func newVelocityBuffer() (*velocityBuffer, error) {
+	sh, err := gfxutil.OpenShader("velocity")
+	if err != nil {
+		return nil, err
+	}
+	sh.Inputs["MaxVelocity"] = float32(maxVelocity)
+	sh.Inputs["Moved"] = false
+	return &velocityBuffer{
+		shader:  sh,
+		proxies: make(map[*gfx.Object]*velocityProxy),
+	}, nil
+}
+
+// RenderVelocity renders the motion of the objects in view of cam into a
+// texture of bounds b and returns it, or nil if render to texture is not
+// supported.
+func (s *Scene) RenderVelocity(d gfx.Device, cam *camera.Camera, b image.Rectangle) *gfx.Texture {
+	if s.velocity == nil {
+		v, err := newVelocityBuffer()
+		if err != nil {
+			log.Println("Motion blur disabled:", err)
+			return nil
+		}
+		s.velocity = v
+	}
+	v := s.velocity
+	if v.canvas == nil || v.canvas.Bounds() != b {
+		cfg := d.Info().RTTFormats.ChooseConfig(gfx.Precision{
+			RedBits: 8, GreenBits: 8, BlueBits: 8, AlphaBits: 8,
+			DepthBits: 24,
+		}, true)
+		v.color = gfx.NewTexture()
+		v.color.MinFilter = gfx.Nearest
+		v.color.MagFilter = gfx.Nearest
+		v.color.WrapU = gfx.Clamp
+		v.color.WrapV = gfx.Clamp
+		cfg.Color = v.color
+		cfg.Bounds = b
+		v.canvas = d.RenderToTexture(cfg)
+		if v.canvas == nil {
+			log.Println("Motion blur disabled: render to texture is not supported.")
+			return nil
+		}
+	}
+
+	// The background does not move, which 127 of 255 encodes.
+	c := v.canvas
+	c.Clear(c.Bounds(), gfx.Color{127.0 / 255, 127.0 / 255, 0, 1})
+	c.ClearDepth(c.Bounds(), 1.0)
+	// The first frame has no previous one, and does not blur.
+	vp := viewProj(cam)
+	if v.frame == 0 {
+		v.prevViewProj = vp
+	}
+	prevViewProj := gfx.ConvertMat4(v.prevViewProj)
+	v.shader.Lock()
+	v.shader.Inputs["PrevViewProj"] = prevViewProj
+	v.shader.Unlock()
+
+	// The pass culls the scene itself, as the lists of the last Draw are the
+	// ones of whichever eye or reflection was drawn last.
+	for _, o := range s.inView(vp) {
+		c.Draw(c.Bounds(), v.proxy(o, prevViewProj), cam)
+	}
+	c.Render()
+
+	// Objects which were not drawn this frame do not blur from where they
+	// were seen last once they are again, see proxy.
+	for o, px := range v.proxies {
+		if px.frame != v.frame {
+			delete(v.proxies, o)
+		}
+	}
+	v.prevViewProj = vp
+	v.frame++
+	return v.color
+}
+
+// proxy returns the object drawing the motion of o. It shares the transform
+// and meshes of o, and the shader of the velocity buffer unless o moved since
+// the previous frame.
+func (v *velocityBuffer) proxy(o *gfx.Object, prevViewProj gfx.Mat4) *gfx.Object {
+	px, ok := v.proxies[o]
+	if !ok {
+		px = &velocityProxy{Object: gfx.NewObject()}
+		px.State = gfx.NewState()
+		px.AlphaMode = gfx.NoAlpha
+		px.Dithering = false
+		v.proxies[o] = px
+	}
+	px.Transform = o.Transform
+	px.Meshes = o.Meshes
+	px.FaceCulling = o.FaceCulling
+
+	// An object which was not drawn in the previous frame starts from where
+	// it is now, rather than blurring from where it was seen last.
+	model := o.Convert(gfx.LocalToWorld)
+	if px.frame != v.frame-1 {
+		px.model = model
+	}
+	px.Shader = v.shader
+	if px.model != model {
+		if px.moved == nil {
+			px.moved = copyShader(v.shader)
+			px.moved.Inputs["Moved"] = true
+		}
+		px.moved.Lock()
+		px.moved.Inputs["PrevModel"] = gfx.ConvertMat4(px.model)
+		px.moved.Inputs["PrevViewProj"] = prevViewProj
+		px.moved.Unlock()
+		px.Shader = px.moved
+	}
+	px.model = model
+	px.frame = v.frame
+	return px.Object
+}
+
+// SetMotionBlur blurs the scene along the motion of the objects and the camera
+// since the previous frame, scaled by strength. Zero turns it off, and one
+// blurs over the motion of the whole frame.
+func (g *Game) SetMotionBlur(strength float64) {
+	if strength < 0 {
+		strength = 0
+	}
+	g.post.MotionBlur = strength
+	if strength == 0 {
+		g.post.Velocity = nil
+	}
+	log.Println("Motion blur", strength)
+}
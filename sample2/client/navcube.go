@@ -0,0 +1,142 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/lmath"
+)
+
+// navFace is one face of the navigation cube.
+type navFace struct {
+	name              string
+	normal            lmath.Vec3
+	corner, right, up lmath.Vec3
+}
+
+// navFaces lists the faces of the unit cube, with the right and up vectors as
+// seen by a camera looking at the face from the outside.
+var navFaces = []navFace{
+	{"Front", lmath.Vec3{0, -1, 0}, lmath.Vec3{-0.5, -0.5, -0.5}, lmath.Vec3{1, 0, 0}, lmath.Vec3{0, 0, 1}},
+	{"Back", lmath.Vec3{0, 1, 0}, lmath.Vec3{0.5, 0.5, -0.5}, lmath.Vec3{-1, 0, 0}, lmath.Vec3{0, 0, 1}},
+	{"Right", lmath.Vec3{1, 0, 0}, lmath.Vec3{0.5, -0.5, -0.5}, lmath.Vec3{0, 1, 0}, lmath.Vec3{0, 0, 1}},
+	{"Left", lmath.Vec3{-1, 0, 0}, lmath.Vec3{-0.5, 0.5, -0.5}, lmath.Vec3{0, -1, 0}, lmath.Vec3{0, 0, 1}},
+	{"Top", lmath.Vec3{0, 0, 1}, lmath.Vec3{-0.5, -0.5, 0.5}, lmath.Vec3{1, 0, 0}, lmath.Vec3{0, 1, 0}},
+	{"Bottom", lmath.Vec3{0, 0, -1}, lmath.Vec3{-0.5, 0.5, -0.5}, lmath.Vec3{1, 0, 0}, lmath.Vec3{0, -1, 0}},
+}
+
+// NavCube is a small labeled cube drawn in the top-right corner of the screen
+// that turns with the main camera, clicking one of its faces tells which axis
+// aligned view was asked for.
+type NavCube struct {
+	// Size is the width and height of the cube area, in pixels.
+	Size int
+
+	cube *gfx.Object
+	cam  *camera.Camera
+	rect image.Rectangle
+}
+
+func NewNavCube(shader *gfx.Shader, text *TextRenderer, bounds image.Rectangle) *NavCube {
+	const tile = 64
+
+	// Render the face labels side by side into a single texture.
+	atlas := image.NewRGBA(image.Rect(0, 0, tile*len(navFaces), tile))
+	for i, f := range navFaces {
+		r := image.Rect(i*tile, 0, (i+1)*tile, tile)
+		draw.Draw(atlas, r, image.NewUniform(color.NRGBA{96, 96, 96, 255}), image.ZP, draw.Src)
+		draw.Draw(atlas, r.Inset(2), image.NewUniform(color.NRGBA{220, 220, 220, 255}), image.ZP, draw.Src)
+		label := text.Render(f.name)
+		at := r.Min.Add(r.Size().Sub(label.Bounds().Size()).Div(2))
+		draw.Draw(atlas, label.Bounds().Add(at), label, image.ZP, draw.Over)
+	}
+	tex := gfx.NewTexture()
+	tex.Source = atlas
+	tex.MinFilter = gfx.LinearMipmapLinear
+	tex.MagFilter = gfx.Linear
+
+	m := gfx.NewMesh()
+	var tc []gfx.TexCoord
+	for i, f := range navFaces {
+		bl := f.corner
+		br := bl.Add(f.right)
+		tl := bl.Add(f.up)
+		tr := br.Add(f.up)
+		for _, v := range []lmath.Vec3{bl, br, tl, tl, br, tr} {
+			m.Vertices = append(m.Vertices, gfx.ConvertVec3(v))
+		}
+		u0 := float32(i) / float32(len(navFaces))
+		u1 := float32(i+1) / float32(len(navFaces))
+		tc = append(tc, gfx.TexCoord{u0, 1}, gfx.TexCoord{u1, 1}, gfx.TexCoord{u0, 0},
+			gfx.TexCoord{u0, 0}, gfx.TexCoord{u1, 1}, gfx.TexCoord{u1, 0})
+	}
+	m.TexCoords = []gfx.TexCoordSet{{Slice: tc}}
+
+	cube := gfx.NewObject()
+	cube.State = gfx.NewState()
+	cube.FaceCulling = gfx.NoFaceCulling
+	cube.Shader = shader
+	cube.Textures = []*gfx.Texture{tex}
+	cube.Meshes = []*gfx.Mesh{m}
+
+	n := &NavCube{
+		Size: 96,
+		cube: cube,
+	}
+	n.Resize(bounds)
+	return n
+}
+
+// Resize moves the cube area into the top-right corner of new screen bounds.
+func (n *NavCube) Resize(bounds image.Rectangle) {
+	const margin = 8
+	n.rect = image.Rect(bounds.Max.X-margin-n.Size, bounds.Min.Y+margin, bounds.Max.X-margin, bounds.Min.Y+margin+n.Size)
+	if n.cam == nil {
+		n.cam = camera.New(n.rect)
+	} else {
+		n.cam.Update(n.rect)
+	}
+}
+
+// Draw draws the cube as seen with the rotation of the main camera.
+func (n *NavCube) Draw(d gfx.Device, main *camera.Camera) {
+	n.cam.SetRot(main.Rot())
+	n.cam.SetPos(cameraForward(main).MulScalar(-3))
+	d.ClearDepth(n.rect, 1.0)
+	d.Draw(n.rect, n.cube, n.cam)
+}
+
+// Click returns the outward normal of the cube face under the pixel p, if any.
+func (n *NavCube) Click(p image.Point) (normal lmath.Vec3, ok bool) {
+	if !p.In(n.rect) {
+		return normal, false
+	}
+	origin, dir, ok := screenRay(n.cam, n.rect, p)
+	if !ok {
+		return normal, false
+	}
+	unit := lmath.Rect3{Min: lmath.Vec3{-0.5, -0.5, -0.5}, Max: lmath.Vec3{0.5, 0.5, 0.5}}
+	_, normal, ok = rayBoxNormal(origin, dir, unit)
+	return normal, ok
+}
+
+// axisView returns the camera rotation looking along -normal, for one of the
+// axis aligned normals of the cube faces.
+func axisView(normal lmath.Vec3) lmath.Vec3 {
+	switch {
+	case normal.Y > 0.5:
+		return lmath.Vec3{0, 0, 180}
+	case normal.X > 0.5:
+		return lmath.Vec3{0, 0, 90}
+	case normal.X < -0.5:
+		return lmath.Vec3{0, 0, -90}
+	case normal.Z > 0.5:
+		return lmath.Vec3{-90, 0, 0}
+	case normal.Z < -0.5:
+		return lmath.Vec3{90, 0, 0}
+	}
+	return lmath.Vec3{}
+}
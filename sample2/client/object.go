@@ -1,17 +1,27 @@
-package main
-
-import (
-	"azul3d.org/engine/gfx"
-)
-
-type Object struct {
-	gfx.Object
-}
-
-func (o *Object) Move(pos gfx.Vec3, frame int) {
-
-}
-
-func (o *Object) Update() {
-
-}
+package main
+
+import (
+	"azul3d.org/engine/gfx"
+)
+
+type Object struct {
+	*gfx.Object
+	Name string
+	Anim *AnimStateMachine
+
+	// The transforms after the last two simulation steps, for objects drawn
+	// interpolated between them. simValid is set once cur was simulated.
+	interpolated bool
+	prev, cur    simTransform
+	simValid     bool
+}
+
+func (o *Object) Move(pos gfx.Vec3, frame int) {
+
+}
+
+func (o *Object) Update(dt float64) {
+	if o.Anim != nil {
+		o.Anim.Update(dt)
+	}
+}
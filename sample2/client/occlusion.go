@@ -0,0 +1,126 @@
+package main
+
+import (
+	"image"
+	"log"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/gfx/gfxutil"
+	"azul3d.org/engine/lmath"
+)
+
+// occlusionMargin is how much the tested boxes are grown by, so that an
+// object just coming out from behind its occluder is drawn a little early
+// rather than late.
+const occlusionMargin = 0.05
+
+// occlusionCuller skips drawing the objects that were fully hidden behind
+// others the frame before. Each object in view has its bounding box drawn
+// after the opaque pass, without writing color or depth, counting the samples
+// passing the depth test. The counts are only read a frame later, once the
+// device has rendered it, so that nothing waits on the GPU.
+type occlusionCuller struct {
+	shader *gfx.Shader
+	cube   *gfx.Mesh
+	boxes  map[*gfx.Object]*occlusionBox
+
+	// tested are the objects whose boxes are drawn this frame.
+	tested []*gfx.Object
+	frame  int
+}
+
+// occlusionBox is the box an object is tested with, and the frame it was last
+// drawn in.
+type occlusionBox struct {
+	*gfx.Object
+	issued bool
+	frame  int
+}
+
+func newOcclusionCuller() (*occlusionCuller, error) {
+	// Any shader will do as nothing is written, the ID one is the simplest.
+	sh, err := gfxutil.OpenShader("id")
+	if err != nil {
+		return nil, err
+	}
+	return &occlusionCuller{
+		shader: sh,
+		cube:   newCube(1).Meshes[0],
+		boxes:  make(map[*gfx.Object]*occlusionBox),
+	}, nil
+}
+
+// SetOcclusionCulling sets whether objects hidden behind others are skipped,
+// using occlusion queries. Only the camera given to Draw is tested, the
+// device has to support occlusion queries (see gfx.DeviceInfo).
+func (s *Scene) SetOcclusionCulling(enabled bool) {
+	if !enabled {
+		s.occlusion = nil
+		return
+	}
+	if s.occlusion != nil {
+		return
+	}
+	oc, err := newOcclusionCuller()
+	if err != nil {
+		log.Println("Occlusion culling disabled:", err)
+		return
+	}
+	s.occlusion = oc
+}
+
+// begin starts a frame, forgetting the boxes of the objects that went out of
+// view.
+func (oc *occlusionCuller) begin() {
+	oc.frame++
+	oc.tested = oc.tested[:0]
+	for o, b := range oc.boxes {
+		if b.frame < oc.frame-1 {
+			delete(oc.boxes, o)
+		}
+	}
+}
+
+// hidden reports whether o was fully occluded when last tested from cam, and
+// has its box tested again this frame. The camera being inside the box always
+// counts as visible, as the box may then be cut away by the near plane.
+func (oc *occlusionCuller) hidden(o *gfx.Object, cam *camera.Camera) bool {
+	b, ok := oc.boxes[o]
+	if !ok {
+		b = &occlusionBox{Object: gfx.NewObject()}
+		b.State = gfx.NewState()
+		b.AlphaMode = gfx.NoAlpha
+		b.Dithering = false
+		b.WriteRed, b.WriteGreen, b.WriteBlue, b.WriteAlpha = false, false, false, false
+		b.DepthWrite = false
+		b.FaceCulling = gfx.NoFaceCulling
+		b.OcclusionTest = true
+		b.Shader = oc.shader
+		b.Meshes = []*gfx.Mesh{oc.cube}
+		oc.boxes[o] = b
+	}
+	// Only a box drawn last frame has a count to go by, one coming back
+	// into view is drawn until it is tested again.
+	hidden := b.issued && b.frame == oc.frame-1 && b.SampleCount() == 0
+
+	box := expand(worldBounds(o), occlusionMargin)
+	size := box.Max.Sub(box.Min)
+	b.SetPos(box.Min.Add(box.Max).MulScalar(0.5))
+	b.SetScale(lmath.Vec3{size.X, size.Y, size.Z})
+	oc.tested = append(oc.tested, o)
+	return hidden && !contains(box, cam.Pos())
+}
+
+// drawOcclusionBoxes draws the boxes of the objects tested this frame, after
+// the opaque objects have filled the depth buffer.
+func (s *Scene) drawOcclusionBoxes(c gfx.Canvas, r image.Rectangle, cam *camera.Camera, stats *RenderStats) {
+	oc := s.occlusion
+	for _, o := range oc.tested {
+		b := oc.boxes[o]
+		c.Draw(r, b.Object, cam)
+		stats.countDraw(b.Object)
+		b.issued = true
+		b.frame = oc.frame
+	}
+}
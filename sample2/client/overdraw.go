@@ -0,0 +1,147 @@
+package main
+
+import (
+	"log"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/gfx/gfxutil"
+	"azul3d.org/engine/lmath"
+)
+
+// maxOverdraw is how many times a pixel has to be drawn to show as red in the
+// overdraw view. Pixels drawn more often are red too.
+const maxOverdraw = 16
+
+// overdrawView renders how many times each pixel is drawn, by adding a small
+// constant per fragment into a texture, then shows it as a heatmap going from
+// blue for once to red for maxOverdraw times.
+type overdrawView struct {
+	shader *gfx.Shader
+	canvas gfx.Canvas
+	count  *gfx.Texture
+
+	// proxies holds the objects counting the draws of the scene objects,
+	// dropped once a render went without them, stamp counting the renders.
+	proxies map[*gfx.Object]*overdrawProxy
+	stamp   int
+
+	heatmap *gfx.Object
+	cam     *camera.Camera
+}
+
+type overdrawProxy struct {
+	*gfx.Object
+	stamp int
+}
+
+func newOverdrawView() (*overdrawView, error) {
+	// The ID shader draws a constant color, which is all counting needs.
+	count, err := gfxutil.OpenShader("id")
+	if err != nil {
+		return nil, err
+	}
+	step := float32(1.0 / maxOverdraw)
+	count.Inputs["ID"] = gfx.Color{step, step, step, 1}
+	heatmap, err := gfxutil.OpenShader("heatmap")
+	if err != nil {
+		return nil, err
+	}
+	v := &overdrawView{
+		shader:  count,
+		proxies: make(map[*gfx.Object]*overdrawProxy),
+		heatmap: newQuad(heatmap),
+	}
+	v.heatmap.AlphaMode = gfx.NoAlpha
+	v.heatmap.DepthTest = false
+	return v, nil
+}
+
+// SetOverdrawView sets whether the scene is shown as a heatmap of how many
+// times each pixel is drawn instead, to find where overdraw is high.
+func (g *Game) SetOverdrawView(enabled bool) {
+	if !enabled {
+		g.overdraw = nil
+		return
+	}
+	if g.overdraw != nil {
+		return
+	}
+	v, err := newOverdrawView()
+	if err != nil {
+		log.Println("Overdraw view disabled:", err)
+		return
+	}
+	g.overdraw = v
+}
+
+// Draw counts the draws of the objects the last call to Scene.Draw drew, as
+// seen by cam, and draws the heatmap of the counts over the canvas c, which is
+// the screen so that post processing does not tint it. It returns false if
+// render to texture is not supported.
+func (v *overdrawView) Draw(d gfx.Device, c gfx.Canvas, s *Scene, cam *camera.Camera) bool {
+	b := c.Bounds()
+	if v.canvas == nil || v.canvas.Bounds() != b {
+		cfg := d.Info().RTTFormats.ChooseConfig(gfx.Precision{
+			RedBits: 8, GreenBits: 8, BlueBits: 8, AlphaBits: 8,
+			DepthBits: 24,
+		}, true)
+		v.count = gfx.NewTexture()
+		v.count.MinFilter = gfx.Nearest
+		v.count.MagFilter = gfx.Nearest
+		v.count.WrapU = gfx.Clamp
+		v.count.WrapV = gfx.Clamp
+		cfg.Color = v.count
+		cfg.Bounds = b
+		v.canvas = d.RenderToTexture(cfg)
+		if v.canvas == nil {
+			log.Println("Overdraw view disabled: render to texture is not supported.")
+			return false
+		}
+		v.heatmap.Textures = []*gfx.Texture{v.count}
+		v.cam = newOrthoCamera(b)
+	}
+
+	v.stamp++
+	rc := v.canvas
+	rc.Clear(rc.Bounds(), gfx.Color{0, 0, 0, 0})
+	rc.ClearDepth(rc.Bounds(), 1.0)
+	for _, list := range [][]*gfx.Object{s.opaque, s.transparent} {
+		for _, o := range list {
+			rc.Draw(rc.Bounds(), v.proxy(o), cam)
+		}
+	}
+	rc.Render()
+	for o, px := range v.proxies {
+		if px.stamp != v.stamp {
+			delete(v.proxies, o)
+		}
+	}
+
+	v.heatmap.SetScale(lmath.Vec3{float64(b.Dx()), 1, float64(b.Dy())})
+	c.Draw(b, v.heatmap, v.cam)
+	return true
+}
+
+// proxy returns the object counting the draws of o. It shares the transform,
+// meshes and depth state of o, so that only the fragments o draws count.
+func (v *overdrawView) proxy(o *gfx.Object) *gfx.Object {
+	px, ok := v.proxies[o]
+	if !ok {
+		px = &overdrawProxy{Object: gfx.NewObject()}
+		px.State = gfx.NewState()
+		px.AlphaMode = gfx.AlphaBlend
+		px.Blend.SrcRGB, px.Blend.DstRGB = gfx.BOne, gfx.BOne
+		px.Blend.SrcAlpha, px.Blend.DstAlpha = gfx.BOne, gfx.BOne
+		px.Dithering = false
+		px.Shader = v.shader
+		v.proxies[o] = px
+	}
+	px.stamp = v.stamp
+	px.Transform = o.Transform
+	px.Meshes = o.Meshes
+	px.FaceCulling = o.FaceCulling
+	px.DepthTest = o.DepthTest
+	px.DepthWrite = o.DepthWrite
+	return px.Object
+}
@@ -0,0 +1,21 @@
+#version 120
+
+varying vec2 tc0;
+
+uniform sampler2D Texture0;
+
+// The layer shows Repeat copies of its texture across the width of the screen,
+// keeping its aspect ratio Aspect, scrolled by OffsetU and OffsetV.
+uniform float Repeat;
+uniform float Aspect;
+uniform float OffsetU;
+uniform float OffsetV;
+
+void main()
+{
+	vec2 uv = vec2(tc0.x * Repeat + OffsetU, (tc0.y - 1.0) * Repeat * Aspect + 1.0 + OffsetV);
+	if (uv.y < 0.0 || uv.y > 1.0) {
+		discard;
+	}
+	gl_FragColor = texture2D(Texture0, uv);
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/lmath"
+)
+
+// ParallaxLayer is a background layer scrolling with the camera slower the
+// further away it is meant to look, between the sky and the scene. Layers are
+// made for a camera looking along +Y: moving it along X pans them sideways,
+// and along Z up and down.
+type ParallaxLayer struct {
+	// Factor is how much of the camera motion the layer follows: zero stays
+	// still like the sky, and one moves with the world.
+	Factor float64
+
+	// Scale is the width in world units one copy of the texture spans when
+	// Factor is one, and Repeat how many copies fit across the screen. The
+	// bottom of the texture rests on the bottom of the screen.
+	Scale  float64
+	Repeat float64
+
+	tex  *gfx.Texture
+	quad *gfx.Object
+}
+
+// AddParallaxLayer adds a background layer showing tex, repeated sideways,
+// which follows factor times the motion of the camera, and returns it. Layers
+// are drawn from the smallest factor, the furthest, to the largest, blending
+// over each other.
+func (g *Game) AddParallaxLayer(tex *gfx.Texture, factor float64) *ParallaxLayer {
+	tex.WrapU = gfx.Repeat
+	tex.WrapV = gfx.Clamp
+	quad := newQuad(gfx.NewShader("parallax"))
+	quad.Shader.GLSL = g.parallaxSources
+	quad.Textures = []*gfx.Texture{tex}
+	quad.AlphaMode = gfx.AlphaBlend
+	quad.DepthTest = false
+	quad.DepthWrite = false
+	l := &ParallaxLayer{
+		Factor: factor,
+		Scale:  10,
+		Repeat: 1,
+		tex:    tex,
+		quad:   quad,
+	}
+	g.parallax = append(g.parallax, l)
+	sort.SliceStable(g.parallax, func(i, j int) bool {
+		return g.parallax[i].Factor < g.parallax[j].Factor
+	})
+	return l
+}
+
+// offset returns the texture offset of the layer for a camera at pos.
+func (l *ParallaxLayer) offset(pos lmath.Vec3) (u, v float64) {
+	tb := l.tex.Bounds
+	aspect := float64(tb.Dy()) / float64(tb.Dx())
+	u = pos.X * l.Factor / l.Scale
+	v = -pos.Z * l.Factor / (l.Scale * aspect)
+	return
+}
+
+// draw draws the layer over the whole canvas c for cam, using the orthographic
+// camera ortho.
+func (l *ParallaxLayer) draw(c gfx.Canvas, cam, ortho *camera.Camera) {
+	b, tb := c.Bounds(), l.tex.Bounds
+	if tb.Empty() || b.Empty() {
+		return
+	}
+	u, v := l.offset(cam.Pos())
+	sh := l.quad.Shader
+	sh.Lock()
+	sh.Inputs["Repeat"] = float32(l.Repeat)
+	sh.Inputs["Aspect"] = float32(float64(b.Dy()) / float64(b.Dx()) * float64(tb.Dx()) / float64(tb.Dy()))
+	sh.Inputs["OffsetU"] = float32(u)
+	sh.Inputs["OffsetV"] = float32(v)
+	sh.Unlock()
+	l.quad.SetScale(lmath.Vec3{float64(b.Dx()), 1, float64(b.Dy())})
+	c.Draw(b, l.quad, ortho)
+}
+
+// drawParallax draws the parallax layers over the whole canvas c, back to
+// front, as seen by cam.
+func (g *Game) drawParallax(c gfx.Canvas, cam *camera.Camera) {
+	if len(g.parallax) == 0 {
+		return
+	}
+	if g.parallaxCam == nil {
+		g.parallaxCam = newOrthoCamera(c.Bounds())
+	} else {
+		g.parallaxCam.Update(c.Bounds())
+	}
+	for _, l := range g.parallax {
+		l.draw(c, cam, g.parallaxCam)
+	}
+}
+
+// silhouetteTexture returns a texture of the given size, transparent above a
+// wavy ridge of color rising to height pixels from its bottom. waves is how
+// many times the ridge rises and falls across the width, which it wraps
+// around so that the texture repeats seamlessly.
+func silhouetteTexture(size image.Point, c color.RGBA, height, waves float64) *gfx.Texture {
+	img := image.NewRGBA(image.Rectangle{Max: size})
+	for x := 0; x < size.X; x++ {
+		t := 2 * math.Pi * float64(x) / float64(size.X)
+		ridge := 0.6 + 0.25*math.Sin(waves*t) + 0.15*math.Sin(3*waves*t+1)
+		top := size.Y - int(height*ridge)
+		for y := top; y < size.Y; y++ {
+			if y >= 0 {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+	tex := gfx.NewTexture()
+	tex.Source = img
+	tex.Bounds = img.Bounds()
+	tex.MinFilter = gfx.Linear
+	tex.MagFilter = gfx.Linear
+	return tex
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"sort"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// parentGen changes whenever an object is attached or detached, telling
+// scenes to sort their objects into hierarchy order again.
+var parentGen int
+
+// SetParent attaches child to parent, so that the transform of child becomes
+// relative to the one of parent and follows it. The local transform of child
+// is kept as it is. A nil parent detaches child, keeping it where it is in the
+// world instead. Attaching an object below itself is an error.
+func SetParent(child, parent *gfx.Object) error {
+	for p := parent; p != nil; p = Parent(p) {
+		if p == child {
+			return errors.New("SetParent: the parent chain would contain a cycle")
+		}
+	}
+	if parent == Parent(child) {
+		return nil
+	}
+	if parent == nil {
+		world := child.Convert(gfx.LocalToWorld)
+		child.Transform.SetParent(nil)
+		propsOf(child).parent = nil
+		setWorldMatrix(child, world)
+	} else {
+		child.Transform.SetParent(parent.Transform)
+		propsOf(child).parent = parent
+	}
+	parentGen++
+	return nil
+}
+
+// Parent returns the object o is attached to, or nil.
+func Parent(o *gfx.Object) *gfx.Object {
+	if p, ok := props[o]; ok {
+		return p.parent
+	}
+	return nil
+}
+
+// depth returns how many parents o has above it.
+func depth(o *gfx.Object) int {
+	n := 0
+	for p := Parent(o); p != nil; p = Parent(p) {
+		n++
+	}
+	return n
+}
+
+// localMatrix returns the transform of o relative to its parent.
+func localMatrix(o *gfx.Object) lmath.Mat4 {
+	m := o.Convert(gfx.LocalToWorld)
+	if p := Parent(o); p != nil {
+		if inv, ok := p.Convert(gfx.LocalToWorld).Inverse(); ok {
+			m = m.Mul(inv)
+		}
+	}
+	return m
+}
+
+// setWorldMatrix sets the position, rotation and scale of the unattached
+// object o from the world matrix m, which must not be sheared.
+func setWorldMatrix(o *gfx.Object, m lmath.Mat4) {
+	x := lmath.Vec3{m[0][0], m[0][1], m[0][2]}
+	y := lmath.Vec3{m[1][0], m[1][1], m[1][2]}
+	z := lmath.Vec3{m[2][0], m[2][1], m[2][2]}
+	o.SetPos(lmath.Vec3{m[3][0], m[3][1], m[3][2]})
+	o.SetScale(lmath.Vec3{x.Length(), y.Length(), z.Length()})
+	o.SetQuat(quatFromAxes(x.Normalized(), y.Normalized(), z.Normalized()))
+}
+
+// hierarchyOrder returns the objects of the scene with every parent before
+// its children, so that their transforms are updated in that order.
+func (s *Scene) hierarchyOrder() []*Object {
+	if s.ordered != nil && s.orderGen == parentGen {
+		return s.ordered
+	}
+	s.ordered = append(make([]*Object, 0, len(s.objects)), s.objects...)
+	depths := make(map[*Object]int, len(s.ordered))
+	for _, o := range s.ordered {
+		depths[o] = depth(o.Object)
+	}
+	sort.SliceStable(s.ordered, func(i, j int) bool {
+		return depths[s.ordered[i]] < depths[s.ordered[j]]
+	})
+	s.orderGen = parentGen
+	return s.ordered
+}
@@ -0,0 +1,43 @@
+#version 120
+
+varying vec4 color;
+varying vec2 tc0;
+
+// Texture0 is the packed window depth of the opaque scene, read only when
+// Softness is above zero. Softness is the distance in world units over which
+// particles fade out in front of it.
+uniform sampler2D Texture0;
+uniform float Softness;
+
+// The camera the scene depth was rendered with, and the size of the canvas.
+uniform float Near;
+uniform float Far;
+uniform bool Ortho;
+uniform float CanvasWidth;
+uniform float CanvasHeight;
+
+// eyeDepth returns the distance along the view of the window depth z.
+float eyeDepth(float z)
+{
+	if (Ortho) {
+		return Near + z * (Far - Near);
+	}
+	float ndc = z * 2.0 - 1.0;
+	return 2.0 * Near * Far / (Far + Near - ndc * (Far - Near));
+}
+
+void main()
+{
+	// Round puffs, fading out from their center.
+	float r = length(tc0 * 2.0 - 1.0);
+	float alpha = color.a * (1.0 - smoothstep(0.3, 1.0, r));
+
+	if (Softness > 0.0) {
+		// The depth texture is upside down compared to the window.
+		vec2 uv = vec2(gl_FragCoord.x / CanvasWidth, 1.0 - gl_FragCoord.y / CanvasHeight);
+		vec3 enc = texture2D(Texture0, uv).rgb;
+		float scene = min(dot(enc, vec3(1.0, 1.0 / 255.0, 1.0 / 65025.0)), 1.0);
+		alpha *= clamp((eyeDepth(scene) - eyeDepth(gl_FragCoord.z)) / Softness, 0.0, 1.0);
+	}
+	gl_FragColor = vec4(color.rgb, alpha);
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"image"
+	"log"
+	"sort"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/gfx/gfxutil"
+	"azul3d.org/engine/lmath"
+)
+
+// ParticleSystem emits round camera facing particles from a point, which
+// drift, grow and fade out over their life.
+type ParticleSystem struct {
+	// Pos is where particles are emitted, Rate how many per second and Life
+	// how many seconds each lives for.
+	Pos  lmath.Vec3
+	Rate float64
+	Life float64
+
+	// Velocity is the initial velocity of the particles, randomly off by up
+	// to Spread along every axis.
+	Velocity lmath.Vec3
+	Spread   float64
+
+	// Size is the width of the particles when emitted, growing by Growth
+	// per second.
+	Size   float64
+	Growth float64
+	Color  gfx.Color
+
+	softness  float64
+	particles []particle
+	pending   float64
+	random    *FrameRandom
+	steps     int64
+
+	// built is the scene update the quads were last built for. Stereo
+	// eyes draw the quads built for the first, as the device only reads
+	// the mesh once the frame is rendered.
+	built int
+
+	obj  *gfx.Object
+	mesh *gfx.Mesh
+}
+
+type particle struct {
+	pos, vel lmath.Vec3
+	age      float64
+}
+
+// AddParticles adds a system emitting up to max particles at once from pos,
+// drawing their random motion from random, and returns it.
+func (s *Scene) AddParticles(pos lmath.Vec3, max int, random *FrameRandom) *ParticleSystem {
+	p := &ParticleSystem{
+		Pos:       pos,
+		Rate:      10,
+		Life:      2,
+		Velocity:  lmath.Vec3{0, 0, 0.5},
+		Spread:    0.1,
+		Size:      0.3,
+		Growth:    0.2,
+		Color:     gfx.Color{1, 1, 1, 1},
+		particles: make([]particle, 0, max),
+		random:    random,
+	}
+	p.mesh = gfx.NewMesh()
+	p.mesh.TexCoords = []gfx.TexCoordSet{{}}
+
+	p.obj = gfx.NewObject()
+	p.obj.State = gfx.NewState()
+	p.obj.AlphaMode = gfx.AlphaBlend
+	p.obj.DepthWrite = false
+	p.obj.FaceCulling = gfx.NoFaceCulling
+	p.obj.Meshes = []*gfx.Mesh{p.mesh}
+
+	s.particles = append(s.particles, p)
+	return p
+}
+
+// SetSoftness fades the particles out over depthRange world units in front of
+// the opaque surfaces behind them, rather than cutting them along a hard line
+// where they intersect. Zero draws them hard.
+func (p *ParticleSystem) SetSoftness(depthRange float64) {
+	if depthRange < 0 {
+		depthRange = 0
+	}
+	p.softness = depthRange
+}
+
+// Softness returns the depth range set by SetSoftness.
+func (p *ParticleSystem) Softness() float64 {
+	return p.softness
+}
+
+// update ages and moves the particles by dt seconds, removing the dead ones
+// and emitting new ones up to the capacity of the system.
+func (p *ParticleSystem) update(dt float64) {
+	live := p.particles[:0]
+	for _, pt := range p.particles {
+		pt.age += dt
+		if pt.age >= p.Life {
+			continue
+		}
+		pt.pos = pt.pos.Add(pt.vel.MulScalar(dt))
+		live = append(live, pt)
+	}
+	p.particles = live
+
+	p.pending += p.Rate * dt
+	r := p.random.StepStream("particles", p.steps)
+	p.steps++
+	for ; p.pending >= 1; p.pending-- {
+		if len(p.particles) == cap(p.particles) {
+			continue
+		}
+		jitter := lmath.Vec3{r.Float64()*2 - 1, r.Float64()*2 - 1, r.Float64()*2 - 1}
+		p.particles = append(p.particles, particle{
+			pos: p.Pos,
+			vel: p.Velocity.Add(jitter.MulScalar(p.Spread)),
+		})
+	}
+}
+
+// build rewrites the quads of the particles to face the camera at eye, from
+// back to front.
+func (p *ParticleSystem) build(eye lmath.Vec3) {
+	sort.Slice(p.particles, func(i, j int) bool {
+		return p.particles[i].pos.Sub(eye).LengthSq() > p.particles[j].pos.Sub(eye).LengthSq()
+	})
+
+	p.mesh.Lock()
+	m := p.mesh
+	m.Vertices = m.Vertices[:0]
+	m.Colors = m.Colors[:0]
+	m.TexCoords[0].Slice = m.TexCoords[0].Slice[:0]
+	m.Indices = m.Indices[:0]
+	for i, pt := range p.particles {
+		// Span the quad across the view direction, level with the ground.
+		dir := pt.pos.Sub(eye)
+		right := dir.Cross(lmath.Vec3{0, 0, 1})
+		if l := right.Length(); l > 1e-9 {
+			right = right.DivScalar(l)
+		} else {
+			right = lmath.Vec3{1, 0, 0}
+		}
+		up := right.Cross(dir)
+		if l := up.Length(); l > 1e-9 {
+			up = up.DivScalar(l)
+		}
+		h := (p.Size + p.Growth*pt.age) / 2
+		right, up = right.MulScalar(h), up.MulScalar(h)
+
+		c := p.Color
+		c.A *= float32(1 - pt.age/p.Life)
+		for _, corner := range [4]struct{ x, y float64 }{{-1, -1}, {1, -1}, {-1, 1}, {1, 1}} {
+			v := pt.pos.Add(right.MulScalar(corner.x)).Add(up.MulScalar(corner.y))
+			m.Vertices = append(m.Vertices, gfx.ConvertVec3(v))
+			m.Colors = append(m.Colors, c)
+			m.TexCoords[0].Slice = append(m.TexCoords[0].Slice, gfx.TexCoord{
+				U: float32(corner.x+1) / 2,
+				V: float32(1-corner.y) / 2,
+			})
+		}
+		a := uint32(4 * i)
+		m.Indices = append(m.Indices, a, a+1, a+2, a+2, a+1, a+3)
+	}
+	m.Changed = true
+	p.mesh.Unlock()
+}
+
+// particleObjects builds the particle systems for cam, drawn to a canvas of
+// bounds b, once per update of the scene, and returns the objects of those
+// with live particles, or nil if the particle shader is unavailable.
+func (s *Scene) particleObjects(cam *camera.Camera, b image.Rectangle) []*gfx.Object {
+	if len(s.particles) == 0 {
+		return nil
+	}
+	if s.particleSources == nil {
+		sh, err := gfxutil.OpenShader("particle")
+		if err != nil {
+			log.Println("Particles disabled:", err)
+			s.particles = nil
+			return nil
+		}
+		s.particleSources = sh.GLSL
+	}
+	objs := s.particleObjs[:0]
+	for _, p := range s.particles {
+		if len(p.particles) == 0 {
+			continue
+		}
+		objs = append(objs, p.obj)
+		if p.built == s.updates && p.obj.Shader != nil {
+			continue
+		}
+		p.built = s.updates
+		p.build(cam.Pos())
+		if p.obj.Shader == nil {
+			p.obj.Shader = gfx.NewShader("particle")
+			p.obj.Shader.GLSL = s.particleSources
+		}
+
+		// Without the depth of the scene, soft particles are drawn hard.
+		soft := p.softness > 0 && s.depth != nil && s.depth.ready
+		p.obj.Textures = nil
+		sh := p.obj.Shader
+		sh.Lock()
+		sh.Inputs["Softness"] = float32(0)
+		if soft {
+			p.obj.Textures = []*gfx.Texture{s.depth.color}
+			sh.Inputs["Softness"] = float32(p.softness)
+			sh.Inputs["Near"] = float32(cam.Near)
+			sh.Inputs["Far"] = float32(cam.Far)
+			sh.Inputs["Ortho"] = cam.Ortho
+			sh.Inputs["CanvasWidth"] = float32(b.Dx())
+			sh.Inputs["CanvasHeight"] = float32(b.Dy())
+		}
+		sh.Unlock()
+	}
+	s.particleObjs = objs
+	return objs
+}
+
+// softParticles reports whether any particle system of the scene is soft.
+func (s *Scene) softParticles() bool {
+	for _, p := range s.particles {
+		if p.softness > 0 && len(p.particles) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// depthBuffer renders the depth of the opaque objects of the scene, packed
+// into the color of a texture like the shadow map, for soft particles to fade
+// out against.
+type depthBuffer struct {
+	shader  *gfx.Shader
+	canvas  gfx.Canvas
+	color   *gfx.Texture
+	proxies map[*gfx.Object]*gfx.Object
+
+	// ready is set once the texture holds the depth of the current frame.
+	ready bool
+}
+
+// RenderDepth renders the depth of the opaque objects of the scene as seen by
+// cam into a texture of bounds b, the one the scene is then drawn to, for the
+// soft particle systems to read. It does nothing when none is soft.
+func (s *Scene) RenderDepth(d gfx.Device, cam *camera.Camera, b image.Rectangle) {
+	if s.depth != nil {
+		s.depth.ready = false
+	}
+	if !s.softParticles() {
+		return
+	}
+	if s.depth == nil {
+		sh, err := gfxutil.OpenShader("depth")
+		if err != nil {
+			log.Println("Soft particles disabled:", err)
+			for _, p := range s.particles {
+				p.softness = 0
+			}
+			return
+		}
+		s.depth = &depthBuffer{
+			shader:  sh,
+			proxies: make(map[*gfx.Object]*gfx.Object),
+		}
+	}
+	db := s.depth
+	if db.canvas == nil || db.canvas.Bounds() != b {
+		cfg := d.Info().RTTFormats.ChooseConfig(gfx.Precision{
+			RedBits: 8, GreenBits: 8, BlueBits: 8, AlphaBits: 8,
+			DepthBits: 24,
+		}, true)
+		db.color = gfx.NewTexture()
+		db.color.MinFilter = gfx.Nearest
+		db.color.MagFilter = gfx.Nearest
+		db.color.WrapU = gfx.Clamp
+		db.color.WrapV = gfx.Clamp
+		cfg.Color = db.color
+		cfg.Bounds = b
+		db.canvas = d.RenderToTexture(cfg)
+		if db.canvas == nil {
+			log.Println("Soft particles disabled: render to texture is not supported.")
+			for _, p := range s.particles {
+				p.softness = 0
+			}
+			return
+		}
+	}
+
+	// The background is as far as the camera sees, which white encodes.
+	c := db.canvas
+	c.Clear(c.Bounds(), gfx.Color{1, 1, 1, 1})
+	c.ClearDepth(c.Bounds(), 1.0)
+	for _, o := range s.inView(viewProj(cam)) {
+		if o.AlphaMode != gfx.AlphaBlend {
+			c.Draw(c.Bounds(), db.proxy(o), cam)
+		}
+	}
+	c.Render()
+	db.ready = true
+}
+
+// proxy returns the object drawing the depth of o. Like the proxies of the
+// shadow map it shares the transform and meshes of o, and the depth shader.
+func (db *depthBuffer) proxy(o *gfx.Object) *gfx.Object {
+	px, ok := db.proxies[o]
+	if !ok {
+		px = gfx.NewObject()
+		px.State = gfx.NewState()
+		px.AlphaMode = gfx.NoAlpha
+		px.Dithering = false
+		px.Shader = db.shader
+		db.proxies[o] = px
+	}
+	px.Transform = o.Transform
+	px.Meshes = o.Meshes
+	px.FaceCulling = o.FaceCulling
+	return px
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"image"
+	"math"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+)
+
+// Pick returns the nearest object whose bounds are under the pixel p of the
+// canvas bounds b, or nil if there is none.
+func (s *Scene) Pick(cam *camera.Camera, b image.Rectangle, p image.Point) *gfx.Object {
+	origin, dir, ok := screenRay(cam, b, p)
+	if !ok {
+		return nil
+	}
+
+	var (
+		hit     *gfx.Object
+		hitDist = math.Inf(1)
+	)
+	for _, o := range s.objects {
+		t, ok := rayBox(origin, dir, worldBounds(o.Object))
+		if ok && t < hitDist {
+			hit, hitDist = o.Object, t
+		}
+	}
+	return hit
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"log"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/gfxutil"
+)
+
+// idPicker renders every object with a color encoding its identifier, so the
+// object under a pixel can be read back exactly.
+type idPicker struct {
+	sources *gfx.GLSLSources
+	canvas  gfx.Canvas
+	color   *gfx.Texture
+	proxies map[*gfx.Object]*gfx.Object
+}
+
+func newIDPicker() (*idPicker, error) {
+	sh, err := gfxutil.OpenShader("id")
+	if err != nil {
+		return nil, err
+	}
+	return &idPicker{
+		sources: sh.GLSL,
+		proxies: make(map[*gfx.Object]*gfx.Object),
+	}, nil
+}
+
+// idColor encodes the identifier id, 1 and up, into 24 bits of RGB.
+func idColor(id int) gfx.Color {
+	return gfx.Color{
+		R: float32(id>>16&0xff) / 255,
+		G: float32(id>>8&0xff) / 255,
+		B: float32(id&0xff) / 255,
+		A: 1,
+	}
+}
+
+// colorID decodes the identifier of idColor, 0 is the background.
+func colorID(c color.Color) int {
+	r, g, b, _ := c.RGBA()
+	return int(r>>8)<<16 | int(g>>8)<<8 | int(b>>8)
+}
+
+// proxy returns the object drawing o with the ID shader. It shares the
+// transform and meshes of o, but has its own shader so that every object can
+// have its own ID input. Cutouts keep their texture and threshold, so that
+// what they discard is not picked.
+func (p *idPicker) proxy(o *gfx.Object, id int) *gfx.Object {
+	px, ok := p.proxies[o]
+	if !ok {
+		px = gfx.NewObject()
+		px.State = gfx.NewState()
+		px.Dithering = false
+		px.Shader = gfx.NewShader("id")
+		px.Shader.GLSL = p.sources
+		p.proxies[o] = px
+	}
+	px.Transform = o.Transform
+	px.Meshes = o.Meshes
+	px.FaceCulling = o.FaceCulling
+
+	var threshold interface{}
+	px.AlphaMode, px.Textures = gfx.NoAlpha, nil
+	if (o.AlphaMode == gfx.BinaryAlpha || o.AlphaMode == gfx.AlphaToCoverage) && len(o.Textures) > 0 {
+		px.AlphaMode = gfx.BinaryAlpha
+		px.Textures = o.Textures[:1]
+		if o.Shader != nil {
+			o.Shader.RLock()
+			threshold = o.Shader.Inputs["AlphaThreshold"]
+			o.Shader.RUnlock()
+		}
+	}
+	px.Shader.Lock()
+	px.Shader.Inputs["ID"] = idColor(id)
+	px.Shader.Inputs["AlphaThreshold"] = float32(0)
+	if threshold != nil {
+		px.Shader.Inputs["AlphaThreshold"] = threshold
+	}
+	px.Shader.Unlock()
+	return px
+}
+
+// PickByID returns the frontmost object drawn at the pixel screenPos, in
+// framebuffer pixels, by the last call to Draw, or nil if there is none.
+// Unlike Pick it tests the actual triangles rather than bounds, at the cost of
+// rendering the scene again and waiting for the result.
+func (s *Scene) PickByID(d gfx.Device, screenPos image.Point) *gfx.Object {
+	done := make(chan image.Image, 1)
+	objects, ok := s.renderIDs(d, screenPos, done)
+	if !ok {
+		return nil
+	}
+	return objectWithID(<-done, objects)
+}
+
+// renderIDs renders the objects of the scene in the view of the camera of the
+// last call to Draw with the color encoding their identifier, and downloads
+// the pixel p of them to done. Like the frame dump, the download is queued
+// after the draws and before the canvas is rendered, and arrives once it is.
+// It returns the objects rendered, which the identifiers index, and reports
+// whether anything was rendered, which it is not with no camera or no render
+// to texture. Batched objects are drawn by themselves, to keep an identifier
+// each.
+func (s *Scene) renderIDs(d gfx.Device, p image.Point, done chan image.Image) ([]*Object, bool) {
+	if s.cam == nil {
+		return nil, false
+	}
+	if s.picker == nil {
+		picker, err := newIDPicker()
+		if err != nil {
+			log.Println(err)
+			return nil, false
+		}
+		s.picker = picker
+	}
+	pk := s.picker
+
+	if pk.canvas == nil || pk.canvas.Bounds() != d.Bounds() {
+		cfg := d.Info().RTTFormats.ChooseConfig(gfx.Precision{
+			RedBits: 8, GreenBits: 8, BlueBits: 8, AlphaBits: 8,
+			DepthBits: 24,
+		}, true)
+		pk.color = gfx.NewTexture()
+		pk.color.MinFilter = gfx.Nearest
+		pk.color.MagFilter = gfx.Nearest
+		cfg.Color = pk.color
+		cfg.Bounds = d.Bounds()
+		pk.canvas = d.RenderToTexture(cfg)
+		if pk.canvas == nil {
+			log.Println("PickByID: render to texture is not supported.")
+			return nil, false
+		}
+	}
+
+	c := pk.canvas
+	c.Clear(c.Bounds(), gfx.Color{0, 0, 0, 0})
+	c.ClearDepth(c.Bounds(), 1.0)
+	objects := s.objectsInView(viewProj(s.cam))
+	for i, o := range objects {
+		px := pk.proxy(o.Object, i+1)
+		s.setClipInputs(px.Shader)
+		c.Draw(c.Bounds(), px, s.cam)
+	}
+	c.Download(image.Rect(p.X, p.Y, p.X+1, p.Y+1), done)
+	c.Render()
+	return objects, true
+}
+
+// objectWithID returns the object of objects whose identifier is encoded by
+// the first pixel of img, downloaded from the canvas of renderIDs, or nil.
+func objectWithID(img image.Image, objects []*Object) *gfx.Object {
+	if img == nil {
+		return nil
+	}
+	id := colorID(img.At(img.Bounds().Min.X, img.Bounds().Min.Y))
+	if id < 1 || id > len(objects) {
+		return nil
+	}
+	return objects[id-1].Object
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// SetPivot sets the point, in the local space of o, that o rotates around. The
+// default pivot is the local origin.
+func SetPivot(o *gfx.Object, pivot lmath.Vec3) {
+	propsOf(o).pivot = pivot
+	applyPivot(o)
+}
+
+// applyPivot offsets the position of o so that its current rotation happens
+// around its pivot instead of its local origin, i.e. the transform becomes
+// translate(pivot) * rotate * translate(-pivot). It returns the offset to its
+// previous value first, so it can be called every frame.
+func applyPivot(o *gfx.Object) {
+	p, ok := props[o]
+	if !ok {
+		return
+	}
+	pos := o.Pos().Sub(p.pivotOffset)
+
+	// Where the pivot ends up with rotation and scale, but without the
+	// translation, versus where it would be with scale only.
+	scale := o.Scale()
+	scaled := lmath.Vec3{p.pivot.X * scale.X, p.pivot.Y * scale.Y, p.pivot.Z * scale.Z}
+	turned := transformPoint(localMatrix(o), p.pivot).Sub(o.Pos())
+
+	p.pivotOffset = scaled.Sub(turned)
+	o.SetPos(pos.Add(p.pivotOffset))
+}
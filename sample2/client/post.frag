@@ -0,0 +1,65 @@
+#version 120
+
+varying vec2 tc0;
+
+uniform sampler2D Texture0;
+uniform float Gamma;
+
+// When UseLUT is set, colors are remapped through the LUTSize^3 color lookup
+// table in Texture1, unrolled into LUTSize slices of blue side by side.
+uniform sampler2D Texture1;
+uniform bool UseLUT;
+uniform float LUTSize;
+
+// When MotionBlur is above zero, the scene is blurred along the screen space
+// motion in Texture2, encoded as made by the velocity shader.
+uniform sampler2D Texture2;
+uniform float MotionBlur;
+uniform float MaxVelocity;
+
+const int blurSamples = 8;
+
+vec4 blur(vec2 uv)
+{
+	vec2 v = (texture2D(Texture2, uv).rg * 255.0 - 127.0) / 127.0 * MaxVelocity * MotionBlur;
+	vec4 sum = vec4(0.0);
+	for(int i = 0; i < blurSamples; i++) {
+		float t = float(i) / float(blurSamples - 1) - 0.5;
+		sum += texture2D(Texture0, uv + v * t);
+	}
+	return sum / float(blurSamples);
+}
+
+vec3 lookup(vec3 c)
+{
+	c = clamp(c, 0.0, 1.0);
+	float n = LUTSize;
+
+	// Sample the two nearest blue slices bilinearly, at texel centers, and
+	// blend them for trilinear interpolation.
+	float b = c.b * (n - 1.0);
+	float b0 = floor(b);
+	float b1 = min(b0 + 1.0, n - 1.0);
+	vec2 uv = vec2((c.r * (n - 1.0) + 0.5) / (n * n), (c.g * (n - 1.0) + 0.5) / n);
+	vec3 s0 = texture2D(Texture1, uv + vec2(b0 / n, 0.0)).rgb;
+	vec3 s1 = texture2D(Texture1, uv + vec2(b1 / n, 0.0)).rgb;
+	return mix(s0, s1, b - b0);
+}
+
+void main()
+{
+	vec4 c;
+	if(MotionBlur > 0.0) {
+		c = blur(tc0);
+	} else {
+		c = texture2D(Texture0, tc0);
+	}
+	if(UseLUT) {
+		c.rgb = lookup(c.rgb);
+	}
+
+	// Gamma is applied last, after every other color adjustment.
+	c.rgb = pow(c.rgb, vec3(1.0 / Gamma));
+
+	gl_FragColor = c;
+}
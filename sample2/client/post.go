@@ -0,0 +1,192 @@
+package main
+
+import (
+	"image"
+	"log"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/lmath"
+)
+
+// PostProcess renders the scene into a texture and then draws that texture to
+// the screen through a shader applying the final color adjustments. When no
+// adjustment is active the scene is drawn to the screen directly.
+type PostProcess struct {
+	// Gamma is the exponent of the final power curve, 1 leaves colors as
+	// they are.
+	Gamma float64
+
+	// Pixelation is the size in screen pixels of the blocks the scene is
+	// drawn with, 1 draws it at full resolution.
+	Pixelation int
+
+	// LUT is the color lookup table the scene colors are remapped through,
+	// see NewColorLUT, or nil for none.
+	LUT *gfx.Texture
+
+	// MotionBlur scales how far along the motion in Velocity the scene is
+	// blurred, zero turns it off. Velocity is the texture made by
+	// Scene.RenderVelocity for the frame, or nil.
+	MotionBlur float64
+	Velocity   *gfx.Texture
+
+	// unsupported is set once render to texture failed, which disables
+	// post processing for good.
+	unsupported bool
+
+	shader *gfx.Shader
+	color  *gfx.Texture
+	canvas gfx.Canvas
+	quad   *gfx.Object
+	cam    *camera.Camera
+}
+
+func NewPostProcess(shader *gfx.Shader) *PostProcess {
+	return &PostProcess{
+		Gamma:      1,
+		Pixelation: 1,
+		shader:     shader,
+	}
+}
+
+// Enabled reports whether any adjustment is active.
+func (p *PostProcess) Enabled() bool {
+	if p.unsupported {
+		return false
+	}
+	return p.Gamma != 1 || p.Pixelation > 1 || p.LUT != nil || p.MotionBlur > 0
+}
+
+// Begin returns the canvas the scene should be drawn to this frame, which is
+// d itself when no adjustment is active or the device cannot render to
+// textures.
+func (p *PostProcess) Begin(d gfx.Device) gfx.Canvas {
+	if !p.Enabled() {
+		return d
+	}
+	if p.canvas == nil || p.canvas.Bounds() != p.canvasBounds(d) {
+		p.resize(d)
+	}
+	if p.canvas == nil {
+		return d
+	}
+	return p.canvas
+}
+
+// End draws the scene texture to the device through the post shader, if the
+// scene was drawn to it.
+func (p *PostProcess) End(d gfx.Device) {
+	if !p.Enabled() || p.canvas == nil {
+		return
+	}
+	p.canvas.Render()
+
+	// Upscale the blocks of a pixelated scene without blurring them.
+	filter := gfx.Linear
+	if p.Pixelation > 1 {
+		filter = gfx.Nearest
+	}
+	p.color.MinFilter = filter
+	p.color.MagFilter = filter
+
+	p.shader.Lock()
+	p.shader.Inputs["Gamma"] = float32(p.Gamma)
+	p.shader.Inputs["UseLUT"] = p.LUT != nil
+	if p.LUT != nil {
+		p.shader.Inputs["LUTSize"] = float32(p.LUT.Bounds.Dy())
+	}
+	blur := p.MotionBlur > 0 && p.Velocity != nil
+	p.shader.Inputs["MotionBlur"] = float32(0)
+	if blur {
+		p.shader.Inputs["MotionBlur"] = float32(p.MotionBlur)
+		p.shader.Inputs["MaxVelocity"] = float32(maxVelocity)
+	}
+	p.shader.Unlock()
+
+	// The velocity is always the third texture, with the scene standing in
+	// for a missing LUT.
+	p.quad.Textures = []*gfx.Texture{p.color}
+	if p.LUT != nil {
+		p.quad.Textures = append(p.quad.Textures, p.LUT)
+	}
+	if blur {
+		if p.LUT == nil {
+			p.quad.Textures = append(p.quad.Textures, p.color)
+		}
+		p.quad.Textures = append(p.quad.Textures, p.Velocity)
+	}
+
+	b := d.Bounds()
+	p.quad.SetScale(lmath.Vec3{float64(b.Dx()), 1, float64(b.Dy())})
+	d.ClearDepth(b, 1.0)
+	d.Draw(b, p.quad, p.cam)
+}
+
+// canvasBounds returns the bounds of the canvas the scene is drawn to, which
+// are the device bounds divided by the pixelation block size.
+func (p *PostProcess) canvasBounds(d gfx.Device) image.Rectangle {
+	b := d.Bounds()
+	if p.Pixelation <= 1 {
+		return b
+	}
+	w, h := b.Dx()/p.Pixelation, b.Dy()/p.Pixelation
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return image.Rect(0, 0, w, h)
+}
+
+// resize creates the render to texture canvas matching the device bounds,
+// scaled down by the pixelation block size.
+func (p *PostProcess) resize(d gfx.Device) {
+	cfg := d.Info().RTTFormats.ChooseConfig(gfx.Precision{
+		RedBits: 8, GreenBits: 8, BlueBits: 8, AlphaBits: 8,
+		DepthBits: 24,
+	}, true)
+
+	p.color = gfx.NewTexture()
+	p.color.MinFilter = gfx.Linear
+	p.color.MagFilter = gfx.Linear
+	p.color.WrapU = gfx.Clamp
+	p.color.WrapV = gfx.Clamp
+	cfg.Color = p.color
+	cfg.Bounds = p.canvasBounds(d)
+
+	p.canvas = d.RenderToTexture(cfg)
+	if p.canvas == nil {
+		log.Println("Post processing disabled: render to texture is not supported.")
+		p.unsupported = true
+		p.Gamma = 1
+		p.Pixelation = 1
+		p.LUT = nil
+		p.MotionBlur = 0
+		return
+	}
+
+	if p.quad == nil {
+		p.quad = newQuad(p.shader)
+		p.quad.AlphaMode = gfx.NoAlpha
+		p.quad.DepthTest = false
+	}
+	if p.cam == nil {
+		p.cam = newOrthoCamera(d.Bounds())
+	} else {
+		p.cam.Update(d.Bounds())
+	}
+}
+
+// setGamma clamps and sets the gamma, treating values close to one as one so
+// that nudging it back and forth can disable the post process again.
+func (p *PostProcess) setGamma(g float64) {
+	if g < 0.1 {
+		g = 0.1
+	}
+	if d := g - 1; d > -1e-6 && d < 1e-6 {
+		g = 1
+	}
+	p.Gamma = g
+}
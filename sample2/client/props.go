@@ -0,0 +1,115 @@
+package main
+
+import (
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// objectProps holds the per-object settings that gfx.Object has no field for.
+type objectProps struct {
+	pivot       lmath.Vec3
+	pivotOffset lmath.Vec3
+
+	// shader is the copy of baseShader owned by the object, see ownShader,
+	// and lit the copy it shares with the objects reached by the same
+	// lights, see setLightInputs. At most one of them is in use.
+	shader, lit, baseShader *gfx.Shader
+
+	// static is the cached state of an object frozen by SetStatic, or nil.
+	static *staticState
+
+	// sway is how much the object sways in the wind, see SetWindSway.
+	sway float64
+
+	// material is the material last set by SetMaterial, or nil.
+	material *Material
+
+	// parent is the object o is attached to by SetParent, or nil.
+	parent *gfx.Object
+
+	// sort overrides the transparency sort distance, see SetSortDistance.
+	sort *sortOverride
+
+	// Shadow flags, inverted so that the zero value casts and receives.
+	noCastShadow, noReceiveShadow bool
+}
+
+var props = make(map[*gfx.Object]*objectProps)
+
+// propsOf returns the settings of o, creating default ones if needed.
+func propsOf(o *gfx.Object) *objectProps {
+	p, ok := props[o]
+	if !ok {
+		p = &objectProps{}
+		props[o] = p
+	}
+	return p
+}
+
+// ownShader returns a shader that only o uses, so that inputs set on it apply
+// to o alone. The first call replaces the shader of o with a copy sharing its
+// sources and inputs. Inputs cannot be changed between draws of a shared
+// shader instead, as the device reads them once the frame is rendered.
+func ownShader(o *gfx.Object) *gfx.Shader {
+	p := propsOf(o)
+	if p.shader != nil && o.Shader == p.shader {
+		return p.shader
+	}
+	src := o.Shader
+	sh := copyShader(src)
+	base := baseShader(o)
+	o.Shader = sh
+	p.shader, p.lit, p.baseShader = sh, nil, base
+	// Static batches are drawn with the base shader, and the copy takes o
+	// out of them.
+	if p.static != nil {
+		staticGen++
+	}
+	return sh
+}
+
+// copyShader returns a new shader with the sources and inputs of src.
+func copyShader(src *gfx.Shader) *gfx.Shader {
+	sh := gfx.NewShader(src.Name)
+	src.RLock()
+	sh.GLSL = src.GLSL
+	for k, v := range src.Inputs {
+		sh.Inputs[k] = v
+	}
+	src.RUnlock()
+	return sh
+}
+
+// replaceBaseShader makes sh the shader o was given. If o owns a copy of its
+// old shader, the copy is replaced by one of sh keeping the inputs set on it.
+func replaceBaseShader(o *gfx.Object, sh *gfx.Shader) {
+	p, ok := props[o]
+	if !ok || p.shader == nil || o.Shader != p.shader {
+		o.Shader = sh
+	} else {
+		own := p.shader
+		o.Shader = sh
+		cp := ownShader(o)
+		own.RLock()
+		cp.Lock()
+		for k, v := range own.Inputs {
+			cp.Inputs[k] = v
+		}
+		cp.Unlock()
+		own.RUnlock()
+	}
+
+	// Static batches are grouped by shader.
+	if ok && p.static != nil {
+		staticGen++
+	}
+}
+
+// baseShader returns the shader o was given, before any ownShader or lit
+// copy.
+func baseShader(o *gfx.Object) *gfx.Shader {
+	if p, ok := props[o]; ok && o.Shader != nil && (o.Shader == p.shader || o.Shader == p.lit) {
+		return p.baseShader
+	}
+	return o.Shader
+}
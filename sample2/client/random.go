@@ -0,0 +1,89 @@
+package main
+
+import (
+	"hash/fnv"
+	"math/rand"
+)
+
+// FrameRandom is the source of every random number effects use, so that runs
+// with the same seed and input look the same. The numbers of a frame only
+// depend on the seed, the frame number and the stream they are drawn from:
+// an effect drawing more numbers in one frame does not change what the others
+// draw, nor what any draws in the next frame.
+type FrameRandom struct {
+	seed    int64
+	frame   int64
+	streams map[string]*rand.Rand
+}
+
+func NewFrameRandom(seed int64) *FrameRandom {
+	return &FrameRandom{
+		seed:    seed,
+		streams: make(map[string]*rand.Rand),
+	}
+}
+
+// SetRandomSeed restarts the random numbers of the effects from seed, as if
+// the game had started with it. The source is reset in place, so the effects
+// holding it keep using it, and the meadow is scattered again from the seed.
+func (g *Game) SetRandomSeed(seed int64) {
+	*g.random = *NewFrameRandom(seed)
+	if g.meadow != nil {
+		g.meadow.SetRandomSeed(seed)
+	}
+}
+
+// Seed returns the seed the numbers are derived from.
+func (r *FrameRandom) Seed() int64 {
+	return r.seed
+}
+
+// Frame returns the number of the current frame, counted from the seed being
+// set.
+func (r *FrameRandom) Frame() int64 {
+	return r.frame
+}
+
+// BeginFrame advances to the next frame, restarting every stream.
+func (r *FrameRandom) BeginFrame() {
+	r.frame++
+	for k := range r.streams {
+		delete(r.streams, k)
+	}
+}
+
+// Stream returns the numbers of the named stream for the current frame. Each
+// effect should use a stream of its own, such as "particles" or "jitter".
+func (r *FrameRandom) Stream(name string) *rand.Rand {
+	s, ok := r.streams[name]
+	if !ok {
+		h := fnv.New64a()
+		h.Write([]byte(name))
+		x := uint64(r.seed) ^ mix64(uint64(r.frame)) ^ mix64(h.Sum64())
+		s = rand.New(rand.NewSource(int64(mix64(x))))
+		r.streams[name] = s
+	}
+	return s
+}
+
+// StepStream returns the numbers of the named stream for the fixed simulation
+// step numbered step. Unlike the ones of Stream they do not depend on the frame
+// the step runs in, so a simulation run in fixed steps draws the same numbers
+// however the steps fall into frames.
+func (r *FrameRandom) StepStream(name string, step int64) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	x := uint64(r.seed) ^ mix64(^uint64(step)) ^ mix64(h.Sum64())
+	return rand.New(rand.NewSource(int64(mix64(x))))
+}
+
+// mix64 scrambles the bits of x, so that nearby seeds and frames give
+// unrelated sequences. It is the finalizer of SplitMix64.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
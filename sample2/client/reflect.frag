@@ -0,0 +1,23 @@
+#version 120
+
+varying vec4 worldPos;
+
+uniform sampler2D Texture0;
+
+// ReflectionMatrix transforms world space into the clip space of the camera
+// mirrored across the reflecting plane, which Texture0 was rendered with.
+uniform mat4 ReflectionMatrix;
+uniform float Reflectivity;
+uniform float Distortion;
+
+void main()
+{
+	vec4 p = ReflectionMatrix * worldPos;
+	vec2 uv = vec2(0.5 + 0.5 * p.x / p.w, 0.5 - 0.5 * p.y / p.w);
+
+	// Ripple the reflection, as if the surface was not quite flat.
+	uv += Distortion * vec2(sin(worldPos.x * 40.0 + worldPos.y * 13.0), cos(worldPos.y * 40.0 - worldPos.x * 17.0));
+
+	vec4 color = texture2D(Texture0, uv);
+	gl_FragColor = vec4(color.rgb, color.a * Reflectivity);
+}
@@ -0,0 +1,19 @@
+#version 120
+
+attribute vec3 Vertex;
+
+uniform mat4 MVP;
+uniform mat4 Model;
+uniform float DepthBias;
+
+varying vec4 worldPos;
+
+void main()
+{
+	worldPos = Model * vec4(Vertex, 1.0);
+	gl_Position = MVP * vec4(Vertex, 1.0);
+
+	// Pull the reflection towards the camera, so that it wins the depth test
+	// against the surface it is blended onto.
+	gl_Position.z -= DepthBias * gl_Position.w;
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"log"
+	"math"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/gfx/gfxutil"
+	"azul3d.org/engine/lmath"
+)
+
+// reflectionClipOffset is how far above the plane geometry has to be to show
+// in a reflection, so that the reflecting surfaces themselves are clipped.
+const reflectionClipOffset = 0.01
+
+// PlanarReflection mirrors the scene in a plane, like a polished floor. The
+// scene is rendered from the camera mirrored across the plane into a texture,
+// which is then blended onto the surfaces added with AddSurface.
+type PlanarReflection struct {
+	// Reflectivity is the opacity of the reflection over the surfaces, zero
+	// turns it off.
+	Reflectivity float64
+
+	// Distortion is how far, in texture coordinates, the reflection is
+	// rippled. Zero gives a perfect mirror.
+	Distortion float64
+
+	normal lmath.Vec3
+	d      float64
+
+	surfaces map[*gfx.Object]bool
+
+	canvas gfx.Canvas
+	color  *gfx.Texture
+	cam    *camera.Camera
+	shader *gfx.Shader
+
+	// matrix transforms world space into the clip space of the mirrored
+	// camera, and ready is set once the reflection was rendered with it.
+	matrix lmath.Mat4
+	ready  bool
+
+	proxies map[*gfx.Object]*gfx.Object
+
+	// The scene is rendered into the reflection through proxies drawn with
+	// copies of the shaders of the objects, so that the clip plane of the
+	// reflection leaves the shaders of the objects alone. The copies are
+	// kept by the sources they compile, and handed out to the shaders using
+	// them anew every render, so that shaders replaced by new copies of
+	// their own reuse the compiled ones. Proxies and copies are dropped once
+	// a render went without them, stamp counting the renders.
+	sceneProxies map[*gfx.Object]*reflectedProxy
+	copies       map[shaderSources][]*reflectedCopy
+	copyOf       map[*gfx.Shader]*reflectedCopy
+	stamp        int
+}
+
+// shaderSources identifies what a shader compiles to.
+type shaderSources struct {
+	name string
+	glsl *gfx.GLSLSources
+}
+
+// reflectedProxy is the object drawing a scene object into a reflection, and
+// reflectedCopy the copy of a shader it is drawn with, as of the render stamp.
+type reflectedProxy struct {
+	*gfx.Object
+	stamp int
+}
+
+type reflectedCopy struct {
+	shader *gfx.Shader
+	stamp  int
+}
+
+func NewPlanarReflection() *PlanarReflection {
+	return &PlanarReflection{
+		Reflectivity: 0.5,
+		normal:       lmath.Vec3{0, 0, 1},
+		surfaces:     make(map[*gfx.Object]bool),
+		proxies:      make(map[*gfx.Object]*gfx.Object),
+		sceneProxies: make(map[*gfx.Object]*reflectedProxy),
+		copies:       make(map[shaderSources][]*reflectedCopy),
+		copyOf:       make(map[*gfx.Shader]*reflectedCopy),
+	}
+}
+
+// SetPlane sets the plane to mirror the scene in, made of the points p where
+// normal.Dot(p) + d is zero. Only what is on the side the normal points to is
+// reflected.
+func (r *PlanarReflection) SetPlane(normal lmath.Vec3, d float64) {
+	l := normal.Length()
+	r.normal = normal.DivScalar(l)
+	r.d = d / l
+}
+
+// AddSurface makes the reflection show on o, which should lie in the plane.
+func (r *PlanarReflection) AddSurface(o *gfx.Object) {
+	r.surfaces[o] = true
+}
+
+// AddReflection adds the planar reflection r to the scene.
+func (s *Scene) AddReflection(r *PlanarReflection) {
+	s.reflections = append(s.reflections, r)
+}
+
+// RenderReflections renders the reflections of the scene as seen by cam,
+// adding what was drawn to stats, which should not be those of Draw as the
+// mirrored views cull objects of their own. Like RenderShadows it comes before
+// Draw. With stereo both eyes show the reflections rendered for the main
+// camera between them, which is slightly off for each eye but not noticeably
+// so at the usual eye separations.
+func (s *Scene) RenderReflections(d gfx.Device, cam *camera.Camera, stats *RenderStats) {
+	if len(s.reflections) == 0 {
+		return
+	}
+	if s.reflectSources == nil {
+		sh, err := gfxutil.OpenShader("reflect")
+		if err != nil {
+			log.Println("Reflections disabled:", err)
+			s.reflections = nil
+			return
+		}
+		s.reflectSources = sh.GLSL
+	}
+
+	// Hide what is below each plane with a free clip plane, and keep the
+	// reflections from being drawn into themselves.
+	saved := s.clipPlanes
+	free := -1
+	for i, p := range s.clipPlanes {
+		if p == (lmath.Vec4{}) {
+			free = i
+			break
+		}
+	}
+	defer func() {
+		s.clipPlanes = saved
+		s.reflecting = nil
+	}()
+
+	for _, r := range s.reflections {
+		r.ready = false
+		eye := cam.Pos()
+		if r.Reflectivity <= 0 || r.normal.Dot(eye)+r.d <= 0 {
+			continue
+		}
+		if err := r.resize(d, d.Bounds()); err != nil {
+			log.Println("Reflections disabled:", err)
+			s.reflections = nil
+			return
+		}
+		r.mirror(cam)
+		if free >= 0 {
+			s.clipPlanes[free] = lmath.Vec4{r.normal.X, r.normal.Y, r.normal.Z, r.d - reflectionClipOffset}
+		}
+
+		c := r.canvas
+		c.Clear(c.Bounds(), gfx.Color{0, 0, 0, 0})
+		c.ClearDepth(c.Bounds(), 1.0)
+		r.stamp++
+		s.reflecting = r
+		s.drawRect(c, c.Bounds(), r.cam, stats)
+		c.Render()
+		r.prune()
+		r.matrix = viewProj(r.cam)
+		r.ready = true
+	}
+}
+
+// sceneProxy returns the object drawing the scene object o into the
+// reflection. It shares the state, transform, meshes and textures of o, and
+// is drawn with a copy of its shader holding the clip planes of the scene as
+// they are while the reflection renders.
+func (r *PlanarReflection) sceneProxy(s *Scene, o *gfx.Object) *gfx.Object {
+	px, ok := r.sceneProxies[o]
+	if !ok {
+		px = &reflectedProxy{Object: gfx.NewObject()}
+		r.sceneProxies[o] = px
+	}
+	px.stamp = r.stamp
+	px.State = o.State
+	px.Transform = o.Transform
+	px.Meshes = o.Meshes
+	px.Textures = o.Textures
+	px.Shader = nil
+	if o.Shader != nil {
+		px.Shader = r.shaderCopy(s, o.Shader)
+	}
+	return px.Object
+}
+
+// shaderCopy returns the copy of sh the reflection is drawn with in this
+// render, with the inputs of sh. It is one with the same sources not handed
+// out yet in this render, or a new one if there is none.
+func (r *PlanarReflection) shaderCopy(s *Scene, sh *gfx.Shader) *gfx.Shader {
+	if cp, ok := r.copyOf[sh]; ok {
+		return cp.shader
+	}
+	sh.RLock()
+	defer sh.RUnlock()
+	key := shaderSources{sh.Name, sh.GLSL}
+	var cp *reflectedCopy
+	for _, c := range r.copies[key] {
+		if c.stamp != r.stamp {
+			cp = c
+			break
+		}
+	}
+	if cp == nil {
+		cp = &reflectedCopy{shader: gfx.NewShader(sh.Name)}
+		cp.shader.GLSL = sh.GLSL
+		r.copies[key] = append(r.copies[key], cp)
+	}
+	cp.stamp = r.stamp
+	r.copyOf[sh] = cp
+
+	cp.shader.Lock()
+	for k := range cp.shader.Inputs {
+		delete(cp.shader.Inputs, k)
+	}
+	for k, v := range sh.Inputs {
+		cp.shader.Inputs[k] = v
+	}
+	cp.shader.Unlock()
+	s.setClipInputs(cp.shader)
+	return cp.shader
+}
+
+// prune drops the proxies and shader copies the last render did not use, and
+// takes the copies back from the shaders they were handed to.
+func (r *PlanarReflection) prune() {
+	for o, px := range r.sceneProxies {
+		if px.stamp != r.stamp {
+			delete(r.sceneProxies, o)
+		}
+	}
+	for key, copies := range r.copies {
+		used := copies[:0]
+		for _, cp := range copies {
+			if cp.stamp == r.stamp {
+				used = append(used, cp)
+			}
+		}
+		if len(used) == 0 {
+			delete(r.copies, key)
+		} else {
+			r.copies[key] = used
+		}
+	}
+	for sh := range r.copyOf {
+		delete(r.copyOf, sh)
+	}
+}
+
+// resize creates the texture the reflection is rendered to, if it does not
+// exist yet or has other bounds than b.
+func (r *PlanarReflection) resize(d gfx.Device, b image.Rectangle) error {
+	if r.canvas != nil && r.canvas.Bounds() == b {
+		return nil
+	}
+	cfg := d.Info().RTTFormats.ChooseConfig(gfx.Precision{
+		RedBits: 8, GreenBits: 8, BlueBits: 8, AlphaBits: 8,
+		DepthBits: 24,
+	}, true)
+	color := gfx.NewTexture()
+	color.MinFilter = gfx.Linear
+	color.MagFilter = gfx.Linear
+	color.WrapU = gfx.Clamp
+	color.WrapV = gfx.Clamp
+	cfg.Color = color
+	cfg.Bounds = b
+	canvas := d.RenderToTexture(cfg)
+	if canvas == nil {
+		return errors.New("render to texture is not supported")
+	}
+	r.canvas, r.color = canvas, color
+	for _, px := range r.proxies {
+		px.Textures = []*gfx.Texture{color}
+	}
+	return nil
+}
+
+// mirror moves the camera of the reflection to cam mirrored across the plane.
+// The mirrored axes are left handed, so the camera is turned to the proper
+// rotation with the same forward and up axes instead: the image is flipped
+// sideways, which projecting the texture from the same camera undoes.
+func (r *PlanarReflection) mirror(cam *camera.Camera) {
+	b := r.canvas.Bounds()
+	if r.cam == nil {
+		r.cam = camera.New(b)
+	}
+	r.cam.FOV = cam.FOV
+	r.cam.Near = cam.Near
+	r.cam.Far = cam.Far
+	r.cam.Ortho = cam.Ortho
+	r.cam.Update(b)
+
+	m := cam.Object.Convert(gfx.LocalToWorld)
+	eye := transformPoint(m, lmath.Vec3{})
+	forward := transformPoint(m, lmath.Vec3{0, 1, 0}).Sub(eye)
+	up := transformPoint(m, lmath.Vec3{0, 0, 1}).Sub(eye)
+
+	reflect := func(v lmath.Vec3) lmath.Vec3 {
+		return v.Sub(r.normal.MulScalar(2 * r.normal.Dot(v)))
+	}
+	f := reflect(forward).Normalized()
+	u := reflect(up).Normalized()
+	r.cam.SetPos(eye.Sub(r.normal.MulScalar(2 * (r.normal.Dot(eye) + r.d))))
+	r.cam.SetQuat(quatFromAxes(f.Cross(u), f, u))
+}
+
+// quatFromAxes returns the rotation turning the X, Y and Z axes into the
+// orthonormal right handed axes x, y and z.
+func quatFromAxes(x, y, z lmath.Vec3) lmath.Quat {
+	var q lmath.Quat
+	switch trace := x.X + y.Y + z.Z; {
+	case trace > 0:
+		s := 0.5 / math.Sqrt(trace+1)
+		q = lmath.Quat{W: 0.25 / s, X: (y.Z - z.Y) * s, Y: (z.X - x.Z) * s, Z: (x.Y - y.X) * s}
+	case x.X > y.Y && x.X > z.Z:
+		s := 2 * math.Sqrt(1+x.X-y.Y-z.Z)
+		q = lmath.Quat{W: (y.Z - z.Y) / s, X: 0.25 * s, Y: (y.X + x.Y) / s, Z: (z.X + x.Z) / s}
+	case y.Y > z.Z:
+		s := 2 * math.Sqrt(1+y.Y-x.X-z.Z)
+		q = lmath.Quat{W: (z.X - x.Z) / s, X: (y.X + x.Y) / s, Y: 0.25 * s, Z: (z.Y + y.Z) / s}
+	default:
+		s := 2 * math.Sqrt(1+z.Z-x.X-y.Y)
+		q = lmath.Quat{W: (x.Y - y.X) / s, X: (z.X + x.Z) / s, Y: (z.Y + y.Z) / s, Z: 0.25 * s}
+	}
+	return q.Normalized()
+}
+
+// drawReflections blends each rendered reflection onto the opaque objects it
+// shows on, including static batches holding one of its surfaces.
+func (s *Scene) drawReflections(c gfx.Canvas, r image.Rectangle, cam *camera.Camera, stats *RenderStats) {
+	if s.reflecting != nil {
+		return
+	}
+	for _, refl := range s.reflections {
+		if !refl.ready {
+			continue
+		}
+		for _, o := range s.opaque {
+			if s.reflects(refl, o) {
+				px := refl.proxy(o, s.reflectSources)
+				c.Draw(r, px, cam)
+				stats.countDraw(px)
+			}
+		}
+	}
+}
+
+// reflects reports whether the reflection shows on o.
+func (s *Scene) reflects(refl *PlanarReflection, o *gfx.Object) bool {
+	if refl.surfaces[o] {
+		return true
+	}
+	for _, b := range s.batches {
+		if b.Object != o {
+			continue
+		}
+		for _, m := range b.members {
+			if refl.surfaces[m] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// proxy returns the object drawing the reflection over o. Like the proxies of
+// decals it shares the transform and meshes of o, and all of them share the
+// reflection shader.
+func (r *PlanarReflection) proxy(o *gfx.Object, sources *gfx.GLSLSources) *gfx.Object {
+	if r.shader == nil {
+		r.shader = gfx.NewShader("reflect")
+		r.shader.GLSL = sources
+	}
+	px, ok := r.proxies[o]
+	if !ok {
+		px = gfx.NewObject()
+		px.State = gfx.NewState()
+		px.AlphaMode = gfx.AlphaBlend
+		px.DepthWrite = false
+		px.Shader = r.shader
+		px.Textures = []*gfx.Texture{r.color}
+		r.proxies[o] = px
+	}
+	px.Transform = o.Transform
+	px.Meshes = o.Meshes
+	px.FaceCulling = o.FaceCulling
+
+	r.shader.Lock()
+	r.shader.Inputs["ReflectionMatrix"] = gfx.ConvertMat4(r.matrix)
+	r.shader.Inputs["Reflectivity"] = float32(r.Reflectivity)
+	r.shader.Inputs["Distortion"] = float32(r.Distortion)
+	r.shader.Inputs["DepthBias"] = float32(0.0005)
+	r.shader.Unlock()
+	return px
+}
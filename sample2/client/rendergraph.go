@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"strings"
+
+	"azul3d.org/engine/gfx"
+)
+
+// RenderGraph runs render passes in the order their inputs and outputs need,
+// rather than the order they were added in. The textures passes render into
+// are only named by them: the graph creates them, and passes whose textures
+// are never needed at the same time share the same one.
+type RenderGraph struct {
+	passes []*RenderPass
+
+	// order is the order passes run in, and slot the texture each output
+	// is rendered to, once compiled.
+	order    []*RenderPass
+	slot     map[string]int
+	targets  []*graphTarget
+	compiled bool
+}
+
+// RenderPass is a pass run by a RenderGraph.
+type RenderPass struct {
+	Name string
+
+	// Inputs are the names of the textures the pass reads, and Output the
+	// name of the one it renders into, or "" for the canvas the graph is
+	// executed to. Run renders the pass to c, given the textures of its
+	// inputs in order.
+	Inputs []string
+	Output string
+	Run    func(c gfx.Canvas, inputs []*gfx.Texture)
+}
+
+// graphTarget is a texture of the graph and the canvas rendering to it.
+type graphTarget struct {
+	color  *gfx.Texture
+	canvas gfx.Canvas
+}
+
+func NewRenderGraph() *RenderGraph {
+	return &RenderGraph{}
+}
+
+// AddPass adds the pass p to the graph, which has to be compiled again.
+func (g *RenderGraph) AddPass(p *RenderPass) {
+	g.passes = append(g.passes, p)
+	g.compiled = false
+}
+
+// Order returns the passes in the order they run in, once compiled.
+func (g *RenderGraph) Order() []*RenderPass {
+	return g.order
+}
+
+// Textures returns how many textures the passes render into, once compiled.
+// Outputs whose lifetimes do not overlap share one.
+func (g *RenderGraph) Textures() int {
+	return len(g.targets)
+}
+
+// Compile orders the passes so that every pass runs after those outputting
+// its inputs, keeping the order they were added in otherwise, and assigns
+// their outputs to textures. It fails if an input is never output, an output
+// is output by two passes, or passes depend on each other in a cycle.
+func (g *RenderGraph) Compile() error {
+	producer := make(map[string]*RenderPass)
+	for _, p := range g.passes {
+		if p.Output == "" {
+			continue
+		}
+		if q, ok := producer[p.Output]; ok {
+			return fmt.Errorf("render graph: passes %q and %q both output %q", q.Name, p.Name, p.Output)
+		}
+		producer[p.Output] = p
+	}
+
+	// Count the passes each pass waits for, and the passes waiting for it.
+	waits := make(map[*RenderPass]int)
+	next := make(map[*RenderPass][]*RenderPass)
+	for _, p := range g.passes {
+		for _, in := range p.Inputs {
+			q, ok := producer[in]
+			if !ok {
+				return fmt.Errorf("render graph: pass %q reads %q, which no pass outputs", p.Name, in)
+			}
+			waits[p]++
+			next[q] = append(next[q], p)
+		}
+	}
+
+	// Run the first added pass that waits for nothing, until none is left.
+	order := make([]*RenderPass, 0, len(g.passes))
+	done := make(map[*RenderPass]bool)
+	for len(order) < len(g.passes) {
+		var ready *RenderPass
+		for _, p := range g.passes {
+			if !done[p] && waits[p] == 0 {
+				ready = p
+				break
+			}
+		}
+		if ready == nil {
+			var names []string
+			for _, p := range g.passes {
+				if !done[p] {
+					names = append(names, fmt.Sprintf("%q", p.Name))
+				}
+			}
+			return fmt.Errorf("render graph: cycle between passes %s", strings.Join(names, ", "))
+		}
+		done[ready] = true
+		order = append(order, ready)
+		for _, p := range next[ready] {
+			waits[p]--
+		}
+	}
+
+	// An output lives from the pass rendering it to the last pass reading
+	// it, and takes the first texture free by then.
+	last := make(map[string]int)
+	for i, p := range order {
+		for _, in := range p.Inputs {
+			last[in] = i
+		}
+	}
+	slot := make(map[string]int)
+	var freeAfter []int
+	for i, p := range order {
+		if p.Output == "" {
+			continue
+		}
+		end, ok := last[p.Output]
+		if !ok {
+			log.Printf("render graph: the output %q of pass %q is never read\n", p.Output, p.Name)
+			end = i
+		}
+		s := -1
+		for j, f := range freeAfter {
+			if f < i {
+				s = j
+				break
+			}
+		}
+		if s < 0 {
+			s = len(freeAfter)
+			freeAfter = append(freeAfter, 0)
+		}
+		freeAfter[s] = end
+		slot[p.Output] = s
+	}
+
+	g.order = order
+	g.slot = slot
+	g.targets = make([]*graphTarget, len(freeAfter))
+	g.compiled = true
+	return nil
+}
+
+// Execute runs the passes, compiling the graph first if needed. Passes without
+// an output render to the canvas c, the textures of the others are the size
+// of it.
+func (g *RenderGraph) Execute(d gfx.Device, c gfx.Canvas) error {
+	if !g.compiled {
+		if err := g.Compile(); err != nil {
+			return err
+		}
+	}
+	b := c.Bounds()
+	for _, p := range g.order {
+		target := c
+		if p.Output != "" {
+			t, err := g.target(d, g.slot[p.Output], b)
+			if err != nil {
+				return err
+			}
+			target = t.canvas
+		}
+		inputs := make([]*gfx.Texture, len(p.Inputs))
+		for i, in := range p.Inputs {
+			inputs[i] = g.targets[g.slot[in]].color
+		}
+		p.Run(target, inputs)
+		if target != c {
+			target.Render()
+		}
+	}
+	return nil
+}
+
+// target returns the texture of the slot s, creating it for the bounds b if
+// it does not exist yet or has another size.
+func (g *RenderGraph) target(d gfx.Device, s int, b image.Rectangle) (*graphTarget, error) {
+	if t := g.targets[s]; t != nil && t.canvas.Bounds() == b {
+		return t, nil
+	}
+	cfg := d.Info().RTTFormats.ChooseConfig(gfx.Precision{
+		RedBits: 8, GreenBits: 8, BlueBits: 8, AlphaBits: 8,
+		DepthBits: 24,
+	}, true)
+	t := &graphTarget{color: gfx.NewTexture()}
+	t.color.MinFilter = gfx.Linear
+	t.color.MagFilter = gfx.Linear
+	t.color.WrapU = gfx.Clamp
+	t.color.WrapV = gfx.Clamp
+	cfg.Color = t.color
+	cfg.Bounds = b
+	t.canvas = d.RenderToTexture(cfg)
+	if t.canvas == nil {
+		return nil, fmt.Errorf("render graph: render to texture is not supported")
+	}
+	g.targets[s] = t
+	return t, nil
+}
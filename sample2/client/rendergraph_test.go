@@ -0,0 +1,141 @@
+package main
+
+import (
+	"image"
+	"strings"
+	"testing"
+
+	"azul3d.org/engine/gfx"
+)
+
+// graphDevice hands out graphCanvases as the textures of a render graph. Only
+// the methods the graph calls are there.
+type graphDevice struct {
+	gfx.Device
+}
+
+func (d *graphDevice) Info() gfx.DeviceInfo { return gfx.DeviceInfo{} }
+
+func (d *graphDevice) RenderToTexture(cfg gfx.RTTConfig) gfx.Canvas {
+	return &graphCanvas{bounds: cfg.Bounds, color: cfg.Color}
+}
+
+// graphCanvas is a canvas rendering into color, or the screen if it is nil.
+type graphCanvas struct {
+	gfx.Canvas
+	bounds image.Rectangle
+	color  *gfx.Texture
+}
+
+func (c *graphCanvas) Bounds() image.Rectangle { return c.bounds }
+
+func (c *graphCanvas) Render() {}
+
+// recordingPass returns a pass which, rather than drawing, records in contents
+// that the texture it renders to holds its name applied to its inputs.
+func recordingPass(contents map[*gfx.Texture]string, name, output string, inputs ...string) *RenderPass {
+	return &RenderPass{
+		Name:   name,
+		Inputs: inputs,
+		Output: output,
+		Run: func(c gfx.Canvas, textures []*gfx.Texture) {
+			in := make([]string, len(textures))
+			for i, t := range textures {
+				in[i] = contents[t]
+			}
+			contents[c.(*graphCanvas).color] = name + "(" + strings.Join(in, ", ") + ")"
+		},
+	}
+}
+
+// bloomGraph returns a graph of the passes of the bloom, added in reverse order
+// if reverse is set, and what they record.
+func bloomGraph(reverse bool) (*RenderGraph, map[*gfx.Texture]string) {
+	contents := make(map[*gfx.Texture]string)
+	passes := []*RenderPass{
+		recordingPass(contents, "scene", "scene"),
+		recordingPass(contents, "bright", "bright", "scene"),
+		recordingPass(contents, "blur x", "blur x", "bright"),
+		recordingPass(contents, "blur y", "blur y", "blur x"),
+		recordingPass(contents, "composite", "", "scene", "blur y"),
+	}
+	g := NewRenderGraph()
+	for i := range passes {
+		if reverse {
+			i = len(passes) - 1 - i
+		}
+		g.AddPass(passes[i])
+	}
+	return g, contents
+}
+
+func TestRenderGraphOrder(t *testing.T) {
+	screen := &graphCanvas{bounds: image.Rect(0, 0, 64, 64)}
+	const want = "composite(scene(), blur y(blur x(bright(scene()))))"
+
+	for _, reverse := range []bool{false, true} {
+		g, contents := bloomGraph(reverse)
+		if err := g.Execute(&graphDevice{}, screen); err != nil {
+			t.Fatal(err)
+		}
+		if got := contents[nil]; got != want {
+			t.Errorf("reverse %v: the screen holds %s, want %s", reverse, got, want)
+		}
+		var names []string
+		for _, p := range g.Order() {
+			names = append(names, p.Name)
+		}
+		if got := strings.Join(names, ", "); got != "scene, bright, blur x, blur y, composite" {
+			t.Errorf("reverse %v: ran %s", reverse, got)
+		}
+	}
+}
+
+func TestRenderGraphAliasing(t *testing.T) {
+	g, _ := bloomGraph(true)
+	if err := g.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The scene is read until the end, but the bright pass is done with
+	// once blurred along x, so the blur along y reuses its texture.
+	if g.Textures() != 3 {
+		t.Errorf("%d textures for 4 outputs, want 3", g.Textures())
+	}
+	if g.slot["bright"] != g.slot["blur y"] {
+		t.Errorf("bright and blur y do not share a texture")
+	}
+	if g.slot["scene"] == g.slot["bright"] || g.slot["scene"] == g.slot["blur x"] || g.slot["bright"] == g.slot["blur x"] {
+		t.Errorf("outputs needed at the same time share a texture: %v", g.slot)
+	}
+}
+
+func TestRenderGraphErrors(t *testing.T) {
+	contents := make(map[*gfx.Texture]string)
+	for _, c := range []struct {
+		name   string
+		passes []*RenderPass
+		want   string
+	}{
+		{"cycle", []*RenderPass{
+			recordingPass(contents, "a", "a", "b"),
+			recordingPass(contents, "b", "b", "a"),
+			recordingPass(contents, "out", "", "a"),
+		}, "cycle"},
+		{"missing input", []*RenderPass{
+			recordingPass(contents, "out", "", "a"),
+		}, "no pass outputs"},
+		{"two outputs", []*RenderPass{
+			recordingPass(contents, "a", "a"),
+			recordingPass(contents, "b", "a"),
+		}, "both output"},
+	} {
+		g := NewRenderGraph()
+		for _, p := range c.passes {
+			g.AddPass(p)
+		}
+		if err := g.Compile(); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got error %v, want one about %q", c.name, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// Ruler is a HUD overlay of faint gridlines with tick marks along the top and
+// left edges of the screen, labeled with their pixel coordinates.
+type Ruler struct {
+	// Text renders the coordinate labels.
+	Text *TextRenderer
+
+	spacing int
+	quad    *gfx.Object
+	bounds  image.Rectangle
+}
+
+func NewRuler(shader *gfx.Shader, text *TextRenderer) *Ruler {
+	return &Ruler{
+		Text:    text,
+		spacing: 100,
+		quad:    newQuad(shader),
+	}
+}
+
+// SetSpacing sets the distance between labeled gridlines, in framebuffer
+// pixels.
+func (r *Ruler) SetSpacing(px int) {
+	if px < 10 {
+		px = 10
+	}
+	r.spacing = px
+	r.redraw()
+}
+
+// Resize redraws the ruler for new screen bounds.
+func (r *Ruler) Resize(bounds image.Rectangle) {
+	if bounds == r.bounds {
+		return
+	}
+	r.bounds = bounds
+	r.redraw()
+}
+
+func (r *Ruler) redraw() {
+	w, h := r.bounds.Dx(), r.bounds.Dy()
+	if w <= 0 || h <= 0 {
+		return
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	grid := image.NewUniform(color.NRGBA{0, 0, 0, 40})
+	tick := image.NewUniform(color.NRGBA{0, 0, 0, 200})
+
+	// Major ticks and gridlines at every spacing, with minor ticks at the
+	// fifths in between. Each minor tick is placed from its major one, so
+	// that spacings not divisible by five do not drift.
+	major, minor := 10*r.Text.Scale, 4*r.Text.Scale
+	for x := 0; x < w; x += r.spacing {
+		draw.Draw(img, image.Rect(x, 0, x+1, h), grid, image.ZP, draw.Over)
+		draw.Draw(img, image.Rect(x, 0, x+1, major), tick, image.ZP, draw.Over)
+		for i := 1; i < 5; i++ {
+			mx := x + i*r.spacing/5
+			draw.Draw(img, image.Rect(mx, 0, mx+1, minor), tick, image.ZP, draw.Over)
+		}
+		if x > 0 {
+			r.label(img, strconv.Itoa(x), image.Pt(x+2, major))
+		}
+	}
+	for y := 0; y < h; y += r.spacing {
+		draw.Draw(img, image.Rect(0, y, w, y+1), grid, image.ZP, draw.Over)
+		draw.Draw(img, image.Rect(0, y, major, y+1), tick, image.ZP, draw.Over)
+		for i := 1; i < 5; i++ {
+			my := y + i*r.spacing/5
+			draw.Draw(img, image.Rect(0, my, minor, my+1), tick, image.ZP, draw.Over)
+		}
+		if y > 0 {
+			r.label(img, strconv.Itoa(y), image.Pt(major, y+2))
+		}
+	}
+
+	tex := gfx.NewTexture()
+	tex.Source = img
+	tex.MinFilter = gfx.Nearest
+	tex.MagFilter = gfx.Nearest
+	r.quad.Textures = []*gfx.Texture{tex}
+	r.quad.SetScale(lmath.Vec3{float64(w), 1, float64(h)})
+}
+
+// label draws s onto img with its top-left corner at p.
+func (r *Ruler) label(img *image.RGBA, s string, p image.Point) {
+	t := r.Text.Render(s)
+	draw.Draw(img, t.Bounds().Add(p), t, image.ZP, draw.Over)
+}
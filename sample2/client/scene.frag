@@ -0,0 +1,164 @@
+#version 120
+
+varying vec2 tc0;
+varying vec4 worldPos;
+varying vec3 worldNormal;
+varying vec3 bary;
+
+uniform sampler2D Texture0;
+uniform bool BinaryAlpha;
+
+// AlphaThreshold is the alpha below which BinaryAlpha discards fragments, 0.5
+// when it is not set.
+uniform float AlphaThreshold;
+
+// World space clip planes, fragments on their negative side are discarded. A
+// zero plane clips nothing.
+uniform vec4 ClipPlane0;
+uniform vec4 ClipPlane1;
+uniform vec4 ClipPlane2;
+uniform vec4 ClipPlane3;
+
+// Emissive light added after everything else, scaled by EmissiveStrength.
+uniform vec4 Emissive;
+uniform float EmissiveStrength;
+
+// SelectTint and Highlight are mixed into the color by their alpha, to show
+// the selected objects and the object under the cursor.
+uniform vec4 SelectTint;
+uniform vec4 Highlight;
+
+// When Wireframe is set, triangle edges WireWidth pixels wide are drawn in
+// WireColor over the fill, found from the barycentric coordinates.
+uniform bool Wireframe;
+uniform vec4 WireColor;
+uniform float WireWidth;
+
+// When MipClamp is set, the mip levels sampled from Texture0, which is
+// TextureWidth by TextureHeight pixels, are clamped to
+// [MipBaseLevel, MipMaxLevel].
+uniform bool MipClamp;
+uniform float MipBaseLevel;
+uniform float MipMaxLevel;
+uniform float TextureWidth;
+uniform float TextureHeight;
+
+// When Lighting is set, the texture color is lit by the directional light and
+// by up to four point lights, the unused ones having a zero radius.
+uniform bool Lighting;
+uniform vec3 SunDir;
+uniform vec4 SunColor;
+uniform vec4 Ambient;
+uniform vec3 LightPos0;
+uniform vec3 LightPos1;
+uniform vec3 LightPos2;
+uniform vec3 LightPos3;
+uniform vec4 LightColor0;
+uniform vec4 LightColor1;
+uniform vec4 LightColor2;
+uniform vec4 LightColor3;
+uniform float LightRadius0;
+uniform float LightRadius1;
+uniform float LightRadius2;
+uniform float LightRadius3;
+uniform float LightFalloff0;
+uniform float LightFalloff1;
+uniform float LightFalloff2;
+uniform float LightFalloff3;
+
+// When ReceiveShadow is set, Texture1 is the shadow map of the directional
+// light, holding the packed depth of the casters in the clip space that
+// ShadowMatrix transforms world space into.
+uniform bool ReceiveShadow;
+uniform sampler2D Texture1;
+uniform mat4 ShadowMatrix;
+
+float sunVisibility()
+{
+	if(!ReceiveShadow) {
+		return 1.0;
+	}
+	vec3 p = (ShadowMatrix * worldPos).xyz;
+	if(any(greaterThan(abs(p), vec3(1.0)))) {
+		return 1.0;
+	}
+	vec3 enc = texture2D(Texture1, vec2(0.5 + 0.5 * p.x, 0.5 - 0.5 * p.y)).rgb;
+	float casterDepth = dot(enc, vec3(1.0, 1.0 / 255.0, 1.0 / 65025.0));
+	float depth = 0.5 + 0.5 * p.z;
+
+	// The bias keeps surfaces from shadowing themselves.
+	return depth - 0.005 > casterDepth ? 0.0 : 1.0;
+}
+
+vec3 pointLight(vec3 n, vec3 pos, vec4 color, float radius, float falloff)
+{
+	if(radius <= 0.0) {
+		return vec3(0.0);
+	}
+	vec3 l = pos - worldPos.xyz;
+	float d = length(l);
+	float atten = pow(clamp(1.0 - d / radius, 0.0, 1.0), falloff);
+	return color.rgb * max(dot(n, l / d), 0.0) * atten;
+}
+
+vec3 lighting()
+{
+	// Surfaces are lit on whichever side faces the camera.
+	vec3 n = normalize(worldNormal);
+	if(!gl_FrontFacing) {
+		n = -n;
+	}
+	vec3 c = Ambient.rgb + SunColor.rgb * max(dot(n, -SunDir), 0.0) * sunVisibility();
+	c += pointLight(n, LightPos0, LightColor0, LightRadius0, LightFalloff0);
+	c += pointLight(n, LightPos1, LightColor1, LightRadius1, LightFalloff1);
+	c += pointLight(n, LightPos2, LightColor2, LightRadius2, LightFalloff2);
+	c += pointLight(n, LightPos3, LightColor3, LightRadius3, LightFalloff3);
+	return c;
+}
+
+vec4 sampleTexture(vec2 tc)
+{
+	if(!MipClamp) {
+		return texture2D(Texture0, tc);
+	}
+
+	// Estimate the level the hardware would pick, and bias it towards the
+	// clamped one.
+	vec2 texels = tc * vec2(TextureWidth, TextureHeight);
+	vec2 dx = dFdx(texels);
+	vec2 dy = dFdy(texels);
+	float lod = 0.5 * log2(max(dot(dx, dx), dot(dy, dy)));
+	return texture2D(Texture0, tc, clamp(lod, MipBaseLevel, MipMaxLevel) - lod);
+}
+
+// edgeFactor returns how much of the fragment is covered by a triangle edge,
+// smoothed over a pixel so the edges are antialiased.
+float edgeFactor()
+{
+	vec3 d = fwidth(bary);
+	vec3 a = smoothstep(d * (WireWidth - 0.5), d * (WireWidth + 0.5), bary);
+	return 1.0 - min(min(a.x, a.y), a.z);
+}
+
+void main()
+{
+	if(dot(worldPos, ClipPlane0) < 0.0 || dot(worldPos, ClipPlane1) < 0.0 ||
+	   dot(worldPos, ClipPlane2) < 0.0 || dot(worldPos, ClipPlane3) < 0.0) {
+		discard;
+	}
+
+	gl_FragColor = sampleTexture(tc0);
+	float threshold = AlphaThreshold > 0.0 ? AlphaThreshold : 0.5;
+	if(BinaryAlpha && gl_FragColor.a < threshold) {
+		discard;
+	}
+	if(Lighting) {
+		gl_FragColor.rgb *= lighting();
+	}
+	gl_FragColor.rgb += Emissive.rgb * EmissiveStrength;
+	gl_FragColor.rgb = mix(gl_FragColor.rgb, SelectTint.rgb, SelectTint.a);
+	gl_FragColor.rgb = mix(gl_FragColor.rgb, Highlight.rgb, Highlight.a);
+	if(Wireframe) {
+		gl_FragColor.rgb = mix(gl_FragColor.rgb, WireColor.rgb, edgeFactor() * WireColor.a);
+	}
+}
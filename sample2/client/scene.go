@@ -1,34 +1,449 @@
-package main
-
-type Scene struct {
-	objects []Object
-}
-
-func NewScene() *Scene {
-	return &Scene{
-		objects: nil,
-	}
-}
-
-func (s *Scene) Update() {
-	for _, o := range s.objects {
-		o.Update()
-	}
-}
-
-//func (s *Scene) Start() {
-//	go s.listen()
-//}
-
-//func (s *Scene) listen() {
-//	ticker := time.NewTicker(time.Second / 60.0)
-
-//	for {
-//		select {
-
-//		case <-ticker.C:
-//			s.Update()
-
-//		}
-//	}
-//}
+package main
+
+import (
+	"image"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/lmath"
+)
+
+type Scene struct {
+	objects []*Object
+
+	// The objects sorted by hierarchyOrder, when parentGen was orderGen.
+	// ordered is dropped whenever objects changes, see objectsChanged.
+	ordered  []*Object
+	orderGen int
+
+	textureNames map[*gfx.Texture]string
+	shaders      map[string]*gfx.Shader
+	meshes       map[string]*gfx.Mesh
+
+	// diffed holds the names of the objects ApplyDiff added.
+	diffed map[string]bool
+
+	// DiffAll makes ApplyDiff also update and remove the named objects it
+	// did not add, such as those added in code.
+	DiffAll bool
+
+	// OnRemove, if set, is called with every object removed from the scene,
+	// once the scene forgot it.
+	OnRemove func(o *Object)
+
+	clipPlanes [MaxClipPlanes]lmath.Vec4
+
+	// TeleportDistance is how far an interpolated object has to move in one
+	// simulation step to jump there rather than slide, see FixedUpdate.
+	TeleportDistance float64
+
+	// Wind sways the objects given a sway with SetWindSway.
+	Wind Wind
+
+	// time is how long the scene was updated for, in seconds.
+	time float64
+
+	// Sun is the directional light of the scene, or nil for none. The scene
+	// is drawn unlit while it has no light at all.
+	Sun *DirectionalLight
+
+	// Shadows sets whether the directional light casts shadows, see
+	// RenderShadows.
+	Shadows bool
+	shadow  *shadowMap
+
+	lights     []*PointLight
+	nearLights []*PointLight
+
+	// litShaders are the copies of shaders drawing the objects reached by
+	// the same lights, and updates counts the calls to Update they were last
+	// drawn in.
+	litShaders map[litKey]*litShader
+	updates    int
+
+	decals       []*Decal
+	decalSources *gfx.GLSLSources
+
+	// reflecting is the reflection being rendered, or nil.
+	reflections    []*PlanarReflection
+	reflectSources *gfx.GLSLSources
+	reflecting     *PlanarReflection
+
+	// index is the spatial index culling uses, if built.
+	index *spatialIndex
+
+	// Static batches, rebuilt when staticGen changes.
+	batches  []*staticBatch
+	batched  map[*gfx.Object]bool
+	batchGen int
+
+	lods           []*LODObject
+	imposterShader *gfx.Shader
+	billboards     map[*gfx.Object]*gfx.Object
+	instances      []*InstancedObject
+
+	// occlusion skips the objects hidden last frame, when culling them is
+	// on. occluding is set while Draw tests them.
+	occlusion *occlusionCuller
+	occluding bool
+
+	trails      []*Trail
+	trailShader *gfx.Shader
+	trailObjs   []*gfx.Object
+
+	// debug is drawn after everything else until the next Update, with
+	// its own shader unless that failed to load and debugOff is set.
+	debug       *DebugDraw
+	debugShader *gfx.Shader
+	debugOff    bool
+
+	// particles are drawn with their own shader from particleSources, and
+	// the soft ones fade out against depth. They move in the fixed steps of
+	// effectStep, so that they do not depend on how long the frames are.
+	particles       []*ParticleSystem
+	effectStep      *FixedStep
+	particleSources *gfx.GLSLSources
+	particleObjs    []*gfx.Object
+	depth           *depthBuffer
+
+	// cam is the camera the scene was last drawn with.
+	cam      *camera.Camera
+	picker   *idPicker
+	hover    *hoverState
+	velocity *velocityBuffer
+
+	// Draw lists, kept to avoid allocating them every frame.
+	opaque, transparent []*gfx.Object
+
+	shaderIDs  map[*gfx.Shader]int
+	textureIDs map[*gfx.Texture]int
+}
+
+func NewScene() *Scene {
+	return &Scene{
+		objects:          nil,
+		TeleportDistance: 1,
+		effectStep:       NewFixedStep(60),
+		textureNames:     make(map[*gfx.Texture]string),
+		shaders:          make(map[string]*gfx.Shader),
+		meshes:           make(map[string]*gfx.Mesh),
+		diffed:           make(map[string]bool),
+		billboards:       make(map[*gfx.Object]*gfx.Object),
+		shaderIDs:        make(map[*gfx.Shader]int),
+		textureIDs:       make(map[*gfx.Texture]int),
+	}
+}
+
+// NameTexture sets the name t is referred to by when the scene is saved.
+func (s *Scene) NameTexture(name string, t *gfx.Texture) {
+	s.textureNames[t] = name
+}
+
+// NameShader sets the name sh is referred to by when the scene is saved, and
+// which loaded scenes use to find it.
+func (s *Scene) NameShader(name string, sh *gfx.Shader) {
+	s.shaders[name] = sh
+}
+
+func (s *Scene) shaderName(o *gfx.Object) string {
+	sh := baseShader(o)
+	for name, v := range s.shaders {
+		if v == sh {
+			return name
+		}
+	}
+	return ""
+}
+
+func (s *Scene) Add(o *Object) {
+	s.objects = append(s.objects, o)
+	s.objectsChanged()
+	if s.index != nil {
+		s.index.update(o)
+	}
+}
+
+// Remove removes o from the scene and forgets the settings made for it, such
+// as its pivot and material, and what the passes drawing the scene keep for
+// it. Its children are detached, staying where they are.
+func (s *Scene) Remove(o *Object) {
+	for i, v := range s.objects {
+		if v != o {
+			continue
+		}
+		s.objects = append(s.objects[:i], s.objects[i+1:]...)
+		s.objectsChanged()
+		if s.index != nil {
+			s.index.remove(o)
+		}
+		if IsStatic(o.Object) {
+			staticGen++
+		}
+		for _, c := range s.objects {
+			if Parent(c.Object) == o.Object {
+				SetParent(c.Object, nil)
+			}
+		}
+		delete(props, o.Object)
+		s.forget(o.Object)
+		if s.OnRemove != nil {
+			s.OnRemove(o)
+		}
+		return
+	}
+}
+
+// forget drops the proxies the passes of the scene draw o with, and stops
+// hovering it.
+func (s *Scene) forget(o *gfx.Object) {
+	if s.shadow != nil {
+		delete(s.shadow.proxies, o)
+	}
+	if s.picker != nil {
+		delete(s.picker.proxies, o)
+	}
+	if s.velocity != nil {
+		delete(s.velocity.proxies, o)
+	}
+	if s.depth != nil {
+		delete(s.depth.proxies, o)
+	}
+	for _, r := range s.reflections {
+		delete(r.surfaces, o)
+		delete(r.proxies, o)
+		delete(r.sceneProxies, o)
+	}
+	for _, d := range s.decals {
+		delete(d.proxies, o)
+	}
+	delete(s.billboards, o)
+	if s.hover != nil && s.hover.hovered == o {
+		s.hover.hovered = nil
+	}
+}
+
+// objectsChanged drops what was worked out from the objects of the scene, to be
+// worked out again. It has to be called whenever they are added, removed or
+// replaced, as the same number of them can be different ones.
+func (s *Scene) objectsChanged() {
+	s.ordered = nil
+}
+
+// object returns the scene object wrapping o, or nil.
+func (s *Scene) object(o *gfx.Object) *Object {
+	if o == nil {
+		return nil
+	}
+	for _, so := range s.objects {
+		if so.Object == o {
+			return so
+		}
+	}
+	return nil
+}
+
+func (s *Scene) Update(dt float64) {
+	s.time += dt
+	s.updates++
+	s.pruneLitShaders()
+	if s.debug != nil {
+		s.debug.clear()
+	}
+	for _, o := range s.hierarchyOrder() {
+		o.Update(dt)
+		applyPivot(o.Object)
+		switch {
+		case s.index != nil:
+			s.index.update(o)
+		case s.batched[o.Object]:
+			// Only check whether it moved, which rebuilds the batches.
+			worldBounds(o.Object)
+		}
+	}
+	for _, t := range s.trails {
+		t.record()
+	}
+	s.effectStep.Advance(dt, func(dt float64) {
+		for _, p := range s.particles {
+			p.update(dt)
+		}
+	})
+	if s.cam != nil {
+		s.updateLODs(s.cam.Pos())
+	}
+}
+
+// inView returns the objects of the scene which are in the view of vp, culled
+// the way Draw culls them but without occlusion culling, and without trails,
+// particles or debug drawing. It is for the passes drawn from another camera
+// than the one of the last Draw, or before it.
+func (s *Scene) inView(vp lmath.Mat4) []*gfx.Object {
+	if s.batched == nil || s.batchGen != staticGen {
+		s.buildBatches()
+	}
+	var (
+		list  []*gfx.Object
+		stats RenderStats
+	)
+	add := func(o *gfx.Object) {
+		if inFrustum(vp, worldBounds(o)) {
+			list = append(list, o)
+		}
+	}
+	for _, o := range s.objectsInView(vp) {
+		if !s.batched[o.Object] {
+			list = append(list, o.Object)
+		}
+	}
+	for _, b := range s.batches {
+		add(b.Object)
+	}
+	for _, inst := range s.instances {
+		for _, cell := range inst.visibleCells(vp, &stats) {
+			add(cell)
+		}
+	}
+	return list
+}
+
+// objectsInView returns the objects of the scene inside the view frustum vp,
+// whether they are batched or not.
+func (s *Scene) objectsInView(vp lmath.Mat4) []*Object {
+	candidates := s.objects
+	if s.index != nil {
+		candidates = s.index.query(vp)
+	}
+	var list []*Object
+	for _, o := range candidates {
+		if inFrustum(vp, worldBounds(o.Object)) {
+			list = append(list, o)
+		}
+	}
+	return list
+}
+
+// Draw draws the objects of the scene that are inside the view of the camera
+// to the canvas, adding what was drawn and culled to stats. Opaque objects are
+// drawn first, grouped by shader and texture, then transparent ones from back
+// to front.
+func (s *Scene) Draw(c gfx.Canvas, cam *camera.Camera, stats *RenderStats) {
+	s.cam = cam
+	s.occluding = s.occlusion != nil
+	s.drawRect(c, c.Bounds(), cam, stats)
+	s.occluding = false
+}
+
+// drawRect is like Draw, but draws to the rectangle r of the canvas and does
+// not change the camera used for picking.
+func (s *Scene) drawRect(c gfx.Canvas, r image.Rectangle, cam *camera.Camera, stats *RenderStats) {
+	if s.batched == nil || s.batchGen != staticGen {
+		s.buildBatches()
+	}
+
+	if s.occluding {
+		s.occlusion.begin()
+	}
+
+	vp := viewProj(cam)
+	s.opaque, s.transparent = s.opaque[:0], s.transparent[:0]
+	add := func(o *gfx.Object) {
+		o = s.drawn(o)
+		if !inFrustum(vp, worldBounds(o)) {
+			stats.Culled++
+			return
+		}
+		if s.occluding && s.occlusion.hidden(o, cam) {
+			stats.OcclusionCulled++
+			return
+		}
+		setMipInputs(o)
+		s.setLightInputs(o)
+		s.setWindInputs(o)
+		if o.AlphaMode == gfx.AlphaBlend {
+			s.transparent = append(s.transparent, o)
+		} else {
+			s.opaque = append(s.opaque, o)
+		}
+	}
+	candidates := s.objects
+	if s.index != nil {
+		candidates = s.index.query(vp)
+		stats.Culled += len(s.objects) - len(candidates)
+	}
+	stats.Objects += len(s.objects)
+	stats.Tested += len(candidates)
+	for _, o := range candidates {
+		if !s.batched[o.Object] {
+			add(o.Object)
+		}
+	}
+	for _, b := range s.batches {
+		add(b.Object)
+	}
+	for _, inst := range s.instances {
+		for _, cell := range inst.visibleCells(vp, stats) {
+			add(cell)
+		}
+	}
+
+	s.transparent = append(s.transparent, s.trailObjects(cam.Pos())...)
+	if s.reflecting == nil {
+		// Particles are too small and short lived to show in reflections.
+		s.transparent = append(s.transparent, s.particleObjects(cam, c.Bounds())...)
+	}
+
+	// The scene is drawn more than once a frame for stereo and reflections,
+	// so the changes add up like the draw calls.
+	shaders, textures := stateChanges(s.opaque)
+	stats.UnsortedShaderChanges += shaders
+	stats.UnsortedTextureChanges += textures
+	s.sortByState(s.opaque)
+	shaders, textures = stateChanges(s.opaque)
+	stats.ShaderChanges += shaders
+	stats.TextureChanges += textures
+	sortBackToFront(s.transparent, cam.Pos())
+	s.transparent = append(s.transparent, s.debugObjects(cam.Pos())...)
+
+	inputsSet := make(map[*gfx.Shader]bool)
+	draw := func(list []*gfx.Object) {
+		for _, o := range list {
+			if s.reflecting != nil {
+				// The shaders of the objects hold the clip planes
+				// of the view, not the ones of the reflection.
+				o = s.reflecting.sceneProxy(s, o)
+			} else if o.Shader != nil && !inputsSet[o.Shader] {
+				s.setClipInputs(o.Shader)
+				inputsSet[o.Shader] = true
+			}
+			c.Draw(r, o, cam)
+			stats.countDraw(o)
+		}
+	}
+	draw(s.opaque)
+	if s.occluding {
+		s.drawOcclusionBoxes(c, r, cam, stats)
+	}
+
+	// Decals and reflections go over the opaque surfaces, and under
+	// transparent ones.
+	s.drawDecals(c, r, cam, stats)
+	s.drawReflections(c, r, cam, stats)
+	draw(s.transparent)
+}
+
+//func (s *Scene) Start() {
+//	go s.listen()
+//}
+
+//func (s *Scene) listen() {
+//	ticker := time.NewTicker(time.Second / 60.0)
+
+//	for {
+//		select {
+
+//		case <-ticker.C:
+//			s.Update()
+
+//		}
+//	}
+//}
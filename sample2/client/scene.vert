@@ -0,0 +1,65 @@
+#version 120
+
+attribute vec3 Vertex;
+attribute vec2 TexCoord0;
+attribute vec3 Normal;
+attribute vec3 Barycentric;
+
+uniform mat4 MVP;
+uniform mat4 Model;
+uniform mat4 View;
+uniform mat4 Projection;
+
+// When Wind is set, vertices sway WindFrequency times a second along the
+// world space WindDir, by up to WindStrength at the top of the local bounds
+// starting at height WindBase and WindHeight tall.
+uniform bool Wind;
+uniform vec3 WindDir;
+uniform float WindStrength;
+uniform float WindFrequency;
+uniform float WindBase;
+uniform float WindHeight;
+uniform bool BendNormals;
+uniform float Time;
+
+// ClipZBias moves the depth of every vertex toward the camera by that much in
+// normalized device coordinates, which is the same number of depth buffer
+// steps at any distance and resolution.
+uniform float ClipZBias;
+
+varying vec2 tc0;
+varying vec4 worldPos;
+varying vec3 worldNormal;
+varying vec3 bary;
+
+vec4 biased(vec4 p)
+{
+	p.z -= ClipZBias * p.w;
+	return p;
+}
+
+void main()
+{
+	tc0 = TexCoord0;
+	bary = Barycentric;
+	worldPos = Model * vec4(Vertex, 1.0);
+	worldNormal = (Model * vec4(Normal, 0.0)).xyz;
+	if(!Wind) {
+		gl_Position = biased(MVP * vec4(Vertex, 1.0));
+		return;
+	}
+
+	// Offset the phase by the position along the wind, so that it moves
+	// through the scene as a wave.
+	float h = clamp((Vertex.z - WindBase) / max(WindHeight, 0.0001), 0.0, 1.0);
+	float phase = 6.2831853 * WindFrequency * Time - dot(worldPos.xyz, WindDir);
+	float sway = WindStrength * sin(phase);
+	worldPos.xyz += WindDir * sway * h * h;
+	if(BendNormals) {
+		// The surface leans by the slope of the sway along the height.
+		float slope = sway * 2.0 * h / max(WindHeight, 0.0001);
+		vec3 up = normalize((Model * vec4(0.0, 0.0, 1.0, 0.0)).xyz);
+		worldNormal -= slope * dot(normalize(worldNormal), WindDir) * up;
+	}
+	gl_Position = biased(Projection * View * worldPos);
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// TextureResolver finds the textures a loaded scene refers to by name.
+type TextureResolver interface {
+	ResolveTexture(name string) (*gfx.Texture, error)
+}
+
+// TextureMap is a TextureResolver looking textures up in a map.
+type TextureMap map[string]*gfx.Texture
+
+func (m TextureMap) ResolveTexture(name string) (*gfx.Texture, error) {
+	t, ok := m[name]
+	if !ok {
+		return nil, fmt.Errorf("no texture named %q", name)
+	}
+	return t, nil
+}
+
+// The binary scene format starts with binaryMagic followed by the format
+// version, readers refuse versions they do not know.
+const (
+	binaryMagic   = "RTSC"
+	binaryVersion = 1
+)
+
+var ErrNotBinaryScene = errors.New("scene: not a binary scene file")
+
+// binWriter writes little endian values, remembering the first error.
+type binWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (b *binWriter) write(v interface{}) {
+	if b.err == nil {
+		b.err = binary.Write(b.w, binary.LittleEndian, v)
+	}
+}
+
+func (b *binWriter) string(s string) {
+	b.write(uint16(len(s)))
+	if b.err == nil {
+		_, b.err = io.WriteString(b.w, s)
+	}
+}
+
+// binReader reads little endian values, remembering the first error.
+type binReader struct {
+	r   io.Reader
+	err error
+}
+
+func (b *binReader) read(v interface{}) {
+	if b.err == nil {
+		b.err = binary.Read(b.r, binary.LittleEndian, v)
+	}
+}
+
+func (b *binReader) uint32() uint32 {
+	var v uint32
+	b.read(&v)
+	return v
+}
+
+// count reads an element count, refusing counts no sane scene has so that a
+// corrupt file fails instead of allocating huge slices.
+func (b *binReader) count() int {
+	n := b.uint32()
+	if b.err == nil && n > 1<<26 {
+		b.err = fmt.Errorf("scene: corrupt binary scene (count %d)", n)
+	}
+	if b.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+func (b *binReader) string() string {
+	var n uint16
+	b.read(&n)
+	buf := make([]byte, n)
+	if b.err == nil {
+		_, b.err = io.ReadFull(b.r, buf)
+	}
+	return string(buf)
+}
+
+func (b *binReader) vec3() lmath.Vec3 {
+	var v [3]float64
+	b.read(&v)
+	return lmath.Vec3{v[0], v[1], v[2]}
+}
+
+// SaveBinary writes the objects of the scene to w in a compact binary form.
+// Meshes are written once even when shared, and textures and shaders are
+// referred to by the names given to NameTexture and NameShader. Objects drawn
+// with a shader that has no name, such as the placeholder of a ShaderLibrary
+// while theirs loads, are left out.
+func (s *Scene) SaveBinary(w io.Writer) error {
+	var objects []*Object
+	for _, o := range s.objects {
+		if baseShader(o.Object) == nil || s.shaderName(o.Object) != "" {
+			objects = append(objects, o)
+		}
+	}
+
+	b := &binWriter{w: w}
+	b.write([]byte(binaryMagic))
+	b.write(uint16(binaryVersion))
+
+	// Collect the meshes and textures every object refers to.
+	var (
+		meshes    []*gfx.Mesh
+		meshIndex = make(map[*gfx.Mesh]uint32)
+		texNames  []string
+		texIndex  = make(map[string]uint32)
+	)
+	for _, o := range objects {
+		for _, m := range o.Meshes {
+			if _, ok := meshIndex[m]; !ok {
+				meshIndex[m] = uint32(len(meshes))
+				meshes = append(meshes, m)
+			}
+		}
+		for _, t := range s.savedTextures(o.Object) {
+			name, ok := s.textureNames[t]
+			if !ok {
+				return fmt.Errorf("scene: object %q uses a texture with no name", o.Name)
+			}
+			if _, ok := texIndex[name]; !ok {
+				texIndex[name] = uint32(len(texNames))
+				texNames = append(texNames, name)
+			}
+		}
+	}
+
+	b.write(uint32(len(texNames)))
+	for _, name := range texNames {
+		b.string(name)
+	}
+
+	b.write(uint32(len(meshes)))
+	for _, m := range meshes {
+		m.RLock()
+		b.write(uint32(len(m.Vertices)))
+		b.write(m.Vertices)
+		b.write(uint32(len(m.Normals)))
+		b.write(m.Normals)
+		b.write(uint32(len(m.TexCoords)))
+		for _, set := range m.TexCoords {
+			b.write(uint32(len(set.Slice)))
+			b.write(set.Slice)
+		}
+		b.write(uint32(len(m.Indices)))
+		b.write(m.Indices)
+		m.RUnlock()
+	}
+
+	b.write(uint32(len(objects)))
+	for _, o := range objects {
+		b.string(o.Name)
+		b.string(s.shaderName(o.Object))
+		pos, rot, scale := o.Pos(), o.Rot(), o.Scale()
+		b.write([9]float64{pos.X, pos.Y, pos.Z, rot.X, rot.Y, rot.Z, scale.X, scale.Y, scale.Z})
+		b.write([4]uint8{uint8(o.AlphaMode), uint8(o.FaceCulling), boolByte(o.DepthTest), boolByte(o.DepthWrite)})
+
+		textures := s.savedTextures(o.Object)
+		b.write(uint32(len(textures)))
+		for _, t := range textures {
+			b.write(texIndex[s.textureNames[t]])
+		}
+		b.write(uint32(len(o.Meshes)))
+		for _, m := range o.Meshes {
+			b.write(meshIndex[m])
+		}
+	}
+	return b.err
+}
+
+// LoadBinary replaces the objects of the scene with the ones read from r,
+// which must have been written by SaveBinary. Textures are found through the
+// resolver and shaders by the names given to NameShader. Objects named like
+// one of the scene are loaded into it, meshes included, so that the code
+// referring to them keeps working; the others are added, and the objects of
+// the scene missing from r are removed.
+func (s *Scene) LoadBinary(r io.Reader, resolver TextureResolver) error {
+	b := &binReader{r: r}
+	var magic [4]byte
+	var version uint16
+	b.read(&magic)
+	if b.err != nil || string(magic[:]) != binaryMagic {
+		return ErrNotBinaryScene
+	}
+	b.read(&version)
+	if b.err != nil {
+		return b.err
+	}
+	if version != binaryVersion {
+		return fmt.Errorf("scene: binary scene version %d is not supported (want %d)", version, binaryVersion)
+	}
+
+	textures := make([]*gfx.Texture, b.count())
+	for i := range textures {
+		name := b.string()
+		if b.err != nil {
+			return b.err
+		}
+		t, err := resolver.ResolveTexture(name)
+		if err != nil {
+			return fmt.Errorf("scene: %v", err)
+		}
+		textures[i] = t
+		s.textureNames[t] = name
+	}
+
+	meshes := make([]*gfx.Mesh, b.count())
+	for i := range meshes {
+		m := gfx.NewMesh()
+		m.Vertices = make([]gfx.Vec3, b.count())
+		b.read(m.Vertices)
+		m.Normals = make([]gfx.Vec3, b.count())
+		b.read(m.Normals)
+		m.TexCoords = make([]gfx.TexCoordSet, b.count())
+		for j := range m.TexCoords {
+			m.TexCoords[j].Slice = make([]gfx.TexCoord, b.count())
+			b.read(m.TexCoords[j].Slice)
+		}
+		m.Indices = make([]uint32, b.count())
+		b.read(m.Indices)
+		meshes[i] = m
+	}
+
+	objects := make([]*Object, b.count())
+	for i := range objects {
+		o := &Object{Object: gfx.NewObject(), Name: b.string()}
+		shaderName := b.string()
+		var tr [9]float64
+		var state [4]uint8
+		b.read(&tr)
+		b.read(&state)
+		if b.err != nil {
+			return b.err
+		}
+		if sh, ok := s.shaders[shaderName]; ok {
+			o.Shader = sh
+		} else if shaderName != "" {
+			return fmt.Errorf("scene: object %q uses unknown shader %q", o.Name, shaderName)
+		}
+		o.SetPos(lmath.Vec3{tr[0], tr[1], tr[2]})
+		o.SetRot(lmath.Vec3{tr[3], tr[4], tr[5]})
+		o.SetScale(lmath.Vec3{tr[6], tr[7], tr[8]})
+		o.State = gfx.NewState()
+		o.AlphaMode = gfx.AlphaMode(state[0])
+		o.FaceCulling = gfx.FaceCullMode(state[1])
+		o.DepthTest = state[2] != 0
+		o.DepthWrite = state[3] != 0
+
+		o.Textures = make([]*gfx.Texture, b.count())
+		for j := range o.Textures {
+			k := b.uint32()
+			if b.err == nil && int(k) >= len(textures) {
+				return fmt.Errorf("scene: object %q refers to texture %d of %d", o.Name, k, len(textures))
+			}
+			if b.err == nil {
+				o.Textures[j] = textures[k]
+			}
+		}
+		o.Meshes = make([]*gfx.Mesh, b.count())
+		for j := range o.Meshes {
+			k := b.uint32()
+			if b.err == nil && int(k) >= len(meshes) {
+				return fmt.Errorf("scene: object %q refers to mesh %d of %d", o.Name, k, len(meshes))
+			}
+			if b.err == nil {
+				o.Meshes[j] = meshes[k]
+			}
+		}
+		objects[i] = o
+	}
+	if b.err != nil {
+		return b.err
+	}
+
+	byName := make(map[string]*Object, len(s.objects))
+	for _, o := range s.objects {
+		if _, ok := byName[o.Name]; !ok && o.Name != "" {
+			byName[o.Name] = o
+		}
+	}
+	kept := make(map[*Object]bool, len(objects))
+	var added []*Object
+	for _, lo := range objects {
+		if o, ok := byName[lo.Name]; ok && !kept[o] {
+			loadInto(o, lo)
+			kept[o] = true
+			continue
+		}
+		added = append(added, lo)
+	}
+	for _, o := range append([]*Object(nil), s.objects...) {
+		if !kept[o] {
+			s.Remove(o)
+		}
+	}
+	for _, o := range added {
+		s.Add(o)
+	}
+	if s.index != nil {
+		s.BuildSpatialIndex()
+	}
+	return nil
+}
+
+// loadInto makes the scene object o look like the loaded object lo, keeping
+// o and those of its meshes with as many vertices as the loaded ones, such as
+// morphed meshes: their data is replaced by a copy of the loaded one instead.
+func loadInto(o, lo *Object) {
+	if baseShader(o.Object) != lo.Shader {
+		replaceBaseShader(o.Object, lo.Shader)
+	}
+	o.SetPos(lo.Pos())
+	o.SetRot(lo.Rot())
+	o.SetScale(lo.Scale())
+	o.AlphaMode = lo.AlphaMode
+	o.FaceCulling = lo.FaceCulling
+	o.DepthTest = lo.DepthTest
+	o.DepthWrite = lo.DepthWrite
+	o.Textures = lo.Textures
+	if len(o.Meshes) != len(lo.Meshes) {
+		o.Meshes = lo.Meshes
+	} else {
+		meshes := make([]*gfx.Mesh, len(lo.Meshes))
+		for i, lm := range lo.Meshes {
+			m := o.Meshes[i]
+			m.Lock()
+			if len(m.Vertices) != len(lm.Vertices) {
+				m.Unlock()
+				meshes[i] = lm
+				continue
+			}
+			m.Vertices = append(m.Vertices[:0], lm.Vertices...)
+			m.Normals = append([]gfx.Vec3(nil), lm.Normals...)
+			m.TexCoords = lm.TexCoords
+			if len(lm.Indices) > 0 {
+				// The barycentrics of wireframes need one vertex per
+				// triangle corner.
+				m.Attribs = nil
+			}
+			m.Indices = lm.Indices
+			m.Changed = true
+			m.Unlock()
+			meshes[i] = m
+		}
+		o.Meshes = meshes
+	}
+	if IsStatic(o.Object) {
+		staticGen++
+	}
+}
+
+func boolByte(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
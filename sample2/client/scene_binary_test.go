@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// scatteredScene returns a scene of n cubes scattered by a fixed seed, all
+// sharing one named mesh, texture and shader.
+func scatteredScene(n int) *Scene {
+	s := NewScene()
+	mesh := newCube(1).Meshes[0]
+	tex := gfx.NewTexture()
+	sh := gfx.NewShader("scene")
+	s.NameMesh("cube", mesh)
+	s.NameTexture("tex", tex)
+	s.NameShader("scene", sh)
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < n; i++ {
+		o := &Object{Object: gfx.NewObject(), Name: fmt.Sprintf("cube%d", i)}
+		o.State = gfx.NewState()
+		o.Shader = sh
+		o.Meshes = []*gfx.Mesh{mesh}
+		o.Textures = []*gfx.Texture{tex}
+		o.SetPos(lmath.Vec3{r.Float64()*200 - 100, r.Float64()*200 - 100, r.Float64() * 20})
+		o.SetRot(lmath.Vec3{0, 0, r.Float64() * 360})
+		o.SetScale(lmath.Vec3{1, 1, 0.5 + r.Float64()})
+		s.Add(o)
+	}
+	return s
+}
+
+// likeScene returns an empty scene knowing the names of the mesh, texture and
+// shader of s.
+func likeScene(s *Scene) *Scene {
+	like := NewScene()
+	for t, name := range s.textureNames {
+		like.NameTexture(name, t)
+	}
+	for name, sh := range s.shaders {
+		like.NameShader(name, sh)
+	}
+	for name, m := range s.meshes {
+		like.NameMesh(name, m)
+	}
+	return like
+}
+
+func textureMap(s *Scene) TextureMap {
+	m := make(TextureMap)
+	for t, name := range s.textureNames {
+		m[name] = t
+	}
+	return m
+}
+
+func saveBinary(t testing.TB, s *Scene) []byte {
+	var buf bytes.Buffer
+	if err := s.SaveBinary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	s := scatteredScene(50)
+	data := saveBinary(t, s)
+
+	loaded := likeScene(s)
+	if err := loaded.LoadBinary(bytes.NewReader(data), textureMap(s)); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.objects) != len(s.objects) {
+		t.Fatalf("loaded %d objects, want %d", len(loaded.objects), len(s.objects))
+	}
+	for i, o := range s.objects {
+		lo := loaded.objects[i]
+		if lo.Name != o.Name {
+			t.Fatalf("object %d is named %q, want %q", i, lo.Name, o.Name)
+		}
+		if lo.Pos() != o.Pos() || lo.Rot() != o.Rot() || lo.Scale() != o.Scale() {
+			t.Errorf("%s: transform %v %v %v, want %v %v %v", o.Name,
+				lo.Pos(), lo.Rot(), lo.Scale(), o.Pos(), o.Rot(), o.Scale())
+		}
+		if lo.Shader != o.Shader || lo.Textures[0] != o.Textures[0] {
+			t.Errorf("%s: shader or texture not found by name", o.Name)
+		}
+		if lo.Meshes[0] != loaded.objects[0].Meshes[0] {
+			t.Errorf("%s: shared mesh loaded more than once", o.Name)
+		}
+	}
+	m, lm := s.objects[0].Meshes[0], loaded.objects[0].Meshes[0]
+	if len(lm.Vertices) != len(m.Vertices) || lm.Vertices[0] != m.Vertices[0] {
+		t.Errorf("mesh vertices not kept")
+	}
+}
+
+func TestBinaryLoadIntoScene(t *testing.T) {
+	s := scatteredScene(10)
+	data := saveBinary(t, s)
+
+	// Loading over the scene keeps the objects named in it, and removes the
+	// ones the file does not have.
+	first := s.objects[0]
+	first.SetPos(lmath.Vec3{1000, 0, 0})
+	extra := &Object{Object: gfx.NewObject(), Name: "extra"}
+	s.Add(extra)
+	if err := s.LoadBinary(bytes.NewReader(data), textureMap(s)); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.objects) != 10 {
+		t.Fatalf("%d objects after loading, want 10", len(s.objects))
+	}
+	if s.object(first.Object) != first {
+		t.Errorf("object named in the scene was replaced")
+	}
+	if first.Pos().X == 1000 {
+		t.Errorf("object named in the scene was not loaded into")
+	}
+	if s.object(extra.Object) != nil {
+		t.Errorf("object missing from the file was kept")
+	}
+}
+
+func TestBinaryVersion(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString(binaryMagic)
+	buf.Write([]byte{binaryVersion + 1, 0})
+	err := NewScene().LoadBinary(&buf, TextureMap{})
+	want := fmt.Sprintf("version %d", binaryVersion+1)
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("got error %v, want one about %s", err, want)
+	}
+
+	err = NewScene().LoadBinary(strings.NewReader(`{"Objects":[]}`), TextureMap{})
+	if err != ErrNotBinaryScene {
+		t.Fatalf("got error %v, want ErrNotBinaryScene", err)
+	}
+}
+
+// benchmarkObjects is how many objects the load benchmarks load, about as
+// many as a large level has.
+const benchmarkObjects = 5000
+
+func BenchmarkLoadBinary(b *testing.B) {
+	s := scatteredScene(benchmarkObjects)
+	data := saveBinary(b, s)
+	textures := textureMap(s)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := likeScene(s).LoadBinary(bytes.NewReader(data), textures); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoadJSON(b *testing.B) {
+	s := scatteredScene(benchmarkObjects)
+	var js jsonScene
+	for _, o := range s.objects {
+		scale := o.Scale()
+		js.Objects = append(js.Objects, jsonObject{
+			Name:     o.Name,
+			Shader:   "scene",
+			Meshes:   []string{"cube"},
+			Textures: []string{"tex"},
+			Pos:      o.Pos(),
+			Rot:      o.Rot(),
+			Scale:    &scale,
+		})
+	}
+	data, err := json.Marshal(js)
+	if err != nil {
+		b.Fatal(err)
+	}
+	like := likeScene(s)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := LoadSceneJSON(bytes.NewReader(data), like); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
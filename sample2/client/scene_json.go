@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// jsonScene is the form of a JSON scene file.
+type jsonScene struct {
+	Objects []jsonObject
+}
+
+// jsonObject is an object of a JSON scene file. Its meshes, textures and
+// shader are referred to by the names given to NameMesh, NameTexture and
+// NameShader. Missing transforms are the identity.
+type jsonObject struct {
+	Name        string
+	Shader      string
+	Meshes      []string
+	Textures    []string
+	Pos, Rot    lmath.Vec3
+	Scale       *lmath.Vec3
+	Transparent bool
+}
+
+// NameMesh sets the name m is referred to by in JSON scene files.
+func (s *Scene) NameMesh(name string, m *gfx.Mesh) {
+	s.meshes[name] = m
+}
+
+// LoadSceneJSON reads a JSON scene from r into a new scene, finding the
+// meshes, textures and shaders it names among those named in the scene like.
+// Object names have to be unique, as scenes are diffed by them.
+func LoadSceneJSON(r io.Reader, like *Scene) (*Scene, error) {
+	var js jsonScene
+	if err := json.NewDecoder(r).Decode(&js); err != nil {
+		return nil, fmt.Errorf("scene: %v", err)
+	}
+	textures := make(map[string]*gfx.Texture, len(like.textureNames))
+	for t, name := range like.textureNames {
+		textures[name] = t
+	}
+
+	s := NewScene()
+	names := make(map[string]bool, len(js.Objects))
+	for _, jo := range js.Objects {
+		if jo.Name == "" {
+			return nil, fmt.Errorf("scene: an object has no name")
+		}
+		if names[jo.Name] {
+			return nil, fmt.Errorf("scene: two objects are named %q", jo.Name)
+		}
+		names[jo.Name] = true
+
+		o := &Object{Object: gfx.NewObject(), Name: jo.Name}
+		o.State = gfx.NewState()
+		if jo.Shader != "" {
+			sh, ok := like.shaders[jo.Shader]
+			if !ok {
+				return nil, fmt.Errorf("scene: object %q uses unknown shader %q", jo.Name, jo.Shader)
+			}
+			o.Shader = sh
+		}
+		for _, name := range jo.Meshes {
+			m, ok := like.meshes[name]
+			if !ok {
+				return nil, fmt.Errorf("scene: object %q uses unknown mesh %q", jo.Name, name)
+			}
+			o.Meshes = append(o.Meshes, m)
+		}
+		for _, name := range jo.Textures {
+			t, ok := textures[name]
+			if !ok {
+				return nil, fmt.Errorf("scene: object %q uses unknown texture %q", jo.Name, name)
+			}
+			o.Textures = append(o.Textures, t)
+		}
+		o.SetPos(jo.Pos)
+		o.SetRot(jo.Rot)
+		if jo.Scale != nil {
+			o.SetScale(*jo.Scale)
+		}
+		if jo.Transparent {
+			o.AlphaMode = gfx.AlphaBlend
+		}
+		s.Add(o)
+	}
+	return s, nil
+}
+
+// SceneDiff is the names of the objects ApplyDiff added, removed and changed.
+type SceneDiff struct {
+	Added, Removed, Changed []string
+}
+
+// Empty reports whether the diff changed nothing.
+func (d SceneDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// ApplyDiff makes the scene hold the objects of next, matching them by name.
+// Objects that did not change are left as they are, and changed ones are
+// updated in place, so that their meshes and textures stay loaded and what
+// refers to them keeps working. Objects not in next are removed. Only the
+// objects an earlier diff added are updated and removed, unless DiffAll is
+// set; the others, such as those added in code, are kept as they are.
+func (s *Scene) ApplyDiff(next *Scene) SceneDiff {
+	var diff SceneDiff
+	byName := make(map[string]*Object, len(s.objects))
+	for _, o := range s.objects {
+		byName[o.Name] = o
+	}
+	wanted := make(map[string]bool, len(next.objects))
+	for _, no := range next.objects {
+		wanted[no.Name] = true
+		o, ok := byName[no.Name]
+		if !ok {
+			s.Add(no)
+			s.diffed[no.Name] = true
+			diff.Added = append(diff.Added, no.Name)
+			continue
+		}
+		if !s.diffed[no.Name] && !s.DiffAll || sameObject(o.Object, no.Object) {
+			continue
+		}
+		// Keep the inputs set on a copy of the shader, such as the
+		// material, unless the shader itself changed.
+		if baseShader(o.Object) != no.Shader {
+			replaceBaseShader(o.Object, no.Shader)
+		}
+		o.Meshes = no.Meshes
+		o.Textures = no.Textures
+		o.AlphaMode = no.AlphaMode
+		o.SetPos(no.Pos())
+		o.SetRot(no.Rot())
+		o.SetScale(no.Scale())
+		if s.index != nil {
+			s.index.update(o)
+		}
+		diff.Changed = append(diff.Changed, no.Name)
+	}
+	for _, o := range append([]*Object(nil), s.objects...) {
+		if o.Name == "" || wanted[o.Name] || !s.diffed[o.Name] && !s.DiffAll {
+			continue
+		}
+		s.Remove(o)
+		diff.Removed = append(diff.Removed, o.Name)
+	}
+	for name := range s.diffed {
+		if !wanted[name] {
+			delete(s.diffed, name)
+		}
+	}
+	return diff
+}
+
+// sameObject reports whether a and b are drawn the same, as far as JSON scenes
+// describe them. The shaders are compared before any copy made to set inputs
+// on them.
+func sameObject(a, b *gfx.Object) bool {
+	if baseShader(a) != baseShader(b) || a.AlphaMode != b.AlphaMode ||
+		a.Pos() != b.Pos() || a.Rot() != b.Rot() || a.Scale() != b.Scale() ||
+		len(a.Meshes) != len(b.Meshes) || len(a.Textures) != len(b.Textures) {
+		return false
+	}
+	for i := range a.Meshes {
+		if a.Meshes[i] != b.Meshes[i] {
+			return false
+		}
+	}
+	for i := range a.Textures {
+		if a.Textures[i] != b.Textures[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sceneWatcher applies the changes made to a JSON scene file to the scene as
+// they are saved.
+type sceneWatcher struct {
+	path    string
+	modTime time.Time
+	next    time.Time
+}
+
+// sceneWatchInterval is how often the watched scene file is checked.
+const sceneWatchInterval = 500 * time.Millisecond
+
+// WatchSceneFile applies the JSON scene file at path to the scene now and
+// whenever it changes, see Scene.ApplyDiff. An empty path stops watching.
+func (g *Game) WatchSceneFile(path string) {
+	g.sceneWatch = nil
+	if path != "" {
+		g.sceneWatch = &sceneWatcher{path: path}
+		g.checkSceneFile()
+	}
+}
+
+// checkSceneFile applies the watched scene file if it changed since it was
+// last applied. A file that cannot be read or parsed is left for the next
+// change, keeping the scene as it is.
+func (g *Game) checkSceneFile() {
+	w := g.sceneWatch
+	if w == nil || time.Now().Before(w.next) {
+		return
+	}
+	w.next = time.Now().Add(sceneWatchInterval)
+	fi, err := os.Stat(w.path)
+	if err != nil || fi.ModTime().Equal(w.modTime) {
+		return
+	}
+	w.modTime = fi.ModTime()
+	f, err := os.Open(w.path)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer f.Close()
+	ns, err := LoadSceneJSON(f, g.scene)
+	if err != nil {
+		log.Printf("%s: %v\n", w.path, err)
+		return
+	}
+	diff := g.scene.ApplyDiff(ns)
+	if g.selected != nil && g.scene.object(g.selected) == nil {
+		g.Select(nil)
+	}
+	if !diff.Empty() {
+		log.Printf("%s: added %v, removed %v, changed %v\n", w.path, diff.Added, diff.Removed, diff.Changed)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"log"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/gfxutil"
+)
+
+// placeholderVert and placeholderFrag draw objects in flat gray while their
+// real shader loads.
+var (
+	placeholderVert = []byte(`#version 120
+
+attribute vec3 Vertex;
+
+uniform mat4 MVP;
+
+void main()
+{
+	gl_Position = MVP * vec4(Vertex, 1.0);
+}
+`)
+	placeholderFrag = []byte(`#version 120
+
+void main()
+{
+	gl_FragColor = vec4(0.6, 0.6, 0.6, 1.0);
+}
+`)
+)
+
+// loadedShader is the result of an asynchronous load.
+type loadedShader struct {
+	name   string
+	shader *gfx.Shader
+	err    error
+}
+
+// ShaderLibrary loads shaders in the background, drawing the objects using
+// them with a flat placeholder shader until they are ready. The sources are
+// read on background goroutines, and handed to the device by Update on the
+// render thread; the objects switch to the shaders once the device loaded
+// them, in a later Update.
+type ShaderLibrary struct {
+	// OnLoad, if not nil, is called from Update with every shader that
+	// finished loading.
+	OnLoad func(name string, sh *gfx.Shader)
+
+	d           gfx.Device
+	placeholder *gfx.Shader
+	shaders     map[string]*gfx.Shader
+	users       map[string][]*gfx.Object
+
+	// loaded receives the shaders read from disk, and compiled the ones the
+	// device loaded, whose names are in compiling.
+	loaded    chan loadedShader
+	compiled  chan *gfx.Shader
+	compiling map[*gfx.Shader]string
+}
+
+func NewShaderLibrary(d gfx.Device) *ShaderLibrary {
+	placeholder := gfx.NewShader("placeholder")
+	placeholder.GLSL = &gfx.GLSLSources{
+		Vertex:   placeholderVert,
+		Fragment: placeholderFrag,
+	}
+	return &ShaderLibrary{
+		d:           d,
+		placeholder: placeholder,
+		shaders:     make(map[string]*gfx.Shader),
+		users:       make(map[string][]*gfx.Object),
+		loaded:      make(chan loadedShader, 16),
+		compiled:    make(chan *gfx.Shader, 16),
+		compiling:   make(map[*gfx.Shader]string),
+	}
+}
+
+// LoadAsync starts loading the shader name from the sources at path, as with
+// gfxutil.OpenShader, and returns immediately.
+func (l *ShaderLibrary) LoadAsync(name, path string) {
+	go func() {
+		sh, err := gfxutil.OpenShader(path)
+		l.loaded <- loadedShader{name, sh, err}
+	}()
+}
+
+// Shader returns the shader name, or the placeholder if it is not loaded yet.
+func (l *ShaderLibrary) Shader(name string) *gfx.Shader {
+	if sh, ok := l.shaders[name]; ok {
+		return sh
+	}
+	return l.placeholder
+}
+
+// Waiting reports whether o is drawn with the placeholder until its shader
+// loads.
+func (l *ShaderLibrary) Waiting(o *gfx.Object) bool {
+	return baseShader(o) == l.placeholder
+}
+
+// Use draws o with the shader name, switching to it once it is loaded.
+func (l *ShaderLibrary) Use(o *gfx.Object, name string) {
+	o.Shader = l.Shader(name)
+	if o.Shader == l.placeholder {
+		l.users[name] = append(l.users[name], o)
+	}
+}
+
+// Update hands the shaders read since the last call to the device, and
+// switches the objects waiting for shaders the device finished loading to
+// them. It must be called from the render thread, and does not block.
+func (l *ShaderLibrary) Update() {
+	for {
+		select {
+		case ld := <-l.loaded:
+			if ld.err != nil {
+				log.Printf("Shader %q: %v\n", ld.name, ld.err)
+				continue
+			}
+			l.compiling[ld.shader] = ld.name
+			l.d.LoadShader(ld.shader, l.compiled)
+		case sh := <-l.compiled:
+			name := l.compiling[sh]
+			delete(l.compiling, sh)
+			l.finish(name, sh)
+		default:
+			return
+		}
+	}
+}
+
+// finish switches the objects waiting for the shader name to sh, now that it
+// is loaded.
+func (l *ShaderLibrary) finish(name string, sh *gfx.Shader) {
+	l.shaders[name] = sh
+	for _, o := range l.users[name] {
+		if baseShader(o) == l.placeholder {
+			replaceBaseShader(o, sh)
+		}
+	}
+	delete(l.users, name)
+	if l.OnLoad != nil {
+		l.OnLoad(name, sh)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log"
+)
+
+// ShadingRate is how many pixels share the result of a single fragment shader
+// invocation.
+type ShadingRate int
+
+const (
+	ShadingRate1x1 ShadingRate = iota
+	ShadingRate2x2
+	ShadingRate4x4
+)
+
+func (r ShadingRate) String() string {
+	switch r {
+	case ShadingRate2x2:
+		return "2x2"
+	case ShadingRate4x4:
+		return "4x4"
+	default:
+		return "1x1"
+	}
+}
+
+// shadingRateExtension is the extension variable rate shading needs.
+const shadingRateExtension = "GL_NV_shading_rate_image"
+
+// SetShadingRate sets the rate the background is shaded at, the objects of the
+// scene are always shaded at full rate.
+//
+// The gfx device does not expose a way to drive the shading rate extension, so
+// for now the rate is only recorded and every draw falls back to full rate,
+// with a notice saying why.
+func (g *Game) SetShadingRate(rate ShadingRate) {
+	g.shadingRate = rate
+	if rate == ShadingRate1x1 {
+		return
+	}
+	if !g.vrsSupported {
+		log.Printf("Shading rate %v: variable rate shading is not supported by this device, using 1x1.\n", rate)
+	} else {
+		log.Printf("Shading rate %v: the device supports %s but gfx cannot set it, using 1x1.\n", rate, shadingRateExtension)
+	}
+}
+
+// ShadingRate returns the shading rate the background is actually drawn at.
+func (g *Game) ShadingRate() ShadingRate {
+	return ShadingRate1x1
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"log"
+	"math"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/gfx/gfxutil"
+	"azul3d.org/engine/lmath"
+)
+
+// shadowMapSize is the width and height of the shadow map, in texels.
+const shadowMapSize = 1024
+
+// SetCastShadow sets whether o casts shadows from the directional light,
+// which it does by default.
+func SetCastShadow(o *gfx.Object, cast bool) {
+	propsOf(o).noCastShadow = !cast
+}
+
+// SetReceiveShadow sets whether shadows from the directional light fall onto
+// o, which they do by default.
+func SetReceiveShadow(o *gfx.Object, receive bool) {
+	propsOf(o).noReceiveShadow = !receive
+}
+
+func castsShadow(o *gfx.Object) bool {
+	p, ok := props[o]
+	return !ok || !p.noCastShadow
+}
+
+func receivesShadow(o *gfx.Object) bool {
+	p, ok := props[o]
+	return !ok || !p.noReceiveShadow
+}
+
+// shadowMap is the depth of the shadow casters as seen from the directional
+// light, packed into the color of a texture.
+type shadowMap struct {
+	canvas gfx.Canvas
+	color  *gfx.Texture
+	shader *gfx.Shader
+	cam    *camera.Camera
+
+	// matrix transforms world space into the clip space of the light, and
+	// ready is set once the map was rendered with it.
+	matrix lmath.Mat4
+	ready  bool
+
+	proxies map[*gfx.Object]*gfx.Object
+}
+
+// RenderShadows renders the shadow map of the directional light, if the scene
+// has one and Shadows is set. It is the first pass of a frame, before Draw.
+func (s *Scene) RenderShadows(d gfx.Device) {
+	if s.shadow != nil {
+		s.shadow.ready = false
+	}
+	if !s.Shadows || s.Sun == nil || len(s.objects) == 0 {
+		return
+	}
+	if s.shadow == nil {
+		sm, err := newShadowMap(d)
+		if err != nil {
+			log.Println("Shadows disabled:", err)
+			s.Shadows = false
+			return
+		}
+		s.shadow = sm
+	}
+	sm := s.shadow
+
+	// Fit the view of the light around the whole scene.
+	bounds := worldBounds(s.objects[0].Object)
+	for _, o := range s.objects[1:] {
+		b := worldBounds(o.Object)
+		bounds.Min = lmath.Vec3{math.Min(bounds.Min.X, b.Min.X), math.Min(bounds.Min.Y, b.Min.Y), math.Min(bounds.Min.Z, b.Min.Z)}
+		bounds.Max = lmath.Vec3{math.Max(bounds.Max.X, b.Max.X), math.Max(bounds.Max.Y, b.Max.Y), math.Max(bounds.Max.Z, b.Max.Z)}
+	}
+	center, radius := boundingSphere(bounds)
+	sm.matrix = lightMatrix(s.Sun.Dir, center, radius)
+
+	sm.shader.Lock()
+	sm.shader.Inputs["ShadowMatrix"] = gfx.ConvertMat4(sm.matrix)
+	sm.shader.Unlock()
+
+	c := sm.canvas
+	c.Clear(c.Bounds(), gfx.Color{1, 1, 1, 1})
+	c.ClearDepth(c.Bounds(), 1.0)
+	for _, o := range s.objects {
+		if castsShadow(o.Object) {
+			c.Draw(c.Bounds(), sm.proxy(o.Object), sm.cam)
+		}
+	}
+	c.Render()
+	sm.ready = true
+}
+
+func newShadowMap(d gfx.Device) (*shadowMap, error) {
+	sh, err := gfxutil.OpenShader("shadow")
+	if err != nil {
+		return nil, err
+	}
+	cfg := d.Info().RTTFormats.ChooseConfig(gfx.Precision{
+		RedBits: 8, GreenBits: 8, BlueBits: 8, AlphaBits: 8,
+		DepthBits: 24,
+	}, true)
+	color := gfx.NewTexture()
+	color.MinFilter = gfx.Nearest
+	color.MagFilter = gfx.Nearest
+	color.WrapU = gfx.Clamp
+	color.WrapV = gfx.Clamp
+	cfg.Color = color
+	cfg.Bounds = image.Rect(0, 0, shadowMapSize, shadowMapSize)
+	canvas := d.RenderToTexture(cfg)
+	if canvas == nil {
+		return nil, errors.New("render to texture is not supported")
+	}
+	return &shadowMap{
+		canvas: canvas,
+		color:  color,
+		shader: sh,
+		// The shadow shader ignores the camera, but drawing needs one.
+		cam:     camera.New(cfg.Bounds),
+		proxies: make(map[*gfx.Object]*gfx.Object),
+	}, nil
+}
+
+// proxy returns the object drawing o into the shadow map. Like the proxies of
+// PickByID it shares the transform and meshes of o, but all of them share the
+// shadow shader.
+func (sm *shadowMap) proxy(o *gfx.Object) *gfx.Object {
+	px, ok := sm.proxies[o]
+	if !ok {
+		px = gfx.NewObject()
+		px.State = gfx.NewState()
+		px.AlphaMode = gfx.NoAlpha
+		px.FaceCulling = gfx.NoFaceCulling
+		px.Dithering = false
+		px.Shader = sm.shader
+		sm.proxies[o] = px
+	}
+	px.Transform = o.Transform
+	px.Meshes = o.Meshes
+	return px
+}
+
+// lightMatrix returns the orthographic view projection of light travelling in
+// dir, looking at the sphere of the given center and radius.
+func lightMatrix(dir, center lmath.Vec3, radius float64) lmath.Mat4 {
+	f := dir.Normalized()
+	up := lmath.Vec3{0, 0, 1}
+	if a := f.Dot(up); a > 0.99 || a < -0.99 {
+		up = lmath.Vec3{1, 0, 0}
+	}
+	r := f.Cross(up).Normalized()
+	u := r.Cross(f)
+
+	// Rows transform points, as in p * m: each column projects onto one axis
+	// of the light and scales the sphere to the [-1, 1] cube.
+	var m lmath.Mat4
+	for i, axis := range []lmath.Vec3{r, u, f} {
+		m[0][i] = axis.X / radius
+		m[1][i] = axis.Y / radius
+		m[2][i] = axis.Z / radius
+		m[3][i] = -axis.Dot(center) / radius
+	}
+	m[3][3] = 1
+	return m
+}
+
+// receivesShadowMap reports whether o is drawn with the shadow map, which
+// needs it to have a single texture of its own.
+func (s *Scene) receivesShadowMap(o *gfx.Object) bool {
+	return s.shadow != nil && s.shadow.ready && receivesShadow(o) && len(s.savedTextures(o)) == 1
+}
+
+// setShadowInputs passes the shadow map to the shader of o, and adds it as the
+// second texture of receivers.
+func (s *Scene) setShadowInputs(o *gfx.Object, sh *gfx.Shader) {
+	var shadowTex *gfx.Texture
+	if s.shadow != nil {
+		shadowTex = s.shadow.color
+		if n := len(o.Textures); n > 1 && o.Textures[n-1] == shadowTex {
+			o.Textures = o.Textures[:n-1]
+		}
+	}
+	receive := s.receivesShadowMap(o)
+	sh.Inputs["ReceiveShadow"] = receive
+	if receive {
+		sh.Inputs["ShadowMatrix"] = gfx.ConvertMat4(s.shadow.matrix)
+		o.Textures = []*gfx.Texture{o.Textures[0], shadowTex}
+	}
+}
+
+// savedTextures returns the textures of o, without the shadow map.
+func (s *Scene) savedTextures(o *gfx.Object) []*gfx.Texture {
+	if s.shadow != nil {
+		if n := len(o.Textures); n > 1 && o.Textures[n-1] == s.shadow.color {
+			return o.Textures[:n-1]
+		}
+	}
+	return o.Textures
+}
@@ -0,0 +1,16 @@
+#version 120
+
+attribute vec3 Vertex;
+
+uniform mat4 Model;
+
+// ShadowMatrix transforms world space into the clip space of the light.
+uniform mat4 ShadowMatrix;
+
+varying float depth;
+
+void main()
+{
+	gl_Position = ShadowMatrix * Model * vec4(Vertex, 1.0);
+	depth = gl_Position.z * 0.5 + 0.5;
+}
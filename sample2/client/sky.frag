@@ -0,0 +1,35 @@
+#version 120
+
+varying vec2 tc0;
+
+// InvViewProj transforms clip space back into world space, for the view ray
+// through the fragment from CameraPos.
+uniform mat4 InvViewProj;
+uniform vec3 CameraPos;
+
+uniform vec4 Horizon;
+uniform vec4 Zenith;
+
+// SunDir points towards the sun, whose disc covers the directions within the
+// angle whose cosine is SunCos.
+uniform vec3 SunDir;
+uniform vec4 SunColor;
+uniform float SunCos;
+
+void main()
+{
+	vec4 far = InvViewProj * vec4(tc0.x * 2.0 - 1.0, 1.0 - tc0.y * 2.0, 1.0, 1.0);
+	vec3 dir = normalize(far.xyz / far.w - CameraPos);
+
+	// Below the horizon stays the horizon color.
+	float t = sqrt(max(dir.z, 0.0));
+	vec3 c = mix(Horizon.rgb, Zenith.rgb, t);
+
+	// A disc with a soft edge, and a faint glow around it.
+	float d = dot(dir, normalize(SunDir));
+	float edge = (1.0 - SunCos) * 0.2;
+	c = mix(c, SunColor.rgb, smoothstep(SunCos - edge, SunCos + edge, d));
+	c += SunColor.rgb * 0.25 * pow(max(d, 0.0), 64.0);
+
+	gl_FragColor = vec4(c, 1.0);
+}
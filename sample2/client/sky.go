@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/lmath"
+)
+
+// Sky fills the background with a gradient from a horizon color to a zenith
+// color by the elevation of the view ray, with a sun disc. It is a cheap
+// stand in for a skybox, drawn before the scene.
+type Sky struct {
+	Horizon, Zenith gfx.Color
+
+	// SunDir is the direction the sunlight travels in, the disc is drawn the
+	// other way. SunSize is the angular radius of the disc, in degrees, and
+	// zero hides it.
+	SunDir   lmath.Vec3
+	SunColor gfx.Color
+	SunSize  float64
+
+	quad *gfx.Object
+	cam  *camera.Camera
+}
+
+func NewSky(shader *gfx.Shader) *Sky {
+	quad := newQuad(shader)
+	quad.AlphaMode = gfx.NoAlpha
+	quad.DepthTest = false
+	quad.DepthWrite = false
+	return &Sky{
+		Horizon:  gfx.Color{1, 1, 1, 1},
+		Zenith:   gfx.Color{0.25, 0.45, 0.85, 1},
+		SunDir:   lmath.Vec3{0, 1, -1},
+		SunColor: gfx.Color{1, 0.95, 0.8, 1},
+		SunSize:  2,
+		quad:     quad,
+	}
+}
+
+// Draw draws the sky over the whole canvas, as seen by cam.
+func (s *Sky) Draw(c gfx.Canvas, cam *camera.Camera) {
+	b := c.Bounds()
+	if s.cam == nil {
+		s.cam = newOrthoCamera(b)
+	} else {
+		s.cam.Update(b)
+	}
+	inv, ok := viewProj(cam).Inverse()
+	if !ok {
+		return
+	}
+	sh := s.quad.Shader
+	sh.Lock()
+	sh.Inputs["InvViewProj"] = gfx.ConvertMat4(inv)
+	sh.Inputs["CameraPos"] = gfx.ConvertVec3(cam.Pos())
+	sh.Inputs["Horizon"] = s.Horizon
+	sh.Inputs["Zenith"] = s.Zenith
+	sh.Inputs["SunDir"] = gfx.ConvertVec3(s.SunDir.Normalized().MulScalar(-1))
+	sh.Inputs["SunColor"] = s.SunColor
+	sh.Inputs["SunCos"] = float32(math.Cos(lmath.Radians(s.SunSize)))
+	sh.Unlock()
+	s.quad.SetScale(lmath.Vec3{float64(b.Dx()), 1, float64(b.Dy())})
+	c.Draw(b, s.quad, s.cam)
+}
+
+// SetSkyGradient turns the sky on with the given horizon and zenith colors.
+func (g *Game) SetSkyGradient(horizon, zenith gfx.Color) {
+	g.sky.Horizon = horizon
+	g.sky.Zenith = zenith
+	g.skyOn = true
+}
+
+// SetSunDirection sets the direction the sunlight travels in, for both the
+// sun disc of the sky and the directional light of the scene.
+func (g *Game) SetSunDirection(dir lmath.Vec3) {
+	g.sky.SunDir = dir
+	if g.scene.Sun != nil {
+		g.scene.Sun.Dir = dir
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"image"
+	"math"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/lmath"
+	"azul3d.org/engine/mouse"
+)
+
+// Colors of the snap preview: the ghost outline of where the object lands,
+// the cell of the grid it lands in and the grid lines around it.
+var (
+	snapGhostColor = gfx.Color{1, 0.6, 0.1, 1}
+	snapCellColor  = gfx.Color{1, 0.6, 0.1, 0.25}
+	snapGridColor  = gfx.Color{1, 1, 1, 0.35}
+)
+
+// snapPreviewCells is how many grid cells around the landing one are outlined
+// each way.
+const snapPreviewCells = 2
+
+// TranslateDrag moves an object grabbed with the left mouse button over the
+// plane through the grab point which is perpendicular to the world axis
+// facing the camera the most.
+type TranslateDrag struct {
+	// OnDragStart, if not nil, is called when a drag starts, before the
+	// target is moved.
+	OnDragStart func(target *gfx.Object)
+
+	// Snap, when above zero, is the spacing of the world grid the object
+	// lands on. While dragged it follows the cursor freely, with a ghost of
+	// it shown where it will land, and is put there once released.
+	Snap float64
+
+	cam *camera.Camera
+
+	target   *gfx.Object
+	dragging bool
+
+	// normal is the axis of the drag plane, grab the point the plane was
+	// grabbed at and start the world position of the target then.
+	normal      lmath.Vec3
+	grab, start lmath.Vec3
+
+	// landing is where the target goes when released.
+	landing lmath.Vec3
+}
+
+func NewTranslateDrag(cam *camera.Camera) *TranslateDrag {
+	return &TranslateDrag{cam: cam}
+}
+
+// Dragging reports whether an object is being dragged.
+func (t *TranslateDrag) Dragging() bool {
+	return t.dragging
+}
+
+// forget stops dragging o, which was removed from the scene, leaving it where
+// it is.
+func (t *TranslateDrag) forget(o *gfx.Object) {
+	if t.target == o {
+		t.dragging = false
+		t.target = nil
+	}
+}
+
+// Update starts dragging target if allowed is set and it was just clicked, and
+// moves the dragged object for the mouse input of this frame. It reports
+// whether an object is being dragged.
+func (t *TranslateDrag) Update(bounds image.Rectangle, in *InputState, target *gfx.Object, allowed bool) bool {
+	if p, ok := in.Clicked(); ok && allowed && target != nil && !t.dragging {
+		origin, dir, ok := screenRay(t.cam, bounds, p)
+		if dist, hit := rayBox(origin, dir, worldBounds(target)); ok && hit {
+			if t.OnDragStart != nil {
+				t.OnDragStart(target)
+			}
+			t.target = target
+			t.dragging = true
+			t.normal = dominantAxis(cameraForward(t.cam))
+			t.grab = origin.Add(dir.MulScalar(dist))
+			t.start = worldPos(target)
+			t.landing = t.start
+		}
+	}
+	if !t.dragging {
+		return false
+	}
+	if !in.ButtonDown(mouse.Left) {
+		setWorldPos(t.target, t.landing)
+		t.dragging = false
+		t.target = nil
+		return false
+	}
+
+	// Move by how far the cursor is from the grab point on the plane, so
+	// that the object does not jump to the cursor.
+	origin, dir, ok := screenRay(t.cam, bounds, in.Cursor())
+	if !ok {
+		return true
+	}
+	hit, ok := rayPlane(origin, dir, t.normal, t.grab)
+	if !ok {
+		return true
+	}
+	pos := t.start.Add(hit.Sub(t.grab))
+	t.landing = pos
+	if t.Snap > 0 {
+		t.landing = t.snapped(pos)
+	}
+	setWorldPos(t.target, pos)
+	return true
+}
+
+// snapped returns p moved to the nearest grid point along the axes of the
+// drag plane, keeping it on the plane.
+func (t *TranslateDrag) snapped(p lmath.Vec3) lmath.Vec3 {
+	snap := func(v float64) float64 {
+		return math.Floor(v/t.Snap+0.5) * t.Snap
+	}
+	if t.normal.X == 0 {
+		p.X = snap(p.X)
+	}
+	if t.normal.Y == 0 {
+		p.Y = snap(p.Y)
+	}
+	if t.normal.Z == 0 {
+		p.Z = snap(p.Z)
+	}
+	return p
+}
+
+// DrawPreview draws the ghost of the dragged object where it lands and the
+// grid cell it lands in to dd, during a snapped drag.
+func (t *TranslateDrag) DrawPreview(dd *DebugDraw) {
+	if !t.dragging || t.Snap <= 0 {
+		return
+	}
+	m := t.target.Convert(gfx.LocalToWorld)
+	m[3][0], m[3][1], m[3][2] = t.landing.X, t.landing.Y, t.landing.Z
+	dd.Box(m, t.target.Bounds(), snapGhostColor)
+
+	// The cell centered on the landing point, with the lines of the cells
+	// around it.
+	u, v := planeAxes(t.normal)
+	h := t.Snap / 2
+	at := func(x, y float64) lmath.Vec3 {
+		return t.landing.Add(u.MulScalar(x)).Add(v.MulScalar(y))
+	}
+	dd.Quad(at(-h, -h), at(h, -h), at(h, h), at(-h, h), snapCellColor)
+	r := float64(snapPreviewCells)*t.Snap + h
+	for i := -snapPreviewCells; i <= snapPreviewCells+1; i++ {
+		x := float64(i)*t.Snap - h
+		dd.Line(at(x, -r), at(x, r), snapGridColor)
+		dd.Line(at(-r, x), at(r, x), snapGridColor)
+	}
+}
+
+// dominantAxis returns the world axis along which dir is the longest.
+func dominantAxis(dir lmath.Vec3) lmath.Vec3 {
+	x, y, z := math.Abs(dir.X), math.Abs(dir.Y), math.Abs(dir.Z)
+	switch {
+	case x >= y && x >= z:
+		return lmath.Vec3{1, 0, 0}
+	case y >= z:
+		return lmath.Vec3{0, 1, 0}
+	}
+	return lmath.Vec3{0, 0, 1}
+}
+
+// planeAxes returns the two world axes across the plane perpendicular to the
+// world axis n.
+func planeAxes(n lmath.Vec3) (u, v lmath.Vec3) {
+	switch {
+	case n.X != 0:
+		return lmath.Vec3{0, 1, 0}, lmath.Vec3{0, 0, 1}
+	case n.Y != 0:
+		return lmath.Vec3{1, 0, 0}, lmath.Vec3{0, 0, 1}
+	}
+	return lmath.Vec3{1, 0, 0}, lmath.Vec3{0, 1, 0}
+}
+
+// rayPlane returns where the ray crosses the plane with the normal n through
+// the point p.
+func rayPlane(origin, dir, n, p lmath.Vec3) (lmath.Vec3, bool) {
+	d := dir.Dot(n)
+	if math.Abs(d) < 1e-9 {
+		return lmath.Vec3{}, false
+	}
+	return origin.Add(dir.MulScalar(p.Sub(origin).Dot(n) / d)), true
+}
+
+// worldPos returns the world space position of the origin of o.
+func worldPos(o *gfx.Object) lmath.Vec3 {
+	return transformPoint(o.Convert(gfx.LocalToWorld), lmath.Vec3{})
+}
+
+// setWorldPos moves o so that its origin is at the world space position p,
+// relative to its parent if it has one.
+func setWorldPos(o *gfx.Object, p lmath.Vec3) {
+	if parent := Parent(o); parent != nil {
+		if inv, ok := parent.Convert(gfx.LocalToWorld).Inverse(); ok {
+			p = transformPoint(inv, p)
+		}
+	}
+	o.SetPos(p)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"sort"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// firstTexture returns the first texture of o, or nil.
+func firstTexture(o *gfx.Object) *gfx.Texture {
+	if len(o.Textures) == 0 {
+		return nil
+	}
+	return o.Textures[0]
+}
+
+// stateKey returns the key opaque objects are sorted by: small integers
+// identifying their shader and first texture, in the order the current sort
+// first saw them.
+func (s *Scene) stateKey(o *gfx.Object) (shader, texture int) {
+	id, ok := s.shaderIDs[o.Shader]
+	if !ok {
+		id = len(s.shaderIDs)
+		s.shaderIDs[o.Shader] = id
+	}
+	t := firstTexture(o)
+	tid, ok := s.textureIDs[t]
+	if !ok {
+		tid = len(s.textureIDs)
+		s.textureIDs[t] = tid
+	}
+	return id, tid
+}
+
+// sortByState sorts objects by shader then texture, keeping the order of
+// objects with equal keys. The IDs are handed out afresh every sort, so that
+// the shaders and textures of removed objects and of replaced copies are not
+// kept alive.
+func (s *Scene) sortByState(objects []*gfx.Object) {
+	for sh := range s.shaderIDs {
+		delete(s.shaderIDs, sh)
+	}
+	for t := range s.textureIDs {
+		delete(s.textureIDs, t)
+	}
+	for _, o := range objects {
+		s.stateKey(o)
+	}
+	sort.SliceStable(objects, func(i, j int) bool {
+		si, ti := s.stateKey(objects[i])
+		sj, tj := s.stateKey(objects[j])
+		if si != sj {
+			return si < sj
+		}
+		return ti < tj
+	})
+}
+
+// stateChanges counts how often the shader and the first texture change when
+// drawing objects in order.
+func stateChanges(objects []*gfx.Object) (shaders, textures int) {
+	for i, o := range objects {
+		if i == 0 || o.Shader != objects[i-1].Shader {
+			shaders++
+		}
+		if i == 0 || firstTexture(o) != firstTexture(objects[i-1]) {
+			textures++
+		}
+	}
+	return
+}
+
+// sortOverride replaces or offsets the distance an object is sorted by, see
+// SetSortDistance.
+type sortOverride struct {
+	dist     float64
+	absolute bool
+}
+
+// SetSortDistance overrides the distance from the camera o is sorted by among
+// transparent objects. If absolute it is sorted as if it were always dist
+// away, math.Inf(1) sorting it first, behind everything, and math.Inf(-1)
+// last. Otherwise dist is added to the distance of its center, negative values
+// bringing it forward.
+func SetSortDistance(o *gfx.Object, dist float64, absolute bool) {
+	propsOf(o).sort = &sortOverride{dist: dist, absolute: absolute}
+}
+
+// ClearSortDistance makes o sorted by the distance of its center again.
+func ClearSortDistance(o *gfx.Object) {
+	if p, ok := props[o]; ok {
+		p.sort = nil
+	}
+}
+
+// sortDistance returns the distance from eye o is sorted by.
+func sortDistance(o *gfx.Object, eye lmath.Vec3) float64 {
+	var override *sortOverride
+	if p, ok := props[o]; ok {
+		override = p.sort
+	}
+	if override != nil && override.absolute {
+		return override.dist
+	}
+	c, _ := boundingSphere(worldBounds(o))
+	d := c.Sub(eye).Length()
+	if override != nil {
+		d += override.dist
+	}
+	return d
+}
+
+// sortBackToFront sorts objects by decreasing distance of their center from
+// eye, or the distance set by SetSortDistance, so that blending composites
+// them correctly.
+func sortBackToFront(objects []*gfx.Object, eye lmath.Vec3) {
+	sort.SliceStable(objects, func(i, j int) bool {
+		return sortDistance(objects[i], eye) > sortDistance(objects[j], eye)
+	})
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"math"
+
+	"azul3d.org/engine/lmath"
+)
+
+// cellKey identifies a cell of a spatialIndex.
+type cellKey [3]int
+
+// cellRange is the box of cells from min to max, inclusive.
+type cellRange struct {
+	min, max cellKey
+}
+
+// count returns the number of cells in r, as a float as it easily overflows.
+func (r cellRange) count() float64 {
+	return float64(r.max[0]-r.min[0]+1) * float64(r.max[1]-r.min[1]+1) * float64(r.max[2]-r.min[2]+1)
+}
+
+// intersect returns the cells both r and o contain, and whether there are any.
+func (r cellRange) intersect(o cellRange) (cellRange, bool) {
+	for i := range r.min {
+		if o.min[i] > r.min[i] {
+			r.min[i] = o.min[i]
+		}
+		if o.max[i] < r.max[i] {
+			r.max[i] = o.max[i]
+		}
+		if r.min[i] > r.max[i] {
+			return r, false
+		}
+	}
+	return r, true
+}
+
+// split returns the two halves of r across its longest side, which must be
+// more than one cell long.
+func (r cellRange) split() (a, b cellRange) {
+	axis := 0
+	for i := 1; i < 3; i++ {
+		if r.max[i]-r.min[i] > r.max[axis]-r.min[axis] {
+			axis = i
+		}
+	}
+	mid := r.min[axis] + (r.max[axis]-r.min[axis])/2
+	a, b = r, r
+	a.max[axis] = mid
+	b.min[axis] = mid + 1
+	return a, b
+}
+
+// union returns the smallest range containing both r and o.
+func (r cellRange) union(o cellRange) cellRange {
+	for i := range r.min {
+		if o.min[i] < r.min[i] {
+			r.min[i] = o.min[i]
+		}
+		if o.max[i] > r.max[i] {
+			r.max[i] = o.max[i]
+		}
+	}
+	return r
+}
+
+// contains reports whether the cell k is in r.
+func (r cellRange) contains(k cellKey) bool {
+	for i := range k {
+		if k[i] < r.min[i] || k[i] > r.max[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// each calls f with every cell of r.
+func (r cellRange) each(f func(k cellKey)) {
+	for x := r.min[0]; x <= r.max[0]; x++ {
+		for y := r.min[1]; y <= r.max[1]; y++ {
+			for z := r.min[2]; z <= r.max[2]; z++ {
+				f(cellKey{x, y, z})
+			}
+		}
+	}
+}
+
+// spatialIndex is a uniform grid of cubic cells holding the objects whose
+// world bounds touch them, so that culling only has to test the objects of the
+// cells inside the view.
+type spatialIndex struct {
+	size   float64
+	cells  map[cellKey][]*Object
+	ranges map[*Object]cellRange
+
+	// extent holds every occupied cell, unless extentStale is set as one on
+	// its edge was emptied.
+	extent      cellRange
+	extentStale bool
+
+	// Objects found in several cells are only returned once per query.
+	stamp int
+	seen  map[*Object]int
+
+	candidates []*Object
+}
+
+// BuildSpatialIndex builds the uniform grid Draw culls the scene objects with,
+// instead of testing each of them against the view. The cell size is twice
+// the average object size. Update keeps the grid up to date as objects move.
+func (s *Scene) BuildSpatialIndex() {
+	var total float64
+	for _, o := range s.objects {
+		b := worldBounds(o.Object)
+		total += b.Max.Sub(b.Min).Length()
+	}
+	size := 1.0
+	if len(s.objects) > 0 {
+		size = math.Max(2*total/float64(len(s.objects)), 0.1)
+	}
+	s.index = &spatialIndex{
+		size:   size,
+		cells:  make(map[cellKey][]*Object),
+		ranges: make(map[*Object]cellRange),
+		seen:   make(map[*Object]int),
+	}
+	for _, o := range s.objects {
+		s.index.update(o)
+	}
+}
+
+// cellRange returns the cells the world space box b touches.
+func (x *spatialIndex) cellRange(b lmath.Rect3) cellRange {
+	cell := func(v lmath.Vec3) cellKey {
+		return cellKey{
+			int(math.Floor(v.X / x.size)),
+			int(math.Floor(v.Y / x.size)),
+			int(math.Floor(v.Z / x.size)),
+		}
+	}
+	return cellRange{cell(b.Min), cell(b.Max)}
+}
+
+// update moves o to the cells its current bounds touch, adding it if needed.
+func (x *spatialIndex) update(o *Object) {
+	r := x.cellRange(worldBounds(o.Object))
+	old, ok := x.ranges[o]
+	if ok && old == r {
+		return
+	}
+	if ok {
+		x.remove(o)
+	}
+	if len(x.cells) == 0 {
+		x.extent, x.extentStale = r, false
+	} else {
+		x.extent = x.extent.union(r)
+	}
+	r.each(func(k cellKey) {
+		x.cells[k] = append(x.cells[k], o)
+	})
+	x.ranges[o] = r
+}
+
+// remove removes o from the index.
+func (x *spatialIndex) remove(o *Object) {
+	r, ok := x.ranges[o]
+	if !ok {
+		return
+	}
+	r.each(func(k cellKey) {
+		list := x.cells[k]
+		for i, v := range list {
+			if v == o {
+				list[i] = list[len(list)-1]
+				list = list[:len(list)-1]
+				break
+			}
+		}
+		if len(list) == 0 {
+			delete(x.cells, k)
+			x.extentStale = x.extentStale || x.onEdge(k)
+		} else {
+			x.cells[k] = list
+		}
+	})
+	delete(x.ranges, o)
+	delete(x.seen, o)
+}
+
+// onEdge reports whether the cell k is on the edge of the extent.
+func (x *spatialIndex) onEdge(k cellKey) bool {
+	for i := range k {
+		if k[i] == x.extent.min[i] || k[i] == x.extent.max[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// occupied returns the cells holding every object, and whether there are
+// any.
+func (x *spatialIndex) occupied() (cellRange, bool) {
+	if len(x.cells) == 0 {
+		return cellRange{}, false
+	}
+	if x.extentStale {
+		first := true
+		for k := range x.cells {
+			if first {
+				x.extent = cellRange{k, k}
+				first = false
+			} else {
+				x.extent = x.extent.union(cellRange{k, k})
+			}
+		}
+		x.extentStale = false
+	}
+	return x.extent, true
+}
+
+// box returns the world space box the cells of r cover.
+func (x *spatialIndex) box(r cellRange) lmath.Rect3 {
+	corner := func(k cellKey) lmath.Vec3 {
+		return lmath.Vec3{float64(k[0]), float64(k[1]), float64(k[2])}.MulScalar(x.size)
+	}
+	return lmath.Rect3{Min: corner(r.min), Max: corner(r.max).Add(lmath.Vec3{x.size, x.size, x.size})}
+}
+
+// queryLeafCells is the number of cells below which query stops halving the
+// ranges it tests against the view, and looks them up one by one.
+const queryLeafCells = 64
+
+// query returns the objects of the cells which may be inside the clip volume
+// of the view projection matrix vp. It reuses the returned slice.
+func (x *spatialIndex) query(vp lmath.Mat4) []*Object {
+	x.stamp++
+	x.candidates = x.candidates[:0]
+	add := func(objects []*Object) {
+		for _, o := range objects {
+			if x.seen[o] != x.stamp {
+				x.seen[o] = x.stamp
+				x.candidates = append(x.candidates, o)
+			}
+		}
+	}
+
+	r, ok := x.occupied()
+	if !ok {
+		return x.candidates
+	}
+	if view, ok := frustumBounds(vp); ok {
+		if r, ok = r.intersect(x.cellRange(view)); !ok {
+			return x.candidates
+		}
+	}
+
+	// When the cells in view are mostly empty, testing the occupied ones is
+	// quicker.
+	if r.count() > float64(len(x.cells)) {
+		for k, objects := range x.cells {
+			if r.contains(k) && inFrustum(vp, x.box(cellRange{k, k})) {
+				add(objects)
+			}
+		}
+		return x.candidates
+	}
+
+	// Otherwise halve the range until its parts are either outside the view
+	// or small enough to look up.
+	var walk func(r cellRange)
+	walk = func(r cellRange) {
+		if !inFrustum(vp, x.box(r)) {
+			return
+		}
+		if r.count() > queryLeafCells {
+			a, b := r.split()
+			walk(a)
+			walk(b)
+			return
+		}
+		r.each(func(k cellKey) {
+			if objects, ok := x.cells[k]; ok && inFrustum(vp, x.box(cellRange{k, k})) {
+				add(objects)
+			}
+		})
+	}
+	walk(r)
+	return x.candidates
+}
+
+// frustumBounds returns the world space bounding box of the clip volume of
+// the view projection matrix vp.
+func frustumBounds(vp lmath.Mat4) (lmath.Rect3, bool) {
+	inv, ok := vp.Inverse()
+	if !ok {
+		return lmath.Rect3{}, false
+	}
+	unit := lmath.Rect3{
+		Min: lmath.Vec3{-1, -1, -1},
+		Max: lmath.Vec3{1, 1, 1},
+	}
+	return transformBox(inv, unit), true
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/lmath"
+)
+
+// visible reports whether a point of a 3x3x3 grid across the world space box
+// b is inside the clip volume of vp. Unlike inFrustum it never counts boxes
+// outside the view near its corners as inside.
+func visible(vp lmath.Mat4, b lmath.Rect3) bool {
+	size := b.Max.Sub(b.Min).MulScalar(0.5)
+	for i := 0; i < 27; i++ {
+		p := b.Min.Add(lmath.Vec3{
+			size.X * float64(i%3),
+			size.Y * float64(i/3%3),
+			size.Z * float64(i/9),
+		})
+		c := transformClip(vp, p)
+		if c.W > 0 && math.Abs(c.X) <= c.W && math.Abs(c.Y) <= c.W && math.Abs(c.Z) <= c.W {
+			return true
+		}
+	}
+	return false
+}
+
+// checkQuery checks that the spatial index of s returns every object that
+// culling all of them against the view of cam finds in it, once. The box test
+// of culling also passes some objects just outside the view, which the index
+// may or may not return as the cells around them are tested instead.
+func checkQuery(t *testing.T, s *Scene, cam *camera.Camera) {
+	vp := viewProj(cam)
+	found := make(map[*Object]bool)
+	for _, o := range s.index.query(vp) {
+		if found[o] {
+			t.Fatalf("%s: returned twice", o.Name)
+		}
+		found[o] = true
+	}
+	for _, o := range s.objects {
+		b := worldBounds(o.Object)
+		if inFrustum(vp, b) && visible(vp, b) && !found[o] {
+			t.Errorf("camera at %v: %s is in view but was not returned", cam.Pos(), o.Name)
+		}
+	}
+}
+
+func TestSpatialQueryMatchesBruteForce(t *testing.T) {
+	s := scatteredScene(2000)
+	s.BuildSpatialIndex()
+
+	bounds := image.Rect(0, 0, 640, 480)
+	cam := camera.New(bounds)
+	r := rand.New(rand.NewSource(2))
+	for _, far := range []float64{20, 200, 100000} {
+		cam.Far = far
+		cam.Update(bounds)
+		for i := 0; i < 20; i++ {
+			cam.SetPos(lmath.Vec3{r.Float64()*300 - 150, r.Float64()*300 - 150, r.Float64() * 40})
+			cam.SetRot(lmath.Vec3{r.Float64()*60 - 30, 0, r.Float64() * 360})
+			checkQuery(t, s, cam)
+		}
+	}
+
+	// Moving and removing objects, the edges of the grid included, keeps
+	// the index right.
+	for i, o := range append([]*Object(nil), s.objects...) {
+		switch i % 3 {
+		case 0:
+			o.SetPos(o.Pos().MulScalar(0.25))
+		case 1:
+			s.Remove(o)
+		}
+	}
+	s.Update(0)
+	cam.Far = 1000
+	cam.Update(bounds)
+	for i := 0; i < 20; i++ {
+		cam.SetPos(lmath.Vec3{r.Float64()*300 - 150, r.Float64()*300 - 150, r.Float64() * 40})
+		cam.SetRot(lmath.Vec3{r.Float64()*60 - 30, 0, r.Float64() * 360})
+		checkQuery(t, s, cam)
+	}
+}
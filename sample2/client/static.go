@@ -0,0 +1,207 @@
+package main
+
+import (
+	"log"
+	"reflect"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// staticGen changes whenever an object is frozen, thawed or moved while
+// frozen, telling scenes to rebuild their static batches.
+var staticGen int
+
+// staticState is the cached transform of a frozen object.
+type staticState struct {
+	pos, rot, scale lmath.Vec3
+	bounds          lmath.Rect3
+	valid, warned   bool
+}
+
+// SetStatic freezes or thaws o. The world bounds of a frozen object are
+// computed once and cached, and scenes combine the meshes of frozen opaque
+// objects sharing a shader, texture and state into one batch drawn with a
+// single draw call. Frozen objects are not meant to move: doing so logs a
+// warning and rebuilds the cache. Mesh changes are not noticed, thaw and
+// freeze the object again after them.
+func SetStatic(o *gfx.Object, static bool) {
+	p := propsOf(o)
+	if static {
+		p.static = &staticState{}
+	} else {
+		p.static = nil
+	}
+	staticGen++
+}
+
+// IsStatic reports whether o is frozen.
+func IsStatic(o *gfx.Object) bool {
+	p, ok := props[o]
+	return ok && p.static != nil
+}
+
+// staticBounds returns the cached world bounds of the frozen object o.
+func staticBounds(o *gfx.Object, st *staticState) lmath.Rect3 {
+	pos, rot, scale := o.Pos(), o.Rot(), o.Scale()
+	if st.valid && (pos != st.pos || rot != st.rot || scale != st.scale) {
+		if !st.warned {
+			log.Println("SetStatic: a frozen object was moved, thaw it first.")
+			st.warned = true
+		}
+		st.valid = false
+		staticGen++
+	}
+	if !st.valid {
+		st.pos, st.rot, st.scale = pos, rot, scale
+		st.bounds = transformBox(o.Convert(gfx.LocalToWorld), o.Bounds())
+		st.valid = true
+	}
+	return st.bounds
+}
+
+// staticBatch is the combined mesh of frozen objects drawn together.
+type staticBatch struct {
+	*gfx.Object
+	members []*gfx.Object
+}
+
+// batchable reports whether the frozen object o can be combined with others.
+// Transparent objects need sorting, and objects with inputs of their own in
+// their shader copy, such as a highlight, so both are drawn by themselves. A
+// copy whose inputs were all cleared again draws like the base shader.
+func batchable(o *gfx.Object) bool {
+	p, ok := props[o]
+	if !ok || p.static == nil || o.AlphaMode == gfx.AlphaBlend {
+		return false
+	}
+	return p.shader == nil || o.Shader != p.shader || !ownInputs(p.shader, p.baseShader)
+}
+
+// ownInputs reports whether the copy sh has inputs differing from those of its
+// base shader. Inputs the base lacks count as the same while they are zero.
+func ownInputs(sh, base *gfx.Shader) bool {
+	sh.RLock()
+	defer sh.RUnlock()
+	base.RLock()
+	defer base.RUnlock()
+	for name, v := range sh.Inputs {
+		bv, ok := base.Inputs[name]
+		if !ok {
+			if v != nil && !reflect.ValueOf(v).IsZero() {
+				return true
+			}
+			continue
+		}
+		if !reflect.DeepEqual(v, bv) {
+			return true
+		}
+	}
+	return false
+}
+
+// inputsChanged tells scenes to rebuild their static batches after an input
+// of the shader copy of o was set, if o is frozen, as that can take it out of
+// its batch or back into one.
+func inputsChanged(o *gfx.Object) {
+	if IsStatic(o) {
+		staticGen++
+	}
+}
+
+// buildBatches groups the batchable objects of the scene by shader, first
+// texture and state, and combines every group of two or more into a batch.
+func (s *Scene) buildBatches() {
+	type key struct {
+		shader  *gfx.Shader
+		texture *gfx.Texture
+		state   gfx.State
+	}
+	var (
+		groups = make(map[key][]*gfx.Object)
+		order  []key
+	)
+	for _, so := range s.objects {
+		o := so.Object
+		if !batchable(o) || len(o.Textures) > 1 {
+			continue
+		}
+		// Lit objects are drawn with copies of their shader shared by
+		// the objects reached by the same lights, which the batch gets
+		// its own of.
+		k := key{baseShader(o), firstTexture(o), *o.State}
+		if _, ok := groups[k]; !ok {
+			order = append(order, k)
+		}
+		groups[k] = append(groups[k], o)
+	}
+
+	s.batches = s.batches[:0]
+	s.batched = make(map[*gfx.Object]bool)
+	for _, k := range order {
+		members := groups[k]
+		if len(members) < 2 {
+			continue
+		}
+		b := gfx.NewObject()
+		b.State = gfx.NewState()
+		*b.State = k.state
+		b.Shader = k.shader
+		b.Textures = members[0].Textures
+		b.Meshes = []*gfx.Mesh{combineMeshes(members)}
+		propsOf(b).static = &staticState{}
+		s.batches = append(s.batches, &staticBatch{Object: b, members: members})
+		for _, o := range members {
+			s.batched[o] = true
+		}
+	}
+	s.batchGen = staticGen
+}
+
+// combineMeshes returns one mesh with the meshes of every object transformed
+// into world space. Only the first texture coordinate set is kept.
+func combineMeshes(objects []*gfx.Object) *gfx.Mesh {
+	out := gfx.NewMesh()
+	out.TexCoords = []gfx.TexCoordSet{{}}
+	for _, o := range objects {
+		m := o.Convert(gfx.LocalToWorld)
+		origin := transformPoint(m, lmath.Vec3{})
+		for _, mesh := range o.Meshes {
+			mesh.RLock()
+			base := uint32(len(out.Vertices))
+			for i, v := range mesh.Vertices {
+				out.Vertices = append(out.Vertices, gfx.ConvertVec3(transformPoint(m, v.Vec3())))
+				if len(mesh.Normals) == len(mesh.Vertices) {
+					n := transformPoint(m, mesh.Normals[i].Vec3()).Sub(origin).Normalized()
+					out.Normals = append(out.Normals, gfx.ConvertVec3(n))
+				}
+				if len(mesh.Colors) == len(mesh.Vertices) {
+					out.Colors = append(out.Colors, mesh.Colors[i])
+				}
+				var tc gfx.TexCoord
+				if len(mesh.TexCoords) > 0 && len(mesh.TexCoords[0].Slice) == len(mesh.Vertices) {
+					tc = mesh.TexCoords[0].Slice[i]
+				}
+				out.TexCoords[0].Slice = append(out.TexCoords[0].Slice, tc)
+			}
+			if len(mesh.Indices) == 0 {
+				for i := range mesh.Vertices {
+					out.Indices = append(out.Indices, base+uint32(i))
+				}
+			}
+			for _, i := range mesh.Indices {
+				out.Indices = append(out.Indices, base+i)
+			}
+			mesh.RUnlock()
+		}
+	}
+
+	// Attributes only some of the meshes had cannot be used.
+	if len(out.Normals) != len(out.Vertices) {
+		out.Normals = nil
+	}
+	if len(out.Colors) != len(out.Vertices) {
+		out.Colors = nil
+	}
+	return out
+}
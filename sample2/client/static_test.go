@@ -0,0 +1,85 @@
+package main
+
+import (
+	"image"
+	"testing"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/lmath"
+)
+
+// nullCanvas is a canvas that drops what is drawn to it, for measuring the
+// CPU side of drawing a scene. Only the methods the scene calls are there.
+type nullCanvas struct {
+	gfx.Canvas
+	bounds image.Rectangle
+}
+
+func (c *nullCanvas) Bounds() image.Rectangle { return c.bounds }
+
+func (c *nullCanvas) Draw(r image.Rectangle, o *gfx.Object, cam gfx.Camera) {}
+
+// benchmarkFrames updates and draws a large scene of cubes sharing a shader,
+// texture and state every iteration, frozen if static is set, and reports the
+// draw calls of a frame.
+func benchmarkFrames(b *testing.B, static bool) {
+	s := scatteredScene(benchmarkObjects)
+	for _, o := range s.objects {
+		SetStatic(o.Object, static)
+	}
+	bounds := image.Rect(0, 0, 1280, 720)
+	c := &nullCanvas{bounds: bounds}
+	cam := camera.New(bounds)
+	cam.SetPos(lmath.Vec3{0, -200, 50})
+
+	// The first frame builds the batches.
+	var stats RenderStats
+	s.Update(1.0 / 60)
+	s.Draw(c, cam, &stats)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stats = RenderStats{}
+		s.Update(1.0 / 60)
+		s.Draw(c, cam, &stats)
+	}
+	b.ReportMetric(float64(stats.DrawCalls), "draws/frame")
+}
+
+func BenchmarkFramesDynamic(b *testing.B) { benchmarkFrames(b, false) }
+
+func BenchmarkFramesStatic(b *testing.B) { benchmarkFrames(b, true) }
+
+func TestStaticTint(t *testing.T) {
+	s := scatteredScene(4)
+	for _, o := range s.objects {
+		SetStatic(o.Object, true)
+	}
+	rebuild := func() {
+		if s.batchGen != staticGen {
+			s.buildBatches()
+		}
+	}
+	rebuild()
+	tinted := s.objects[0].Object
+	if len(s.batches) != 1 || !s.batched[tinted] {
+		t.Fatalf("%d batches of the frozen cubes, want one holding them all", len(s.batches))
+	}
+
+	// A tinted cube leaves the batch to be drawn with its tint.
+	setSelectTint(tinted, selectTint)
+	if s.batchGen == staticGen {
+		t.Fatal("tinting a frozen cube did not ask for the batches to be rebuilt")
+	}
+	rebuild()
+	if s.batched[tinted] || len(s.batches) != 1 || len(s.batches[0].members) != 3 {
+		t.Fatalf("the tinted cube is batched, or the others are not")
+	}
+
+	// Once the tint is cleared it rejoins the batch.
+	setSelectTint(tinted, gfx.Color{})
+	rebuild()
+	if !s.batched[tinted] || len(s.batches[0].members) != 4 {
+		t.Fatalf("the cube cleared of its tint is not batched again")
+	}
+}
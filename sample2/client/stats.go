@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+
+	"azul3d.org/engine/gfx"
+)
+
+// RenderStats are the statistics of a single rendered frame.
+type RenderStats struct {
+	FrameTime float64 // Seconds.
+	DrawCalls int
+	Triangles int
+	Culled    int
+
+	// Shader and texture changes between consecutive opaque draws, as drawn
+	// and as they would have been without sorting.
+	ShaderChanges          int
+	TextureChanges         int
+	UnsortedShaderChanges  int
+	UnsortedTextureChanges int
+
+	// Objects is the number of scene objects, and Tested how many of them
+	// had their bounds tested against the view, which the spatial index
+	// makes fewer.
+	Objects int
+	Tested  int
+
+	// OcclusionCulled is the number of objects in view that were skipped as
+	// hidden behind others, see Scene.SetOcclusionCulling.
+	OcclusionCulled int
+
+	// GPU times of the shadow, scene and post processing passes, in seconds,
+	// as measured a few frames earlier. Zero without GPU timers.
+	GPUShadow, GPUScene, GPUPost float64
+}
+
+// countDraw accounts for drawing o once.
+func (s *RenderStats) countDraw(o *gfx.Object) {
+	s.DrawCalls++
+	for _, m := range o.Meshes {
+		m.RLock()
+		if len(m.Indices) > 0 {
+			s.Triangles += len(m.Indices) / 3
+		} else {
+			s.Triangles += len(m.Vertices) / 3
+		}
+		m.RUnlock()
+	}
+}
+
+// statsLog writes one CSV row of RenderStats per frame.
+type statsLog struct {
+	f     *os.File
+	w     *csv.Writer
+	frame int
+}
+
+var statsHeader = []string{"frame", "frame_time_ms", "draw_calls", "triangles", "culled", "shader_changes", "texture_changes", "unsorted_shader_changes", "unsorted_texture_changes", "objects", "tested", "occlusion_culled", "gpu_shadow_ms", "gpu_scene_ms", "gpu_post_ms", "avg_frame_time_ms", "low1_frame_time_ms", "low01_frame_time_ms", "peak_draw_calls"}
+
+func newStatsLog(path string) (*statsLog, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	// The CSV writer is buffered, so rows only reach the disk once enough of
+	// them are queued (or on close) and writing never stalls a frame.
+	l := &statsLog{f: f, w: csv.NewWriter(f)}
+	l.w.Write(statsHeader)
+	return l, nil
+}
+
+// write writes the row of a frame, followed by the summary of the frames of
+// the aggregation window ending with it.
+func (l *statsLog) write(s RenderStats, sum StatsSummary) {
+	l.w.Write([]string{
+		strconv.Itoa(l.frame),
+		strconv.FormatFloat(s.FrameTime*1000, 'f', 3, 64),
+		strconv.Itoa(s.DrawCalls),
+		strconv.Itoa(s.Triangles),
+		strconv.Itoa(s.Culled),
+		strconv.Itoa(s.ShaderChanges),
+		strconv.Itoa(s.TextureChanges),
+		strconv.Itoa(s.UnsortedShaderChanges),
+		strconv.Itoa(s.UnsortedTextureChanges),
+		strconv.Itoa(s.Objects),
+		strconv.Itoa(s.Tested),
+		strconv.Itoa(s.OcclusionCulled),
+		strconv.FormatFloat(s.GPUShadow*1000, 'f', 3, 64),
+		strconv.FormatFloat(s.GPUScene*1000, 'f', 3, 64),
+		strconv.FormatFloat(s.GPUPost*1000, 'f', 3, 64),
+		strconv.FormatFloat(sum.AvgFrameTime*1000, 'f', 3, 64),
+		strconv.FormatFloat(sum.Low1*1000, 'f', 3, 64),
+		strconv.FormatFloat(sum.Low01*1000, 'f', 3, 64),
+		strconv.Itoa(sum.PeakDrawCalls),
+	})
+	l.frame++
+}
+
+func (l *statsLog) close() error {
+	l.w.Flush()
+	if err := l.w.Error(); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
+
+// StartStatsLog starts writing the statistics of every frame to a CSV file at
+// path, stopping any log that is already running.
+func (g *Game) StartStatsLog(path string) error {
+	if err := g.StopStatsLog(); err != nil {
+		return err
+	}
+	l, err := newStatsLog(path)
+	if err != nil {
+		return err
+	}
+	g.statsLog = l
+	return nil
+}
+
+// StopStatsLog flushes and closes the running stats log, if any.
+func (g *Game) StopStatsLog() error {
+	if g.statsLog == nil {
+		return nil
+	}
+	err := g.statsLog.close()
+	g.statsLog = nil
+	return err
+}
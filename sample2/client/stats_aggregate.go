@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+)
+
+// defaultStatsWindow is how many seconds of frames a StatsAggregator
+// summarizes unless told otherwise.
+const defaultStatsWindow = 10
+
+// StatsAggregator keeps the statistics of the frames rendered over the last
+// few seconds and summarizes them, for the overlay and for benchmarks.
+type StatsAggregator struct {
+	window float64
+
+	// frames holds the statistics of the frames in the window, oldest
+	// first, and total the sum of their frame times. times holds the same
+	// frame times sorted, kept so as frames come and go rather than sorted
+	// again for every summary, and draws and tris the sums of the draw calls
+	// and triangles.
+	frames      []RenderStats
+	total       float64
+	times       []float64
+	draws, tris int
+
+	// summary caches Summary until the next frame is added.
+	summary *StatsSummary
+}
+
+// StatsSummary summarizes the frames of the window of a StatsAggregator.
+type StatsSummary struct {
+	Frames   int
+	Duration float64 // Seconds, the sum of the frame times.
+
+	// AvgFrameTime is the mean frame time and MaxFrameTime the slowest one.
+	// Low1 and Low01 are the 1% and 0.1% low frame times, the 99th and the
+	// 99.9th percentiles: only one frame in a hundred, or in a thousand, took
+	// longer. All are in seconds.
+	AvgFrameTime float64
+	MaxFrameTime float64
+	Low1, Low01  float64
+
+	// Mean and peak numbers of draw calls and triangles per frame.
+	AvgDrawCalls  float64
+	PeakDrawCalls int
+	AvgTriangles  float64
+	PeakTriangles int
+}
+
+// NewStatsAggregator returns an aggregator over the last window seconds.
+func NewStatsAggregator(window float64) *StatsAggregator {
+	a := &StatsAggregator{}
+	a.SetWindow(window)
+	return a
+}
+
+// SetWindow sets how many seconds of the most recent frames are summarized,
+// dropping the older ones at once.
+func (a *StatsAggregator) SetWindow(seconds float64) {
+	if seconds < 0 {
+		seconds = 0
+	}
+	a.window = seconds
+	a.trim()
+	a.summary = nil
+}
+
+// Window returns how many seconds of frames are summarized.
+func (a *StatsAggregator) Window() float64 {
+	return a.window
+}
+
+// Add adds the statistics of the frame that was just rendered, dropping the
+// frames which fell out of the window.
+func (a *StatsAggregator) Add(s RenderStats) {
+	a.frames = append(a.frames, s)
+	a.total += s.FrameTime
+	a.draws += s.DrawCalls
+	a.tris += s.Triangles
+	i := sort.SearchFloat64s(a.times, s.FrameTime)
+	a.times = append(a.times, 0)
+	copy(a.times[i+1:], a.times[i:])
+	a.times[i] = s.FrameTime
+	a.trim()
+	a.summary = nil
+}
+
+// Reset forgets every frame.
+func (a *StatsAggregator) Reset() {
+	a.frames = a.frames[:0]
+	a.total = 0
+	a.times = a.times[:0]
+	a.draws, a.tris = 0, 0
+	a.summary = nil
+}
+
+// trim drops the oldest frames until the others span no more than the
+// window, always keeping the latest one.
+func (a *StatsAggregator) trim() {
+	n := 0
+	for len(a.frames)-n > 1 && a.total > a.window {
+		f := a.frames[n]
+		a.total -= f.FrameTime
+		a.draws -= f.DrawCalls
+		a.tris -= f.Triangles
+		i := sort.SearchFloat64s(a.times, f.FrameTime)
+		a.times = a.times[:i+copy(a.times[i:], a.times[i+1:])]
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	// Move the frames left down rather than reslicing past the dropped
+	// ones, so that the backing array does not keep growing.
+	a.frames = a.frames[:copy(a.frames, a.frames[n:])]
+	if len(a.frames) == 1 {
+		// Do not let rounding build up while the frames come and go.
+		a.total = a.frames[0].FrameTime
+	}
+}
+
+// Summary summarizes the frames in the window. The zero summary is returned
+// while there are none. It is cached until the next frame is added, and only
+// has to find the peaks, so calling it every frame is cheap.
+func (a *StatsAggregator) Summary() StatsSummary {
+	if a.summary != nil {
+		return *a.summary
+	}
+	var sum StatsSummary
+	if len(a.frames) > 0 {
+		for _, f := range a.frames {
+			if f.DrawCalls > sum.PeakDrawCalls {
+				sum.PeakDrawCalls = f.DrawCalls
+			}
+			if f.Triangles > sum.PeakTriangles {
+				sum.PeakTriangles = f.Triangles
+			}
+		}
+		n := float64(len(a.frames))
+		sum.Frames = len(a.frames)
+		sum.Duration = a.total
+		sum.AvgFrameTime = a.total / n
+		sum.MaxFrameTime = a.times[len(a.times)-1]
+		sum.Low1 = percentile(a.times, 99)
+		sum.Low01 = percentile(a.times, 99.9)
+		sum.AvgDrawCalls = float64(a.draws) / n
+		sum.AvgTriangles = float64(a.tris) / n
+	}
+	a.summary = &sum
+	return sum
+}
+
+// percentile returns the p-th percentile of the sorted values, the smallest
+// one which at least p percent of them are no greater than. For the frame
+// times 1, 2, ..., 1000 the 99th is 990 and the 99.9th 999.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p * float64(len(sorted)) / 100))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// String formats the summary on one line, with times in milliseconds.
+func (s StatsSummary) String() string {
+	return fmt.Sprintf("%d frames in %.1fs: avg %.2f ms, 1%% low %.2f ms, 0.1%% low %.2f ms, max %.2f ms, draw calls avg %.0f peak %d, triangles avg %.0f peak %d",
+		s.Frames, s.Duration, s.AvgFrameTime*1000, s.Low1*1000, s.Low01*1000, s.MaxFrameTime*1000,
+		s.AvgDrawCalls, s.PeakDrawCalls, s.AvgTriangles, s.PeakTriangles)
+}
+
+// SetStatsWindow sets over how many of the last seconds the frame statistics
+// are aggregated, see StatsAggregator.
+func (g *Game) SetStatsWindow(seconds float64) {
+	g.statsAgg.SetWindow(seconds)
+}
+
+// StartBenchmark forgets the frame statistics so far and sets the window to
+// seconds, logging the summary of the frames once that many seconds passed.
+func (g *Game) StartBenchmark(seconds float64) {
+	g.statsAgg.SetWindow(seconds)
+	g.statsAgg.Reset()
+	g.benchLeft = seconds
+	log.Printf("benchmark: running for %.1fs\n", seconds)
+}
+
+// updateBenchmark counts down the running benchmark by dt, logging its
+// summary when it is over.
+func (g *Game) updateBenchmark(dt float64) {
+	if g.benchLeft <= 0 {
+		return
+	}
+	g.benchLeft -= dt
+	if g.benchLeft <= 0 {
+		log.Println("benchmark:", g.statsAgg.Summary())
+	}
+}
+
+// statsSummaryText is the part of the FPS counter showing the aggregates.
+func (g *Game) statsSummaryText() string {
+	s := g.statsAgg.Summary()
+	if s.Frames == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" | %.0fs: avg %.1f, 1%% low %.1f, 0.1%% low %.1f ms, peak %d draws",
+		s.Duration, s.AvgFrameTime*1000, s.Low1*1000, s.Low01*1000, s.PeakDrawCalls)
+}
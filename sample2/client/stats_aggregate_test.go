@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]float64, 1000)
+	for i := range sorted {
+		sorted[i] = float64(i + 1)
+	}
+	for _, c := range []struct{ p, want float64 }{
+		{99, 990},
+		{99.9, 999},
+		{100, 1000},
+		{50, 500},
+		{0, 1},
+	} {
+		if got := percentile(sorted, c.p); got != c.want {
+			t.Errorf("percentile %v is %v, want %v", c.p, got, c.want)
+		}
+	}
+	if got := percentile(nil, 99); got != 0 {
+		t.Errorf("percentile of no values is %v, want 0", got)
+	}
+}
+
+func TestStatsSummaryLows(t *testing.T) {
+	// The frame times 1, 2, ..., 1000 seconds, added out of order.
+	a := NewStatsAggregator(1e9)
+	r := rand.New(rand.NewSource(1))
+	for _, i := range r.Perm(1000) {
+		a.Add(RenderStats{FrameTime: float64(i + 1), DrawCalls: i + 1})
+	}
+	sum := a.Summary()
+	if sum.Frames != 1000 {
+		t.Fatalf("%d frames, want 1000", sum.Frames)
+	}
+	if sum.Low1 != 990 || sum.Low01 != 999 || sum.MaxFrameTime != 1000 {
+		t.Errorf("1%% low %v, 0.1%% low %v, max %v; want 990, 999, 1000",
+			sum.Low1, sum.Low01, sum.MaxFrameTime)
+	}
+	if sum.AvgFrameTime != 500.5 || sum.AvgDrawCalls != 500.5 || sum.PeakDrawCalls != 1000 {
+		t.Errorf("avg %v, avg draws %v, peak draws %d; want 500.5, 500.5, 1000",
+			sum.AvgFrameTime, sum.AvgDrawCalls, sum.PeakDrawCalls)
+	}
+}
+
+func TestStatsSummaryWindow(t *testing.T) {
+	// Frames of one second with fewer and fewer draw calls, ten of them in
+	// the window.
+	a := NewStatsAggregator(10)
+	for i := 0; i < 20; i++ {
+		a.Add(RenderStats{FrameTime: 1, DrawCalls: 100 - i})
+		a.Summary()
+	}
+	sum := a.Summary()
+	if sum.Frames != 10 || sum.PeakDrawCalls != 90 || sum.AvgDrawCalls != 85.5 {
+		t.Errorf("%d frames, peak draws %d, avg draws %v; want 10, 90, 85.5",
+			sum.Frames, sum.PeakDrawCalls, sum.AvgDrawCalls)
+	}
+
+	// Frames getting faster from one second by a fortieth each: the last 14
+	// of 20 add up to 9.625 seconds, so the slowest kept is the seventh.
+	a = NewStatsAggregator(10)
+	for i := 0; i < 20; i++ {
+		a.Add(RenderStats{FrameTime: 1 - float64(i)/40})
+	}
+	sum = a.Summary()
+	if want := 1 - 6.0/40; sum.MaxFrameTime != want {
+		t.Errorf("max frame time %v after the slow frames left, want %v", sum.MaxFrameTime, want)
+	}
+
+	a.Reset()
+	if sum := a.Summary(); sum != (StatsSummary{}) {
+		t.Errorf("summary after a reset is %+v, want the zero summary", sum)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"image"
+	"log"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/lmath"
+)
+
+// StereoMode is how the scene is drawn for two eyes.
+type StereoMode int
+
+const (
+	// StereoOff draws the scene once, from the main camera.
+	StereoOff StereoMode = iota
+
+	// StereoSideBySide draws the left eye to the left half of the screen and
+	// the right eye to the right half.
+	StereoSideBySide
+
+	// StereoAnaglyph draws both eyes over each other, the left one in red and
+	// the right one in cyan, for viewing with red/cyan glasses.
+	StereoAnaglyph
+)
+
+func (m StereoMode) String() string {
+	switch m {
+	case StereoOff:
+		return "off"
+	case StereoSideBySide:
+		return "side by side"
+	case StereoAnaglyph:
+		return "anaglyph"
+	}
+	return "unknown"
+}
+
+// Stereo draws the scene from two eye cameras which are the main camera moved
+// half of the eye separation to the left and to the right.
+type Stereo struct {
+	Mode StereoMode
+
+	// EyeSeparation is the distance between the two eyes, in world units.
+	EyeSeparation float64
+
+	left, right *camera.Camera
+
+	// Used by the anaglyph mode, which draws each eye to a texture and then
+	// combines them.
+	shader                  *gfx.Shader
+	leftColor, rightColor   *gfx.Texture
+	leftCanvas, rightCanvas gfx.Canvas
+	quad                    *gfx.Object
+	quadCam                 *camera.Camera
+}
+
+// NewStereo returns stereo rendering, initially off, combining anaglyph eyes
+// with the given shader.
+func NewStereo(shader *gfx.Shader) *Stereo {
+	return &Stereo{
+		EyeSeparation: 0.06,
+		shader:        shader,
+	}
+}
+
+// Draw draws the scene to the canvas as seen by the main camera, from both
+// eyes unless stereo is off. The canvas is expected to be cleared already.
+func (s *Stereo) Draw(d gfx.Device, c gfx.Canvas, scene *Scene, cam *camera.Camera, stats *RenderStats) {
+	b := c.Bounds()
+	switch s.Mode {
+	case StereoSideBySide:
+		scene.cam = cam
+		mid := b.Min.X + b.Dx()/2
+		l := image.Rect(b.Min.X, b.Min.Y, mid, b.Max.Y)
+		r := image.Rect(mid, b.Min.Y, b.Max.X, b.Max.Y)
+		scene.drawRect(c, l, s.eye(&s.left, cam, l, -1), stats)
+		scene.drawRect(c, r, s.eye(&s.right, cam, r, 1), stats)
+
+	case StereoAnaglyph:
+		if s.leftCanvas == nil || s.leftCanvas.Bounds() != b {
+			s.resize(d, b)
+		}
+		if s.Mode != StereoAnaglyph {
+			// Render to texture is not supported.
+			scene.Draw(c, cam, stats)
+			return
+		}
+		scene.cam = cam
+		eyes := []struct {
+			c   gfx.Canvas
+			cam *camera.Camera
+		}{
+			{s.leftCanvas, s.eye(&s.left, cam, b, -1)},
+			{s.rightCanvas, s.eye(&s.right, cam, b, 1)},
+		}
+		for _, e := range eyes {
+			e.c.Clear(b, gfx.Color{1, 1, 1, 1})
+			e.c.ClearDepth(b, 1.0)
+			scene.drawRect(e.c, b, e.cam, stats)
+			e.c.Render()
+		}
+		s.quad.SetScale(lmath.Vec3{float64(b.Dx()), 1, float64(b.Dy())})
+		c.Draw(b, s.quad, s.quadCam)
+
+	default:
+		scene.Draw(c, cam, stats)
+	}
+}
+
+// eye updates the eye camera *e to look like cam, moved sideways by half of
+// the eye separation in the direction of side, and returns it.
+func (s *Stereo) eye(e **camera.Camera, cam *camera.Camera, r image.Rectangle, side float64) *camera.Camera {
+	if *e == nil {
+		*e = camera.New(r)
+	}
+	eye := *e
+	eye.FOV = cam.FOV
+	eye.Near = cam.Near
+	eye.Far = cam.Far
+	eye.Ortho = cam.Ortho
+	eye.Update(r)
+
+	m := cam.Object.Convert(gfx.LocalToWorld)
+	eye.SetPos(transformPoint(m, lmath.Vec3{side * s.EyeSeparation / 2, 0, 0}))
+	eye.SetRot(cam.Rot())
+	return eye
+}
+
+// resize creates the eye textures of the anaglyph mode for the bounds b. It
+// turns stereo off if render to texture is not supported.
+func (s *Stereo) resize(d gfx.Device, b image.Rectangle) {
+	cfg := d.Info().RTTFormats.ChooseConfig(gfx.Precision{
+		RedBits: 8, GreenBits: 8, BlueBits: 8, AlphaBits: 8,
+		DepthBits: 24,
+	}, true)
+	cfg.Bounds = b
+
+	canvas := func() (gfx.Canvas, *gfx.Texture) {
+		t := gfx.NewTexture()
+		t.MinFilter = gfx.Linear
+		t.MagFilter = gfx.Linear
+		t.WrapU = gfx.Clamp
+		t.WrapV = gfx.Clamp
+		cfg.Color = t
+		return d.RenderToTexture(cfg), t
+	}
+	s.leftCanvas, s.leftColor = canvas()
+	s.rightCanvas, s.rightColor = canvas()
+	if s.leftCanvas == nil || s.rightCanvas == nil {
+		log.Println("Anaglyph stereo disabled: render to texture is not supported.")
+		s.Mode = StereoOff
+		s.leftCanvas, s.rightCanvas = nil, nil
+		return
+	}
+
+	if s.quad == nil {
+		s.quad = newQuad(s.shader)
+		s.quad.AlphaMode = gfx.NoAlpha
+		s.quad.DepthTest = false
+	}
+	s.quad.Textures = []*gfx.Texture{s.leftColor, s.rightColor}
+	if s.quadCam == nil {
+		s.quadCam = newOrthoCamera(b)
+	} else {
+		s.quadCam.Update(b)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"sort"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+)
+
+// streamedTexture is an image the TextureStreamer keeps resident from some
+// mip level down.
+type streamedTexture struct {
+	// levels is the mip chain of the image on the CPU, level 0 being the
+	// full image.
+	levels []*image.RGBA
+	users  []*gfx.Object
+
+	// tex holds the levels from level down, want is the level wanted for
+	// the current view and coverage the largest screen area of a user.
+	tex      *gfx.Texture
+	level    int
+	want     int
+	coverage float64
+}
+
+// TextureStreamer keeps only the mip levels of its textures that the current
+// view needs on the GPU, within a memory budget. The full images stay on the
+// CPU, and each texture is uploaded again from the finest level needed when
+// that changes: a texture that is further away or smaller on screen has its
+// finest levels dropped, and gets them back as it comes closer.
+type TextureStreamer struct {
+	// Budget is the most texture memory, in bytes, resident at once.
+	Budget int
+
+	// Prefetch is how many levels finer than what the view needs are kept,
+	// so that approaching a texture does not show it sharpening.
+	Prefetch int
+
+	textures map[image.Image]*streamedTexture
+	order    []*streamedTexture
+	margin   float64
+}
+
+func NewTextureStreamer(budgetMB int) *TextureStreamer {
+	return &TextureStreamer{
+		Budget:   budgetMB << 20,
+		Prefetch: 1,
+		textures: make(map[image.Image]*streamedTexture),
+		margin:   0.25,
+	}
+}
+
+// SetHysteresis sets how far past the coverage of a level, in levels, the view
+// has to go for a texture to change level, in either direction, so that a
+// texture covering about as many pixels as it has texels is not uploaded again
+// every frame. It is a quarter of a level unless set, and zero switches right
+// at it.
+func (s *TextureStreamer) SetHysteresis(margin float64) {
+	if margin < 0 {
+		margin = 0
+	}
+	s.margin = margin
+}
+
+// SetTextureMemoryBudget sets the budget of the texture streamer, in MB.
+func (g *Game) SetTextureMemoryBudget(mb int) {
+	g.streamer.Budget = mb << 20
+}
+
+// Stream textures o with img, streamed. Objects streaming the same image share
+// its texture.
+func (s *TextureStreamer) Stream(o *gfx.Object, img image.Image) {
+	t, ok := s.textures[img]
+	if !ok {
+		t = &streamedTexture{levels: mipChain(img), level: -1}
+		s.textures[img] = t
+		s.order = append(s.order, t)
+	}
+	t.users = append(t.users, o)
+	if t.tex != nil {
+		o.Textures = []*gfx.Texture{t.tex}
+	}
+}
+
+// Resident returns the texture memory, in bytes, the streamed textures take.
+func (s *TextureStreamer) Resident() int {
+	n := 0
+	for _, t := range s.order {
+		if t.level >= 0 {
+			n += levelBytes(t.levels, t.level)
+		}
+	}
+	return n
+}
+
+// Update chooses the levels to keep for the view of cam over the bounds b, and
+// uploads the textures whose level changed.
+func (s *TextureStreamer) Update(cam *camera.Camera, b image.Rectangle) {
+	for _, t := range s.order {
+		t.coverage = 0
+		for _, o := range t.users {
+			if r, ok := screenBounds(cam, b, worldBounds(o)); ok {
+				r = r.Intersect(b)
+				t.coverage = math.Max(t.coverage, float64(r.Dx()*r.Dy()))
+			}
+		}
+
+		// The finest level with no more texels than covered pixels is
+		// enough, less the prefetched levels. The level in use is kept
+		// until the view is the margin past it. Textures out of view keep
+		// their coarsest level.
+		full := t.levels[0].Bounds()
+		want := len(t.levels) - 1 - s.Prefetch
+		if t.coverage > 0 {
+			texels := float64(full.Dx() * full.Dy())
+			level := math.Log2(texels/t.coverage)/2 - float64(s.Prefetch)
+			want = int(math.Floor(level))
+			if cur := float64(t.level); t.level >= 0 && level >= cur-s.margin && level < cur+1+s.margin {
+				want = t.level
+			}
+		}
+		if want < 0 {
+			want = 0
+		}
+		if want > len(t.levels)-1 {
+			want = len(t.levels) - 1
+		}
+		t.want = want
+	}
+
+	// Drop levels from the textures covering the least of the screen until
+	// the budget is met.
+	byCoverage := append([]*streamedTexture(nil), s.order...)
+	sort.SliceStable(byCoverage, func(i, j int) bool {
+		return byCoverage[i].coverage < byCoverage[j].coverage
+	})
+	total := 0
+	for _, t := range s.order {
+		total += levelBytes(t.levels, t.want)
+	}
+	for dropped := true; total > s.Budget && dropped; {
+		dropped = false
+		for _, t := range byCoverage {
+			if total <= s.Budget {
+				break
+			}
+			if t.want < len(t.levels)-1 {
+				total -= levelBytes(t.levels, t.want) - levelBytes(t.levels, t.want+1)
+				t.want++
+				dropped = true
+			}
+		}
+	}
+
+	for _, t := range s.order {
+		if t.want != t.level {
+			t.upload(t.want)
+		}
+	}
+}
+
+// upload replaces the texture of t by one holding the mip levels from level
+// down. The old texture is freed once nothing refers to it.
+func (t *streamedTexture) upload(level int) {
+	img := t.levels[level]
+	tex := gfx.NewTexture()
+	tex.Source = img
+	tex.Bounds = img.Bounds()
+	tex.MinFilter = gfx.LinearMipmapLinear
+	tex.MagFilter = gfx.Linear
+	tex.WrapU = gfx.Clamp
+	tex.WrapV = gfx.Clamp
+	for _, o := range t.users {
+		o.Textures = []*gfx.Texture{tex}
+	}
+	t.tex = tex
+	t.level = level
+}
+
+// levelBytes returns the memory a texture holding the mip levels from level
+// down takes.
+func levelBytes(levels []*image.RGBA, level int) int {
+	n := 0
+	for _, img := range levels[level:] {
+		n += len(img.Pix)
+	}
+	return n
+}
+
+// mipChain returns img and its halves by halves down to a single pixel.
+func mipChain(img image.Image) []*image.RGBA {
+	rgba, ok := img.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(img.Bounds())
+		draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	}
+	chain := []*image.RGBA{rgba}
+	for {
+		b := rgba.Bounds()
+		if b.Dx() == 1 && b.Dy() == 1 {
+			return chain
+		}
+		rgba = halveImage(rgba)
+		chain = append(chain, rgba)
+	}
+}
+
+// halveImage returns img at half its size, averaging each two by two block.
+// Odd rows and columns are clamped to the edge.
+func halveImage(img *image.RGBA) *image.RGBA {
+	b := img.Bounds()
+	w, h := (b.Dx()+1)/2, (b.Dy()+1)/2
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum [4]int
+			for i := 0; i < 4; i++ {
+				sx := b.Min.X + int(math.Min(float64(2*x+i%2), float64(b.Dx()-1)))
+				sy := b.Min.Y + int(math.Min(float64(2*y+i/2), float64(b.Dy()-1)))
+				p := img.PixOffset(sx, sy)
+				for c := range sum {
+					sum[c] += int(img.Pix[p+c])
+				}
+			}
+			p := out.PixOffset(x, y)
+			for c := range sum {
+				out.Pix[p+c] = uint8(sum[c] / 4)
+			}
+		}
+	}
+	return out
+}
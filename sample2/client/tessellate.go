@@ -0,0 +1,96 @@
+package main
+
+import (
+	"azul3d.org/engine/gfx"
+)
+
+// TessellateMesh returns a copy of m where every triangle is split into four
+// through the midpoints of its edges, factor times over, so the copy has 4^factor
+// times as many triangles. Texture coordinates and colors are interpolated, and
+// normals are recomputed if m has them. The copy is not indexed.
+func TessellateMesh(m *gfx.Mesh, factor int) *gfx.Mesh {
+	m.RLock()
+	out := gfx.NewMesh()
+
+	// Expand indices first, so that every three vertices form a triangle.
+	index := func(i int) int {
+		if len(m.Indices) == 0 {
+			return i
+		}
+		return int(m.Indices[i])
+	}
+	n := len(m.Indices)
+	if n == 0 {
+		n = len(m.Vertices)
+	}
+	hasColors := len(m.Colors) == len(m.Vertices)
+	out.TexCoords = make([]gfx.TexCoordSet, len(m.TexCoords))
+	for i := 0; i < n; i++ {
+		k := index(i)
+		out.Vertices = append(out.Vertices, m.Vertices[k])
+		if hasColors {
+			out.Colors = append(out.Colors, m.Colors[k])
+		}
+		for s, set := range m.TexCoords {
+			out.TexCoords[s].Slice = append(out.TexCoords[s].Slice, set.Slice[k])
+		}
+	}
+	hasNormals := len(m.Normals) > 0
+	m.RUnlock()
+
+	for level := 0; level < factor; level++ {
+		pairs := midpoints(len(out.Vertices))
+		out.Vertices = subdivideVec3(out.Vertices, pairs)
+		if hasColors {
+			out.Colors = subdivideColor(out.Colors, pairs)
+		}
+		for s := range out.TexCoords {
+			out.TexCoords[s].Slice = subdivideTexCoord(out.TexCoords[s].Slice, pairs)
+		}
+	}
+	if hasNormals {
+		out.Normals = computeNormals(out.Vertices, nil, nil)
+	}
+	return out
+}
+
+// midpoints returns the pairs of vertices, of a list of n vertices where
+// every three form a triangle, whose midpoints make up the list with each
+// triangle (a, b, c) split into its four midpoint triangles. The corners are
+// kept as the midpoint of a vertex and itself.
+func midpoints(n int) [][2]int {
+	out := make([][2]int, 0, n*4)
+	for i := 0; i+2 < n; i += 3 {
+		a, b, c := [2]int{i, i}, [2]int{i + 1, i + 1}, [2]int{i + 2, i + 2}
+		ab, bc, ca := [2]int{i, i + 1}, [2]int{i + 1, i + 2}, [2]int{i + 2, i}
+		out = append(out, a, ab, ca, ab, b, bc, ca, bc, c, ab, bc, ca)
+	}
+	return out
+}
+
+func subdivideVec3(v []gfx.Vec3, pairs [][2]int) []gfx.Vec3 {
+	out := make([]gfx.Vec3, len(pairs))
+	for i, p := range pairs {
+		x, y := v[p[0]], v[p[1]]
+		out[i] = gfx.Vec3{(x.X + y.X) / 2, (x.Y + y.Y) / 2, (x.Z + y.Z) / 2}
+	}
+	return out
+}
+
+func subdivideColor(v []gfx.Color, pairs [][2]int) []gfx.Color {
+	out := make([]gfx.Color, len(pairs))
+	for i, p := range pairs {
+		x, y := v[p[0]], v[p[1]]
+		out[i] = gfx.Color{(x.R + y.R) / 2, (x.G + y.G) / 2, (x.B + y.B) / 2, (x.A + y.A) / 2}
+	}
+	return out
+}
+
+func subdivideTexCoord(v []gfx.TexCoord, pairs [][2]int) []gfx.TexCoord {
+	out := make([]gfx.TexCoord, len(pairs))
+	for i, p := range pairs {
+		x, y := v[p[0]], v[p[1]]
+		out[i] = gfx.TexCoord{(x.U + y.U) / 2, (x.V + y.V) / 2}
+	}
+	return out
+}
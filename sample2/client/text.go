@@ -0,0 +1,145 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"azul3d.org/engine/gfx"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// TextRenderer rasterizes strings into images that can be used as textures.
+type TextRenderer struct {
+	// Face is the font face glyphs are drawn with.
+	Face font.Face
+
+	// Color is the fill color of the glyphs.
+	Color gfx.Color
+
+	// OutlineColor is the color of the outline and of the drop shadow, both
+	// of which are drawn behind the fill.
+	OutlineColor gfx.Color
+
+	// OutlineWidth is the width of the outline in unscaled pixels, zero
+	// disables it.
+	OutlineWidth int
+
+	// ShadowOffset is the offset of the drop shadow in unscaled pixels, the
+	// zero point disables it.
+	ShadowOffset image.Point
+
+	// Scale is the DPI scale the text is rendered at, the glyphs, outline and
+	// shadow offset are all multiplied by it.
+	Scale int
+}
+
+func NewTextRenderer() *TextRenderer {
+	return &TextRenderer{
+		Face:  basicfont.Face7x13,
+		Color: gfx.Color{0, 0, 0, 1},
+		Scale: 1,
+	}
+}
+
+// padding returns the space, in scaled pixels, that is needed around the
+// glyphs so that the outline and shadow are not clipped at the image edges.
+func (r *TextRenderer) padding() int {
+	p := r.OutlineWidth
+	if r.ShadowOffset != (image.Point{}) {
+		s := r.ShadowOffset
+		if s.X < 0 {
+			s.X = -s.X
+		}
+		if s.Y < 0 {
+			s.Y = -s.Y
+		}
+		if s.X > s.Y {
+			p += s.X
+		} else {
+			p += s.Y
+		}
+	}
+	return p * r.Scale
+}
+
+// Render rasterizes s into a new image.
+func (r *TextRenderer) Render(s string) *image.RGBA {
+	scale := r.Scale
+	if scale < 1 {
+		scale = 1
+	}
+
+	// Draw the glyph coverage at the native size of the face.
+	m := r.Face.Metrics()
+	w := font.MeasureString(r.Face, s).Ceil()
+	h := (m.Ascent + m.Descent).Ceil()
+	glyphs := image.NewAlpha(image.Rect(0, 0, w, h))
+	fd := &font.Drawer{
+		Dst:  glyphs,
+		Src:  image.Opaque,
+		Face: r.Face,
+		Dot:  fixed.Point26_6{Y: m.Ascent},
+	}
+	fd.DrawString(s)
+
+	// Scale it up and surround it with enough padding for the outline and
+	// shadow.
+	pad := r.padding()
+	mask := image.NewAlpha(image.Rect(0, 0, w*scale+2*pad, h*scale+2*pad))
+	for y := 0; y < h*scale; y++ {
+		for x := 0; x < w*scale; x++ {
+			mask.SetAlpha(x+pad, y+pad, glyphs.AlphaAt(x/scale, y/scale))
+		}
+	}
+
+	img := image.NewRGBA(mask.Bounds())
+	outline := mask
+	if r.OutlineWidth > 0 {
+		outline = dilate(mask, r.OutlineWidth*scale)
+	}
+	if r.ShadowOffset != (image.Point{}) {
+		offset := r.ShadowOffset.Mul(scale)
+		draw.DrawMask(img, img.Bounds().Add(offset), image.NewUniform(nrgba(r.OutlineColor)), image.ZP, outline, image.ZP, draw.Over)
+	}
+	if r.OutlineWidth > 0 {
+		draw.DrawMask(img, img.Bounds(), image.NewUniform(nrgba(r.OutlineColor)), image.ZP, outline, image.ZP, draw.Over)
+	}
+	draw.DrawMask(img, img.Bounds(), image.NewUniform(nrgba(r.Color)), image.ZP, mask, image.ZP, draw.Over)
+	return img
+}
+
+// dilate grows the coverage of m by a disc of the given radius.
+func dilate(m *image.Alpha, radius int) *image.Alpha {
+	b := m.Bounds()
+	out := image.NewAlpha(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			var a uint8
+			for dy := -radius; dy <= radius; dy++ {
+				for dx := -radius; dx <= radius; dx++ {
+					if dx*dx+dy*dy > radius*radius {
+						continue
+					}
+					if v := m.AlphaAt(x+dx, y+dy).A; v > a {
+						a = v
+					}
+				}
+			}
+			out.SetAlpha(x, y, color.Alpha{a})
+		}
+	}
+	return out
+}
+
+// nrgba converts a gfx.Color into a color.NRGBA.
+func nrgba(c gfx.Color) color.NRGBA {
+	return color.NRGBA{
+		R: uint8(c.R * 255),
+		G: uint8(c.G * 255),
+		B: uint8(c.B * 255),
+		A: uint8(c.A * 255),
+	}
+}
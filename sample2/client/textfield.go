@@ -0,0 +1,284 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"time"
+	"unicode"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/window"
+	"azul3d.org/engine/keyboard"
+	"azul3d.org/engine/lmath"
+	"golang.org/x/image/font"
+)
+
+const (
+	// textFieldPadding is the space between the edges of a text field and
+	// its text, in unscaled pixels.
+	textFieldPadding = 3
+
+	// caretBlink is how long the caret is shown, then hidden, for.
+	caretBlink = 500 * time.Millisecond
+)
+
+// TextField is a one line text input drawn by the HUD. Clicking it gives it
+// the keyboard, which it keeps until clicked outside of, Escape is pressed or
+// the game focuses another field.
+type TextField struct {
+	// Renderer draws the text and the caret.
+	Renderer *TextRenderer
+
+	// ScaleMode is how the field is sized, ScaleInherit uses the mode of
+	// the HUD.
+	ScaleMode ScaleMode
+
+	// OnSubmit, if set, is called with the value when Enter is pressed.
+	OnSubmit func(value string)
+
+	anchor Anchor
+	pos    image.Point
+	width  int
+
+	// text is edited at the cursor, a rune index. It is shown from the rune
+	// start on, so that the cursor stays inside the field.
+	text   []rune
+	cursor int
+	start  int
+
+	label, caret *Label
+	back         *gfx.Object
+	backTex      [2]*gfx.Texture
+
+	g         *Game
+	focused   bool
+	blinkFrom time.Time
+}
+
+// AddTextField adds a text field width pixels wide, positioned from the given
+// corner of the screen: pos is the offset of its nearest corner from it.
+func (h *HUD) AddTextField(anchor Anchor, pos image.Point, width int) *TextField {
+	r := NewTextRenderer()
+	r.Color = gfx.Color{1, 1, 1, 1}
+	f := &TextField{
+		Renderer: r,
+		anchor:   anchor,
+		pos:      pos,
+		width:    width,
+		label:    &Label{Object: newQuad(h.shader), Renderer: r},
+		caret:    &Label{Object: newQuad(h.shader), Renderer: r},
+		back:     newQuad(h.shader),
+	}
+	for i, c := range []color.RGBA{{0, 0, 0, 100}, {0, 0, 0, 180}} {
+		img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+		img.SetRGBA(0, 0, c)
+		tex := gfx.NewTexture()
+		tex.Source = img
+		tex.Bounds = img.Bounds()
+		tex.MinFilter = gfx.Nearest
+		tex.MagFilter = gfx.Nearest
+		f.backTex[i] = tex
+	}
+	h.fields = append(h.fields, f)
+	return f
+}
+
+// Value returns the text in the field.
+func (f *TextField) Value() string {
+	return string(f.text)
+}
+
+// SetValue replaces the text in the field, moving the cursor to its end.
+func (f *TextField) SetValue(s string) {
+	f.text = []rune(s)
+	f.cursor = len(f.text)
+	f.start = 0
+}
+
+// Redraw re-renders the text of the field, for instance after the renderer
+// was changed.
+func (f *TextField) Redraw() {
+	f.label.Redraw()
+	f.caret.Redraw()
+}
+
+// Focused reports whether the field has the keyboard.
+func (f *TextField) Focused() bool {
+	return f.focused
+}
+
+// FocusTextField gives the keyboard to the text field f, putting it on top of
+// the input context stack, or takes it back from the focused field if f is
+// nil.
+func (g *Game) FocusTextField(f *TextField) {
+	if g.focusedField == f {
+		return
+	}
+	if old := g.focusedField; old != nil {
+		old.focused = false
+		g.RemoveInputContext(old)
+	}
+	g.focusedField = f
+	if f != nil {
+		f.g = g
+		f.focused = true
+		f.blinkFrom = time.Now()
+		g.PushInputContext(f)
+	}
+}
+
+// TextFieldAt returns the text field at the pixel p, or nil.
+func (h *HUD) TextFieldAt(p image.Point) *TextField {
+	for _, f := range h.fields {
+		if p.In(f.rect(h.bounds, h.scale(f.ScaleMode))) {
+			return f
+		}
+	}
+	return nil
+}
+
+// HandleEvent implements the InputContext interface. It consumes every
+// keyboard event, other events reach the contexts below so that a click
+// elsewhere can take the focus away.
+func (f *TextField) HandleEvent(e window.Event) bool {
+	switch ev := e.(type) {
+	case keyboard.Typed:
+		for _, r := range ev.S {
+			if unicode.IsPrint(r) {
+				f.text = append(f.text, 0)
+				copy(f.text[f.cursor+1:], f.text[f.cursor:])
+				f.text[f.cursor] = r
+				f.cursor++
+			}
+		}
+
+	case keyboard.ButtonEvent:
+		if ev.State != keyboard.Down {
+			return true
+		}
+		switch ev.Key {
+		case keyboard.Enter:
+			if f.OnSubmit != nil {
+				f.OnSubmit(f.Value())
+			}
+		case keyboard.Escape:
+			f.g.FocusTextField(nil)
+		case keyboard.Backspace:
+			if f.cursor > 0 {
+				f.text = append(f.text[:f.cursor-1], f.text[f.cursor:]...)
+				f.cursor--
+			}
+		case keyboard.Delete:
+			if f.cursor < len(f.text) {
+				f.text = append(f.text[:f.cursor], f.text[f.cursor+1:]...)
+			}
+		case keyboard.ArrowLeft:
+			if f.cursor > 0 {
+				f.cursor--
+			}
+		case keyboard.ArrowRight:
+			if f.cursor < len(f.text) {
+				f.cursor++
+			}
+		case keyboard.Home:
+			f.cursor = 0
+		case keyboard.End:
+			f.cursor = len(f.text)
+		}
+
+	default:
+		return false
+	}
+
+	// Show the caret right away after each key, rather than whenever the
+	// blinking gets to it.
+	f.blinkFrom = time.Now()
+	return true
+}
+
+// CapturesKeyboard implements the InputContext interface.
+func (f *TextField) CapturesKeyboard() bool {
+	return true
+}
+
+// textWidth returns the width of s as drawn by the renderer of the field, in
+// unscaled pixels.
+func (f *TextField) textWidth(s []rune) int {
+	return font.MeasureString(f.Renderer.Face, string(s)).Ceil() * f.Renderer.Scale
+}
+
+// size returns the size of the field, in unscaled pixels.
+func (f *TextField) size() image.Point {
+	m := f.Renderer.Face.Metrics()
+	h := (m.Ascent+m.Descent).Ceil()*f.Renderer.Scale + 2*textFieldPadding
+	return image.Pt(f.width, h)
+}
+
+// origin returns the top-left corner of the field in unscaled pixels, which
+// are scaled by scale to screen pixels.
+func (f *TextField) origin(bounds image.Rectangle, scale float64) image.Point {
+	size := f.size()
+	p := f.pos
+	if f.anchor == AnchorTopRight || f.anchor == AnchorBottomRight {
+		p.X = int(float64(bounds.Dx())/scale) - p.X - size.X
+	}
+	if f.anchor == AnchorBottomLeft || f.anchor == AnchorBottomRight {
+		p.Y = int(float64(bounds.Dy())/scale) - p.Y - size.Y
+	}
+	return p
+}
+
+// rect returns the rectangle the field covers on screen, in pixels.
+func (f *TextField) rect(bounds image.Rectangle, scale float64) image.Rectangle {
+	o := f.origin(bounds, scale)
+	r := image.Rectangle{o, o.Add(f.size())}
+	return image.Rect(
+		int(float64(r.Min.X)*scale), int(float64(r.Min.Y)*scale),
+		int(float64(r.Max.X)*scale), int(float64(r.Max.Y)*scale),
+	)
+}
+
+// draw draws the field with the HUD camera.
+func (f *TextField) draw(c gfx.Canvas, h *HUD) {
+	scale := h.scale(f.ScaleMode)
+	bounds := h.bounds
+	r := f.rect(bounds, scale)
+	back := f.backTex[0]
+	if f.focused {
+		back = f.backTex[1]
+	}
+	f.back.Textures = []*gfx.Texture{back}
+	f.back.SetPos(lmath.Vec3{float64(r.Min.X), 0, float64(bounds.Dy() - r.Max.Y)})
+	f.back.SetScale(lmath.Vec3{float64(r.Dx()), 1, float64(r.Dy())})
+	c.Draw(c.Bounds(), f.back, h.cam)
+
+	// Scroll the text so that the cursor stays inside, then cut what does
+	// not fit.
+	inner := f.width - 2*textFieldPadding
+	if f.cursor < f.start {
+		f.start = f.cursor
+	}
+	for f.start < f.cursor && f.textWidth(f.text[f.start:f.cursor]) > inner {
+		f.start++
+	}
+	end := len(f.text)
+	for end > f.cursor && f.textWidth(f.text[f.start:end]) > inner {
+		end--
+	}
+
+	o := f.origin(bounds, scale).Add(image.Pt(textFieldPadding, textFieldPadding))
+	f.label.pos = o
+	f.label.SetText(string(f.text[f.start:end]))
+	if f.label.text != "" {
+		f.label.place(bounds, scale)
+		c.Draw(c.Bounds(), f.label.Object, h.cam)
+	}
+
+	if f.focused && time.Since(f.blinkFrom)/caretBlink%2 == 0 {
+		f.caret.SetText("|")
+		x := f.textWidth(f.text[f.start:f.cursor]) - f.textWidth([]rune("|"))/2
+		f.caret.pos = o.Add(image.Pt(x, 0))
+		f.caret.place(bounds, scale)
+		c.Draw(c.Bounds(), f.caret.Object, h.cam)
+	}
+}
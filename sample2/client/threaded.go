@@ -0,0 +1,146 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"azul3d.org/engine/lmath"
+)
+
+// TransformState is the transform of one scene object as seen by the update
+// goroutine of a ThreadedLoop.
+type TransformState struct {
+	Pos, Rot, Scale lmath.Vec3
+}
+
+// ThreadedLoop runs game state updates on their own goroutine, separate from
+// rendering. The update function works on its own copy of the transforms of
+// the scene objects, and publishes a snapshot of them after every update; the
+// render thread applies the latest snapshot to the objects once per frame, so
+// it never sees a half written state and is at most one frame behind.
+//
+// Only the transforms Update changes are applied, so the render thread can
+// keep moving the objects the loop leaves alone; an object whose transform is
+// changed by both ends up with whichever was set last. Only the objects in
+// the scene when the loop was started are updated, even once they have been
+// removed from it unless the loop is told to forget them. Update must not call
+// into the device, as GPU work has to stay on the render thread.
+type ThreadedLoop struct {
+	// Update advances the transforms, indexed like the objects of the scene
+	// when the loop was started, by dt seconds.
+	Update func(state []TransformState, dt float64)
+
+	scene *Scene
+	rate  time.Duration
+
+	// objects are the scene objects when the loop was started, which the
+	// transforms are applied to. They are only used by the render thread.
+	objects []*Object
+
+	// state and last, the state after the previous update, are owned by the
+	// update goroutine. Under mu it copies the transforms that changed into
+	// front and marks them dirty, until Apply sets them.
+	state, last, front []TransformState
+	dirty              []bool
+	fresh              bool
+	mu                 sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewThreadedLoop returns a loop calling update on the transforms of the
+// objects of s rate times per second, once started.
+func NewThreadedLoop(s *Scene, rate int, update func(state []TransformState, dt float64)) *ThreadedLoop {
+	return &ThreadedLoop{
+		Update: update,
+		scene:  s,
+		rate:   time.Second / time.Duration(rate),
+	}
+}
+
+// Start reads the current transforms of the scene objects and starts the
+// update goroutine.
+func (l *ThreadedLoop) Start() {
+	l.objects = append([]*Object(nil), l.scene.objects...)
+	n := len(l.objects)
+	l.state = make([]TransformState, n)
+	for i, o := range l.objects {
+		l.state[i] = TransformState{o.Pos(), o.Rot(), o.Scale()}
+	}
+	l.last = append([]TransformState(nil), l.state...)
+	l.front = make([]TransformState, n)
+	l.dirty = make([]bool, n)
+	l.fresh = false
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	go l.run()
+}
+
+// Stop stops the update goroutine and waits for it to return. Snapshots it
+// published but that were not applied yet are dropped.
+func (l *ThreadedLoop) Stop() {
+	close(l.stop)
+	<-l.done
+}
+
+func (l *ThreadedLoop) run() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.rate)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case now := <-ticker.C:
+			l.Update(l.state, now.Sub(last).Seconds())
+			last = now
+
+			l.mu.Lock()
+			for i, t := range l.state {
+				if t != l.last[i] {
+					l.front[i] = t
+					l.dirty[i] = true
+					l.fresh = true
+				}
+			}
+			l.mu.Unlock()
+			copy(l.last, l.state)
+		}
+	}
+}
+
+// forget stops applying the transforms of the loop to o, which was removed
+// from the scene. The update goroutine keeps its state, so the indices stay
+// the same.
+func (l *ThreadedLoop) forget(o *Object) {
+	for i, lo := range l.objects {
+		if lo == o {
+			l.objects[i] = nil
+		}
+	}
+}
+
+// Apply sets the transforms the update goroutine changed since the last call
+// on the scene objects, from the latest snapshot. It must be called from the
+// render thread, before the scene is drawn.
+func (l *ThreadedLoop) Apply() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.fresh {
+		return
+	}
+	for i, t := range l.front {
+		o := l.objects[i]
+		if !l.dirty[i] || o == nil {
+			continue
+		}
+		o.SetPos(t.Pos)
+		o.SetRot(t.Rot)
+		o.SetScale(t.Scale)
+		l.dirty[i] = false
+	}
+	l.fresh = false
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// TestThreadedLoopSceneShrinks checks that snapshots are applied to the
+// objects the loop was started with after the scene lost some of them. Run it
+// with -race to check the snapshots are handed over safely.
+func TestThreadedLoopSceneShrinks(t *testing.T) {
+	s := NewScene()
+	for i := 0; i < 3; i++ {
+		s.Add(&Object{Object: gfx.NewObject()})
+	}
+	objects := append([]*Object(nil), s.objects...)
+	l := NewThreadedLoop(s, 1000, func(state []TransformState, dt float64) {
+		for i := range state {
+			state[i].Pos.Z++
+		}
+	})
+	l.Start()
+	defer l.Stop()
+
+	s.Remove(objects[0])
+	s.Remove(objects[2])
+	deadline := time.Now().Add(time.Second)
+	for objects[2].Pos().Z == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no snapshot was applied")
+		}
+		time.Sleep(time.Millisecond)
+		l.Apply()
+	}
+	for i, o := range objects {
+		if o.Pos() == (lmath.Vec3{}) {
+			t.Errorf("object %d was not updated", i)
+		}
+	}
+}
+
+func TestThreadedLoopForget(t *testing.T) {
+	s := NewScene()
+	for i := 0; i < 2; i++ {
+		s.Add(&Object{Object: gfx.NewObject()})
+	}
+	objects := append([]*Object(nil), s.objects...)
+	l := NewThreadedLoop(s, 1000, func(state []TransformState, dt float64) {
+		for i := range state {
+			state[i].Pos.Z++
+		}
+	})
+	s.OnRemove = l.forget
+	l.Start()
+	defer l.Stop()
+
+	s.Remove(objects[0])
+	deadline := time.Now().Add(time.Second)
+	for objects[1].Pos().Z == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no snapshot was applied")
+		}
+		time.Sleep(time.Millisecond)
+		l.Apply()
+	}
+	if objects[0].Pos() != (lmath.Vec3{}) {
+		t.Errorf("forgotten object was updated")
+	}
+}
+
+func TestThreadedLoopAppliesChangesOnly(t *testing.T) {
+	s := NewScene()
+	for i := 0; i < 2; i++ {
+		s.Add(&Object{Object: gfx.NewObject()})
+	}
+	objects := append([]*Object(nil), s.objects...)
+	l := NewThreadedLoop(s, 1000, func(state []TransformState, dt float64) {
+		state[0].Pos.Z++
+	})
+	l.Start()
+	defer l.Stop()
+
+	// The render thread moves the object the loop leaves alone.
+	moved := lmath.Vec3{1, 2, 3}
+	objects[1].SetPos(moved)
+	deadline := time.Now().Add(time.Second)
+	for objects[0].Pos().Z == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no snapshot was applied")
+		}
+		time.Sleep(time.Millisecond)
+		l.Apply()
+	}
+	if p := objects[1].Pos(); p != moved {
+		t.Errorf("the object the loop does not update was put back at %v, want %v", p, moved)
+	}
+}
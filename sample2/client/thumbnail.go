@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+)
+
+// CaptureObjectThumbnail renders o by itself, seen from the direction the
+// camera looks in and framed to fit, onto a transparent image of the given
+// size. The scene and camera are left as they are, and only o is drawn, with
+// the shader inputs it was last drawn with.
+func (g *Game) CaptureObjectThumbnail(o *gfx.Object, size image.Point) (*image.RGBA, error) {
+	if size.X <= 0 || size.Y <= 0 {
+		return nil, errors.New("CaptureObjectThumbnail: empty size")
+	}
+	b := image.Rectangle{Max: size}
+	cfg := g.d.Info().RTTFormats.ChooseConfig(gfx.Precision{
+		RedBits: 8, GreenBits: 8, BlueBits: 8, AlphaBits: 8,
+		DepthBits: 24,
+	}, true)
+	color := gfx.NewTexture()
+	color.MinFilter = gfx.Nearest
+	color.MagFilter = gfx.Nearest
+	cfg.Color = color
+	cfg.Bounds = b
+	c := g.d.RenderToTexture(cfg)
+	if c == nil {
+		return nil, errors.New("CaptureObjectThumbnail: render to texture is not supported")
+	}
+
+	cam := camera.New(b)
+	cam.FOV = g.cam.FOV
+	cam.Update(b)
+	cam.SetQuat(g.cam.Quat())
+	cam.SetPos(focusPos(cam, o, float64(size.X)/float64(size.Y)))
+
+	c.Clear(b, gfx.Color{0, 0, 0, 0})
+	c.ClearDepth(b, 1.0)
+	c.Draw(b, o, cam)
+	done := make(chan image.Image, 1)
+	c.Download(b, done)
+	c.Render()
+	img := <-done
+	if img == nil {
+		return nil, errors.New("CaptureObjectThumbnail: download failed")
+	}
+	rgba, ok := img.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(img.Bounds())
+		draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	}
+	return rgba, nil
+}
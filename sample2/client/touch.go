@@ -0,0 +1,159 @@
+package main
+
+import (
+	"image"
+	"log"
+	"math"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// touchEvent is implemented by window events reporting a touch point moving,
+// or being put down or lifted. The window package does not deliver touch
+// events yet, so no event implements it: touch gestures do nothing, and the
+// FPS counter says so while they are on.
+type touchEvent interface {
+	TouchID() int
+	TouchPos() (x, y float64)
+
+	// TouchDown is false once the touch point is lifted.
+	TouchDown() bool
+}
+
+// touchGestures turns touch points into camera moves: one finger orbits the
+// camera around the selected object or the origin, as fast as the mouse
+// sensitivity says, and two fingers pan with their centroid and zoom with the
+// distance between them.
+type touchGestures struct {
+	mouseLook
+	enabled bool
+	points  map[int]lmath.Vec2
+
+	// The centroid and spread of the two finger gesture in progress.
+	centroid lmath.Vec2
+	spread   float64
+}
+
+// SetTouchEnabled turns touch camera gestures on or off. On windows without
+// touch events it only logs a notice.
+func (g *Game) SetTouchEnabled(enabled bool) {
+	g.touch.enabled = enabled
+	g.touch.points = make(map[int]lmath.Vec2)
+	if enabled && !g.touchSeen {
+		log.Println("Touch gestures enabled, no touch events were received from this window yet.")
+	}
+}
+
+// touchText returns the notice the FPS counter shows while touch gestures are
+// on without any touch event having arrived, or nothing.
+func (g *Game) touchText() string {
+	if !g.touch.enabled || g.touchSeen {
+		return ""
+	}
+	return " | touch: no touch events from the window"
+}
+
+// handleTouch updates the gesture in progress with the touch event e.
+func (g *Game) handleTouch(e touchEvent) {
+	g.touchSeen = true
+	t := &g.touch
+	if !t.enabled {
+		return
+	}
+	x, y := e.TouchPos()
+	p := lmath.Vec2{x, y}
+	old, had := t.points[e.TouchID()]
+	before := len(t.points)
+	if e.TouchDown() {
+		t.points[e.TouchID()] = p
+	} else {
+		delete(t.points, e.TouchID())
+	}
+
+	// A finger put down or lifted changes the gesture, which restarts from
+	// the points as they are now instead of jumping.
+	if len(t.points) != before || !had {
+		t.centroid, t.spread = t.twoFingers()
+		return
+	}
+
+	switch len(t.points) {
+	case 1:
+		g.touchOrbit(p.Sub(old))
+	case 2:
+		centroid, spread := t.twoFingers()
+		g.touchPan(centroid.Sub(t.centroid))
+		if t.spread > 0 && spread > 0 {
+			g.touchZoom(centroid, spread/t.spread)
+		}
+		t.centroid, t.spread = centroid, spread
+	}
+}
+
+// twoFingers returns the centroid of the first two touch points and the
+// distance between them.
+func (t *touchGestures) twoFingers() (centroid lmath.Vec2, spread float64) {
+	var p []lmath.Vec2
+	for _, v := range t.points {
+		p = append(p, v)
+		if len(p) == 2 {
+			break
+		}
+	}
+	if len(p) < 2 {
+		return lmath.Vec2{}, 0
+	}
+	return p[0].Add(p[1]).MulScalar(0.5), p[0].Sub(p[1]).Length()
+}
+
+// touchCenter returns the point one finger orbits the camera around.
+func (g *Game) touchCenter() lmath.Vec3 {
+	if g.selected != nil {
+		c, _ := boundingSphere(worldBounds(g.selected))
+		return c
+	}
+	return lmath.Vec3{}
+}
+
+// touchOrbit turns the camera around the orbit center by the drag delta, in
+// pixels.
+func (g *Game) touchOrbit(delta lmath.Vec2) {
+	center := g.touchCenter()
+	dist := g.cam.Pos().Sub(center).Length()
+	yaw, pitch := g.touch.turn(delta.X, delta.Y)
+	rot := g.cam.Rot()
+	rot.Z -= yaw * 0.3
+	rot.X = math.Max(-89, math.Min(89, rot.X-pitch*0.3))
+	g.cam.SetRot(rot)
+	g.cam.SetPos(center.Sub(cameraForward(g.cam).MulScalar(dist)))
+}
+
+// touchPan moves the camera sideways so that the orbit center follows the
+// centroid moving by delta pixels.
+func (g *Game) touchPan(delta lmath.Vec2) {
+	dist := g.cam.Pos().Sub(g.touchCenter()).Length()
+	worldPerPixel := 2 * dist * math.Tan(lmath.Radians(g.cam.FOV)/2) / float64(g.bounds.Dy())
+	m := g.cam.Object.Convert(gfx.LocalToWorld)
+	origin := transformPoint(m, lmath.Vec3{})
+	right := transformPoint(m, lmath.Vec3{1, 0, 0}).Sub(origin)
+	up := transformPoint(m, lmath.Vec3{0, 0, 1}).Sub(origin)
+	move := right.MulScalar(-delta.X * worldPerPixel).Add(up.MulScalar(delta.Y * worldPerPixel))
+	g.cam.SetPos(g.cam.Pos().Add(move))
+}
+
+// touchZoom moves the camera towards the point under the centroid, by the
+// ratio the fingers spread apart. Moving along the ray through the centroid
+// keeps what is under it in place.
+func (g *Game) touchZoom(centroid lmath.Vec2, ratio float64) {
+	_, dir, ok := screenRay(g.cam, g.bounds, image.Pt(int(centroid.X), int(centroid.Y)))
+	if !ok {
+		return
+	}
+	dist := g.cam.Pos().Sub(g.touchCenter()).Length()
+	step := dist * (1 - 1/ratio)
+	if min := g.cam.Near * 2; dist-step < min {
+		step = dist - min
+	}
+	g.cam.SetPos(g.cam.Pos().Add(dir.MulScalar(step)))
+}
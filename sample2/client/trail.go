@@ -0,0 +1,147 @@
+package main
+
+import (
+	"log"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/gfxutil"
+	"azul3d.org/engine/lmath"
+)
+
+// Trail draws a ribbon following the recent positions of an object, tapering
+// and fading out towards its tail.
+type Trail struct {
+	// Width is the width of the ribbon at its head, in world units.
+	Width float64
+	Color gfx.Color
+
+	target *gfx.Object
+
+	// points is a ring buffer of the last positions of target, the newest
+	// one being at head.
+	points      []lmath.Vec3
+	head, count int
+
+	obj  *gfx.Object
+	mesh *gfx.Mesh
+}
+
+// AddTrail adds a trail following the last length positions of o, one per
+// frame, and returns it.
+func (s *Scene) AddTrail(o *gfx.Object, length int, color gfx.Color) *Trail {
+	if length < 2 {
+		length = 2
+	}
+	t := &Trail{
+		Width:  0.3,
+		Color:  color,
+		target: o,
+		points: make([]lmath.Vec3, length),
+	}
+
+	// The ribbon has two vertices per point, and a quad between every two
+	// points. Only the vertices change from frame to frame.
+	t.mesh = gfx.NewMesh()
+	t.mesh.Vertices = make([]gfx.Vec3, 2*length)
+	t.mesh.Colors = make([]gfx.Color, 2*length)
+	for i := 0; i+1 < length; i++ {
+		a, b := uint32(2*i), uint32(2*i+2)
+		t.mesh.Indices = append(t.mesh.Indices, a, a+1, b, b, a+1, b+1)
+	}
+
+	t.obj = gfx.NewObject()
+	t.obj.State = gfx.NewState()
+	t.obj.AlphaMode = gfx.AlphaBlend
+	t.obj.DepthWrite = false
+	t.obj.FaceCulling = gfx.NoFaceCulling
+	t.obj.Meshes = []*gfx.Mesh{t.mesh}
+
+	s.trails = append(s.trails, t)
+	return t
+}
+
+// record adds the current position of the target to the ring buffer,
+// overwriting the oldest one once it is full.
+func (t *Trail) record() {
+	t.head = (t.head + 1) % len(t.points)
+	t.points[t.head] = transformPoint(t.target.Convert(gfx.LocalToWorld), lmath.Vec3{})
+	if t.count < len(t.points) {
+		t.count++
+	}
+}
+
+// point returns the i'th newest recorded position.
+func (t *Trail) point(i int) lmath.Vec3 {
+	return t.points[(t.head-i+len(t.points))%len(t.points)]
+}
+
+// build rewrites the ribbon vertices to face the camera at eye. Points not
+// recorded yet repeat the oldest one, giving degenerate quads.
+func (t *Trail) build(eye lmath.Vec3) {
+	if t.count == 0 {
+		return
+	}
+	t.mesh.Lock()
+	n := len(t.points)
+	for i := 0; i < n; i++ {
+		j := i
+		if j >= t.count {
+			j = t.count - 1
+		}
+		p := t.point(j)
+
+		// The ribbon spans sideways to both the path and the view direction.
+		prev, next := t.point(j), t.point(j)
+		if j > 0 {
+			prev = t.point(j - 1)
+		}
+		if j+1 < t.count {
+			next = t.point(j + 1)
+		}
+		side := prev.Sub(next).Cross(eye.Sub(p))
+		if l := side.Length(); l > 0 {
+			side = side.DivScalar(l)
+		}
+
+		// Taper the width and fade the color linearly towards the tail.
+		f := 1 - float64(j)/float64(n-1)
+		side = side.MulScalar(t.Width / 2 * f)
+		c := t.Color
+		c.A *= float32(f)
+
+		t.mesh.Vertices[2*i] = gfx.ConvertVec3(p.Add(side))
+		t.mesh.Vertices[2*i+1] = gfx.ConvertVec3(p.Sub(side))
+		t.mesh.Colors[2*i] = c
+		t.mesh.Colors[2*i+1] = c
+	}
+	t.mesh.Changed = true
+	t.mesh.Unlock()
+}
+
+// trailObjects builds the trails for the camera at eye and returns their
+// objects, or nil if the trail shader is unavailable.
+func (s *Scene) trailObjects(eye lmath.Vec3) []*gfx.Object {
+	if len(s.trails) == 0 {
+		return nil
+	}
+	if s.trailShader == nil {
+		sh, err := gfxutil.OpenShader("trail")
+		if err != nil {
+			log.Println("Trails disabled:", err)
+			s.trails = nil
+			return nil
+		}
+		s.trailShader = sh
+	}
+	objs := s.trailObjs[:0]
+	for _, t := range s.trails {
+		if t.count < 2 {
+			continue
+		}
+		t.build(eye)
+		t.obj.Shader = s.trailShader
+		objs = append(objs, t.obj)
+	}
+	s.trailObjs = objs
+	return objs
+}
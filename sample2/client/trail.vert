@@ -0,0 +1,14 @@
+#version 120
+
+attribute vec3 Vertex;
+attribute vec4 Color;
+
+uniform mat4 MVP;
+
+varying vec4 color;
+
+void main()
+{
+	color = Color;
+	gl_Position = MVP * vec4(Vertex, 1.0);
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math"
+
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/lmath"
+
+	"github.com/mypianoplayer/ragtime_sample/sample2/client/ease"
+)
+
+// CameraTween moves and turns a camera towards a target over time.
+type CameraTween struct {
+	cam               *camera.Camera
+	fromPos, toPos    lmath.Vec3
+	fromRot, toRot    lmath.Vec3
+	duration, elapsed float64
+	easing            ease.Func
+	active            bool
+}
+
+// Start begins tweening cam from its current transform to the given position
+// and rotation over duration seconds, replacing any tween in progress. The
+// easing function shapes the progress, nil progresses linearly.
+func (t *CameraTween) Start(cam *camera.Camera, pos, rot lmath.Vec3, duration float64, easing ease.Func) {
+	if easing == nil {
+		easing = ease.Linear
+	}
+	*t = CameraTween{
+		cam:      cam,
+		fromPos:  cam.Pos(),
+		toPos:    pos,
+		fromRot:  cam.Rot(),
+		toRot:    rot,
+		duration: duration,
+		easing:   easing,
+		active:   true,
+	}
+}
+
+// Active reports whether the tween is still in progress.
+func (t *CameraTween) Active() bool {
+	return t.active
+}
+
+// Stop ends the tween where it is.
+func (t *CameraTween) Stop() {
+	t.active = false
+}
+
+// Update advances the tween by dt seconds.
+func (t *CameraTween) Update(dt float64) {
+	if !t.active {
+		return
+	}
+	t.elapsed += dt
+	f := 1.0
+	if t.duration > 0 {
+		f = math.Min(t.elapsed/t.duration, 1)
+	}
+	if f >= 1 {
+		t.active = false
+	}
+	f = t.easing(f)
+
+	t.cam.SetPos(t.fromPos.Lerp(t.toPos, f))
+	t.cam.SetRot(lmath.Vec3{
+		lerpAngle(t.fromRot.X, t.toRot.X, f),
+		lerpAngle(t.fromRot.Y, t.toRot.Y, f),
+		lerpAngle(t.fromRot.Z, t.toRot.Z, f),
+	})
+}
+
+// lerpAngle interpolates between two angles in degrees along the shortest way.
+func lerpAngle(a, b, t float64) float64 {
+	d := math.Mod(b-a, 360)
+	if d > 180 {
+		d -= 360
+	} else if d < -180 {
+		d += 360
+	}
+	return a + d*t
+}
@@ -0,0 +1,16 @@
+#version 120
+
+varying vec4 cur;
+varying vec4 prev;
+
+uniform float MaxVelocity;
+
+void main()
+{
+	// Motion in texture coordinates, where V grows downwards.
+	vec2 v = (cur.xy / cur.w - prev.xy / prev.w) * vec2(0.5, -0.5);
+
+	// Encode [-MaxVelocity, MaxVelocity] so that no motion is exactly 127.
+	v = clamp(v / MaxVelocity, -1.0, 1.0);
+	gl_FragColor = vec4((v * 127.0 + 127.0) / 255.0, 0.0, 1.0);
+}
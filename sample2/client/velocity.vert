@@ -0,0 +1,26 @@
+#version 120
+
+attribute vec3 Vertex;
+
+uniform mat4 MVP;
+uniform mat4 Model;
+uniform mat4 PrevViewProj;
+
+// PrevModel is the model matrix of the object in the previous frame, if it
+// Moved since then.
+uniform bool Moved;
+uniform mat4 PrevModel;
+
+varying vec4 cur;
+varying vec4 prev;
+
+void main()
+{
+	mat4 model = Model;
+	if(Moved) {
+		model = PrevModel;
+	}
+	cur = MVP * vec4(Vertex, 1.0);
+	prev = PrevViewProj * model * vec4(Vertex, 1.0);
+	gl_Position = cur;
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+
+	"azul3d.org/engine/gfx"
+)
+
+// videoQueue is how many downloaded frames may wait for the encoder before
+// capturing a frame drops or blocks on it.
+const videoQueue = 8
+
+// videoCapture writes the pixels of every frame as raw RGB to the standard
+// input of an encoder process, from a background goroutine.
+type videoCapture struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	size  image.Point
+
+	// drop sets whether frames are dropped rather than waited for when the
+	// encoder falls behind.
+	drop    bool
+	dropped int
+
+	frames chan chan image.Image
+	done   chan struct{}
+}
+
+// defaultVideoCommand returns the ffmpeg invocation encoding raw RGB frames of
+// the given size, at 60 frames per second, to capture.mp4.
+func defaultVideoCommand(size image.Point) []string {
+	return []string{
+		"ffmpeg", "-y", "-loglevel", "error",
+		"-f", "rawvideo", "-pixel_format", "rgb24",
+		"-video_size", fmt.Sprintf("%dx%d", size.X, size.Y),
+		"-framerate", "60", "-i", "-",
+		"-c:v", "libx264", "-pix_fmt", "yuv420p", "capture.mp4",
+	}
+}
+
+func newVideoCapture(args []string, size image.Point, drop bool) (*videoCapture, error) {
+	cmd := exec.Command(args[0], args[1:]...)
+	// Show what the encoder complains about, such as a bad argument.
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	v := &videoCapture{
+		cmd:    cmd,
+		stdin:  stdin,
+		size:   size,
+		drop:   drop,
+		frames: make(chan chan image.Image, videoQueue),
+		done:   make(chan struct{}),
+	}
+	go v.write()
+	return v, nil
+}
+
+// write sends the frames to the encoder as they are downloaded. Once writing
+// fails, because the encoder exited, the remaining frames are discarded.
+func (v *videoCapture) write() {
+	defer close(v.done)
+	rgb := make([]byte, 3*v.size.X*v.size.Y)
+	frame := image.NewRGBA(image.Rectangle{Max: v.size})
+	failed := false
+	for p := range v.frames {
+		img := <-p
+		if failed {
+			continue
+		}
+		if img == nil {
+			log.Println("video capture: a frame could not be downloaded")
+			continue
+		}
+
+		// Frames of another size, after the window was resized, are
+		// scaled to the size of the video.
+		if img.Bounds().Size() == v.size {
+			draw.Draw(frame, frame.Bounds(), img, img.Bounds().Min, draw.Src)
+		} else {
+			scaleNearest(frame, img)
+		}
+		for i, j := 0, 0; i < len(frame.Pix); i, j = i+4, j+3 {
+			rgb[j], rgb[j+1], rgb[j+2] = frame.Pix[i], frame.Pix[i+1], frame.Pix[i+2]
+		}
+		if _, err := v.stdin.Write(rgb); err != nil {
+			log.Println("video capture:", err)
+			failed = true
+		}
+	}
+}
+
+// scaleNearest draws src over the whole of dst, picking the nearest pixel.
+func scaleNearest(dst *image.RGBA, src image.Image) {
+	sb, db := src.Bounds(), dst.Bounds()
+	for y := 0; y < db.Dy(); y++ {
+		sy := sb.Min.Y + y*sb.Dy()/db.Dy()
+		for x := 0; x < db.Dx(); x++ {
+			sx := sb.Min.X + x*sb.Dx()/db.Dx()
+			dst.Set(db.Min.X+x, db.Min.Y+y, src.At(sx, sy))
+		}
+	}
+}
+
+// capture queues the current contents of the canvas. When the encoder falls
+// behind, the frame is dropped or waited for depending on drop.
+func (v *videoCapture) capture(c gfx.Canvas) {
+	if v.drop && len(v.frames) == cap(v.frames) {
+		v.dropped++
+		return
+	}
+	p := make(chan image.Image, 1)
+	c.Download(c.Bounds(), p)
+	v.frames <- p
+}
+
+// stop waits for every queued frame to be written, then closes the standard
+// input of the encoder for it to finish the video, and waits for it to exit.
+func (v *videoCapture) stop() error {
+	close(v.frames)
+	<-v.done
+	if v.dropped > 0 {
+		log.Printf("video capture: %d frames dropped while the encoder fell behind\n", v.dropped)
+	}
+	v.stdin.Close()
+	return v.cmd.Wait()
+}
+
+// StartVideoCapture starts writing every frame, scaled to size, as raw RGB to
+// the standard input of the command cmd until StopVideoCapture is called. A
+// nil cmd encodes capture.mp4 with ffmpeg, and a zero size is the size of the
+// window.
+func (g *Game) StartVideoCapture(cmd []string, size image.Point) error {
+	if err := g.StopVideoCapture(); err != nil {
+		log.Println("video capture:", err)
+	}
+	if size.X <= 0 || size.Y <= 0 {
+		size = g.d.Bounds().Size()
+	}
+	if len(cmd) == 0 {
+		cmd = defaultVideoCommand(size)
+	}
+	v, err := newVideoCapture(cmd, size, g.videoDrop)
+	if err != nil {
+		return err
+	}
+	g.video = v
+	return nil
+}
+
+// StopVideoCapture stops the running video capture once all of its frames
+// were written, and returns the error the encoder exited with, if any.
+func (g *Game) StopVideoCapture() error {
+	if g.video == nil {
+		return nil
+	}
+	err := g.video.stop()
+	g.video = nil
+	return err
+}
+
+// SetVideoDropFrames sets whether the video capture drops frames when the
+// encoder falls behind, keeping the frame rate, rather than waiting for it,
+// keeping every frame. It waits by default.
+func (g *Game) SetVideoDropFrames(drop bool) {
+	g.videoDrop = drop
+	if g.video != nil {
+		g.video.drop = drop
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"image"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// viewportRegion is the part of a larger virtual viewport the framebuffer
+// shows, for display walls where each machine draws one tile of the view.
+type viewportRegion struct {
+	virtual image.Point
+	region  image.Rectangle
+
+	// overscan is how many virtual pixels past each edge of the region are
+	// drawn too, for tiles that overlap to be blended or hide bezels.
+	overscan int
+}
+
+// SetViewportRegion makes the camera project as if the framebuffer were a
+// virtual viewport of virtualSize pixels, of which only region is drawn,
+// stretched over the whole framebuffer. Machines drawing adjacent regions of the
+// same virtual viewport, with the same camera, show seamless tiles of one
+// view. An empty region goes back to drawing the whole view to the
+// framebuffer.
+func (g *Game) SetViewportRegion(virtualSize image.Point, region image.Rectangle) {
+	if virtualSize.X <= 0 || virtualSize.Y <= 0 || region.Empty() {
+		g.viewport = nil
+	} else {
+		overscan := 0
+		if g.viewport != nil {
+			overscan = g.viewport.overscan
+		}
+		g.viewport = &viewportRegion{
+			virtual:  virtualSize,
+			region:   region,
+			overscan: overscan,
+		}
+	}
+	g.updateProjection()
+}
+
+// SetViewportOverscan sets how many virtual pixels past each edge of the
+// viewport region are drawn as well. It has no effect without a region.
+func (g *Game) SetViewportOverscan(pixels int) {
+	if g.viewport == nil {
+		return
+	}
+	if pixels < 0 {
+		pixels = 0
+	}
+	g.viewport.overscan = pixels
+	g.updateProjection()
+}
+
+// updateProjection updates the projection of the camera for the framebuffer,
+// or the viewport region if one is set.
+func (g *Game) updateProjection() {
+	b := g.d.Bounds()
+	if g.viewport != nil {
+		b = image.Rectangle{Max: g.viewport.virtual}
+	}
+	g.cam.Update(b)
+
+	// An orthographic camera is centered on its position and orthoHeight
+	// units high, rather than one unit per pixel.
+	if g.cam.Ortho && g.orthoHeight > 0 {
+		h := g.orthoHeight / 2
+		w := h * float64(b.Dx()) / float64(b.Dy())
+		g.cam.P = gfx.ConvertMat4(lmath.Mat4Ortho(-w, w, -h, h, g.cam.Near, g.cam.Far))
+	}
+	if g.viewport != nil {
+		crop := g.viewport.crop()
+		g.cam.P = gfx.ConvertMat4(g.cam.Projection().Mat4().Mul(crop))
+	}
+}
+
+// crop returns the matrix scaling and moving clip space so that the region,
+// grown by the overscan, fills it.
+func (v *viewportRegion) crop() lmath.Mat4 {
+	r := v.region.Inset(-v.overscan)
+
+	// The region in normalized device coordinates, with Y up where pixel
+	// rows go down.
+	x0 := 2*float64(r.Min.X)/float64(v.virtual.X) - 1
+	x1 := 2*float64(r.Max.X)/float64(v.virtual.X) - 1
+	y0 := 1 - 2*float64(r.Max.Y)/float64(v.virtual.Y)
+	y1 := 1 - 2*float64(r.Min.Y)/float64(v.virtual.Y)
+
+	// Applied before the perspective divide, the offsets are scaled by w.
+	m := lmath.Mat4Identity
+	m[0][0] = 2 / (x1 - x0)
+	m[1][1] = 2 / (y1 - y0)
+	m[3][0] = -(x1 + x0) / (x1 - x0)
+	m[3][1] = -(y1 + y0) / (y1 - y0)
+	return m
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// Wind sways the vertices of the objects given a sway with SetWindSway.
+type Wind struct {
+	// Dir is the direction the wind blows in, Strength how far in world
+	// units the top of a swaying object moves and Frequency how many times
+	// a second it sways back and forth. A strength of zero is no wind.
+	Dir       lmath.Vec3
+	Strength  float64
+	Frequency float64
+
+	// BendNormals tilts the normals along with the sway, so that lighting
+	// follows it.
+	BendNormals bool
+}
+
+// SetWindSway sets how much o sways in the wind of the scene, one being the
+// full strength of the wind. Each vertex sways by the square of its height
+// within the local bounds of o, so the bottom stays anchored. Zero stops it.
+func SetWindSway(o *gfx.Object, sway float64) {
+	propsOf(o).sway = sway
+	if sway == 0 {
+		if p := props[o]; p.shader != nil && o.Shader == p.shader {
+			p.shader.Lock()
+			p.shader.Inputs["Wind"] = false
+			p.shader.Unlock()
+		}
+	}
+}
+
+// setWindInputs passes the wind to the own shader of o, if it sways.
+func (s *Scene) setWindInputs(o *gfx.Object) {
+	p, ok := props[o]
+	if !ok || p.sway == 0 {
+		return
+	}
+	w := s.Wind
+	b := o.Bounds()
+	sh := ownShader(o)
+	sh.Lock()
+	sh.Inputs["Wind"] = w.Strength != 0
+	sh.Inputs["WindDir"] = gfx.ConvertVec3(w.Dir.Normalized())
+	sh.Inputs["WindStrength"] = float32(w.Strength * p.sway)
+	sh.Inputs["WindFrequency"] = float32(w.Frequency)
+	sh.Inputs["WindBase"] = float32(b.Min.Z)
+	sh.Inputs["WindHeight"] = float32(b.Max.Z - b.Min.Z)
+	sh.Inputs["BendNormals"] = w.BendNormals
+	sh.Inputs["Time"] = float32(s.time)
+	sh.Unlock()
+}
+
+// SetWind sets the wind of the scene, see Wind.
+func (g *Game) SetWind(dir lmath.Vec3, strength, frequency float64) {
+	g.scene.Wind.Dir = dir
+	g.scene.Wind.Strength = strength
+	g.scene.Wind.Frequency = frequency
+}
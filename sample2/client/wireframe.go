@@ -0,0 +1,121 @@
+package main
+
+import (
+	"azul3d.org/engine/gfx"
+)
+
+// barycentricAttrib is the vertex attribute holding the barycentric
+// coordinates of every vertex within its triangle.
+const barycentricAttrib = "Barycentric"
+
+// SetBarycentricWireframe draws the edges of the triangles of o over its fill
+// in the same pass, width pixels wide in the given color. The edges are found
+// from the distance to them in barycentric coordinates, so they stay crisp at
+// any resolution and need no depth offset. A width of zero turns it off.
+//
+// The meshes of o are replaced by copies with barycentric coordinates, which
+// needs a vertex of its own per triangle corner, so indexed meshes are
+// expanded. Objects sharing a mesh share its copy, and the mesh itself is left
+// as it was for the other objects using it.
+func SetBarycentricWireframe(o *gfx.Object, color gfx.Color, width float64) {
+	if width > 0 {
+		meshes := make([]*gfx.Mesh, len(o.Meshes))
+		for i, m := range o.Meshes {
+			meshes[i] = withBarycentrics(m)
+		}
+		o.Meshes = meshes
+	}
+	sh := ownShader(o)
+	sh.Lock()
+	sh.Inputs["Wireframe"] = width > 0
+	sh.Inputs["WireColor"] = color
+	sh.Inputs["WireWidth"] = float32(width)
+	sh.Unlock()
+}
+
+// barycentricMeshes maps the meshes given to withBarycentrics to their copies
+// with barycentric coordinates.
+var barycentricMeshes = make(map[*gfx.Mesh]*gfx.Mesh)
+
+// withBarycentrics returns m if it has barycentric coordinates, or else a copy
+// of it giving every triangle the coordinates (1, 0, 0), (0, 1, 0) and
+// (0, 0, 1) at its corners. The copy is made once per mesh.
+func withBarycentrics(m *gfx.Mesh) *gfx.Mesh {
+	if c, ok := barycentricMeshes[m]; ok {
+		return c
+	}
+	m.RLock()
+	_, ok := m.Attribs[barycentricAttrib]
+	if ok {
+		m.RUnlock()
+		return m
+	}
+	c := m.Copy()
+	m.RUnlock()
+	addBarycentrics(c)
+	barycentricMeshes[m] = c
+	return c
+}
+
+// addBarycentrics gives every triangle of m the barycentric coordinates
+// (1, 0, 0), (0, 1, 0) and (0, 0, 1) at its corners, unless it has them. The
+// mesh is changed in place, so it must not be shared; see withBarycentrics.
+func addBarycentrics(m *gfx.Mesh) {
+	m.Lock()
+	defer m.Unlock()
+	if _, ok := m.Attribs[barycentricAttrib]; ok {
+		return
+	}
+	if len(m.Indices) > 0 {
+		unindex(m)
+	}
+	bary := make([]gfx.Vec3, len(m.Vertices))
+	for i := range bary {
+		switch i % 3 {
+		case 0:
+			bary[i] = gfx.Vec3{1, 0, 0}
+		case 1:
+			bary[i] = gfx.Vec3{0, 1, 0}
+		default:
+			bary[i] = gfx.Vec3{0, 0, 1}
+		}
+	}
+	if m.Attribs == nil {
+		m.Attribs = make(map[string]gfx.VertexAttrib)
+	}
+	m.Attribs[barycentricAttrib] = gfx.VertexAttrib{Data: bary}
+	m.Changed = true
+}
+
+// unindex expands the indexed mesh m, which must be locked, into one vertex
+// per index.
+func unindex(m *gfx.Mesh) {
+	n := len(m.Indices)
+	vertices := make([]gfx.Vec3, n)
+	for i, idx := range m.Indices {
+		vertices[i] = m.Vertices[idx]
+	}
+	m.Vertices = vertices
+	if len(m.Normals) > 0 {
+		normals := make([]gfx.Vec3, n)
+		for i, idx := range m.Indices {
+			normals[i] = m.Normals[idx]
+		}
+		m.Normals = normals
+	}
+	if len(m.Colors) > 0 {
+		colors := make([]gfx.Color, n)
+		for i, idx := range m.Indices {
+			colors[i] = m.Colors[idx]
+		}
+		m.Colors = colors
+	}
+	for s, set := range m.TexCoords {
+		tc := make([]gfx.TexCoord, n)
+		for i, idx := range m.Indices {
+			tc[i] = set.Slice[idx]
+		}
+		m.TexCoords[s] = gfx.TexCoordSet{Slice: tc}
+	}
+	m.Indices = nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"azul3d.org/engine/gfx"
+)
+
+// SetClipZBias draws o with its depth moved toward the camera by bias, in
+// normalized device coordinates where the depth range is two wide, so that it
+// wins against surfaces it lies on such as a label on a card. The offset is
+// applied in clip space after projection, which makes it the same number of
+// depth buffer steps at any distance and window size; with 24 bits of depth
+// one step is about 1.2e-7. It only has to be large enough to cover the
+// rounding between coplanar meshes, as objects further in front than it are
+// still ordered by depth. A bias of zero draws o as usual.
+func SetClipZBias(o *gfx.Object, bias float64) {
+	sh := ownShader(o)
+	sh.Lock()
+	sh.Inputs["ClipZBias"] = float32(bias)
+	sh.Unlock()
+}